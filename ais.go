@@ -15,6 +15,7 @@ import (
 	"hash/fnv"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
@@ -55,18 +56,12 @@ type Matching interface {
 // type Match func(rec *Record) bool
 
 // Vessel is a struct for the identifying information about a specific
-// ship in an AIS dataset.  NOTE: REFINEMENT OF PACKAGE AIS WILL
-// INCORPORATE MORE OF THE SHIP IDENTIFYING DATA COMMENTED OUT IN THIS
-// MINIMALLY VIABLE IMPLEMENTATION.
+// ship in an AIS dataset.  The remaining static data for a ship such as
+// IMO, CallSign, VesselType and dimensions is held by a VesselIdentity
+// obtained from a VesselRegistry.
 type Vessel struct {
 	MMSI       string
 	VesselName string
-	// IMO        string
-	// Callsign   string
-	// VesselType string
-	// Length     string
-	// Width      string
-	// Draft      string
 }
 
 // VesselSet is a set of unique vessels usually obtained by the return
@@ -106,6 +101,35 @@ func NewGeohasher(rs *RecordSet) *Geohasher {
 // the variadic function on a *Geohasher must be the index of "LAT" and "LON"
 // in the rec.  Field will come back nil for any non-nil error returned.
 func (g *Geohasher) Generate(rec Record, index ...int) (Field, error) {
+	return generateGeohash(rec, DefaultGeohashPrecision, index...)
+}
+
+// DefaultGeohashPrecision is the number of bits of precision used by a
+// Geohasher.  Twenty-two bits corresponds to boxes that are about .1 degree
+// on a side.
+const DefaultGeohashPrecision uint = 22
+
+// PrecisionGeohasher implements the Generator interface to append a geohash
+// with a caller specified number of bits of precision.  Fewer bits create larger
+// boxes and therefore larger Clusters when used with Window.FindClusters.  The
+// value must be between 1 and 64.
+//
+//	gen := ais.PrecisionGeohasher(20)
+//	rs, err = rs.AppendField("Geohash", []string{"LAT", "LON"}, gen)
+type PrecisionGeohasher uint
+
+// Generate implements the Generator interface for a PrecisionGeohasher.  The index
+// values must be the index of "LAT" and "LON" in the rec.
+func (bits PrecisionGeohasher) Generate(rec Record, index ...int) (Field, error) {
+	if bits < 1 || bits > 64 {
+		return "", fmt.Errorf("geohash: precision must be between 1 and 64 bits, got %d", bits)
+	}
+	return generateGeohash(rec, uint(bits), index...)
+}
+
+// Unexported generateGeohash provides the shared implementation for the
+// geohash Generators.
+func generateGeohash(rec Record, bits uint, index ...int) (Field, error) {
 	if len(index) != 2 {
 		return "", fmt.Errorf("geohash: generate: len(index) must equal" +
 			" 2 where the first int is the index of `LAT` and the second int is the index of `LON`")
@@ -121,7 +145,7 @@ func (g *Geohasher) Generate(rec Record, index ...int) (Field, error) {
 	if err != nil {
 		return "", fmt.Errorf("geohash: unable to parse lon")
 	}
-	hash := geohash.EncodeIntWithPrecision(lat, lon, uint(22))
+	hash := geohash.EncodeIntWithPrecision(lat, lon, bits)
 	return Field(fmt.Sprintf("%#x", hash)), nil
 }
 
@@ -136,6 +160,16 @@ type RecordSet struct {
 	data  io.ReadWriter // client provided io interface
 	first *Record       // accessible only by package functions
 	stash *Record       // stashed Record from a client Read() but not yet used
+	src   recordReader  // binary data source read in place of r when non-nil
+	watch *watch        // cancellation and progress of a Context method
+	size  int64         // size of the file the RecordSet was opened from
+	pos   func() int64  // bytes read from the file or io.Reader, when known
+
+	policy  ErrorPolicy // handling of malformed Records
+	sink    RejectSink  // receives the Records rejected by RejectInvalid
+	skipped int         // Records skipped or rejected under the policy
+	line    int         // line of the last Record read from the data
+	lines   *lineReader // counts the lines read by r
 }
 
 // NewRecordSet returns a *Recordset that has an in-memory data buffer for
@@ -147,7 +181,7 @@ func NewRecordSet() *RecordSet {
 
 	buf := bytes.Buffer{}
 	rs.data = &buf
-	rs.r = csv.NewReader(&buf)
+	rs.r = rs.newCSVReader(&buf)
 	rs.w = csv.NewWriter(&buf)
 
 	rs.r.LazyQuotes = true
@@ -159,32 +193,176 @@ func NewRecordSet() *RecordSet {
 // OpenRecordSet takes the filename of an ais data file as its input.
 // It returns a pointer to the RecordSet and a nil error upon successfully
 // validating that the file can be read by an encoding/csv Reader. It returns
-// a nil Recordset on any non-nil error.
+// a nil Recordset on any non-nil error.  The file is opened read only, so
+// RecordSet.Write and RecordSet.Flush return ErrReadOnly.  Files
+// compressed with gzip or a registered Compression, such as zstd, and zip
+// archives are decompressed as they are read, and columnar files and files in
+// a registered Format are detected from their content.
 func OpenRecordSet(filename string) (*RecordSet, error) {
 	rs := NewRecordSet()
+	rs.w = csv.NewWriter(readOnlyWriter{})
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open recordset: %v", err)
+	}
+
+	magic, err := readMagic(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open recordset: %v", err)
+	}
+	if format, ok := formatByMagic(magic); ok {
+		f.Close()
+		fr, err := format.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("open recordset: %v", err)
+		}
+		rs.data = nil
+		rs.setSource(fr, fr.Fields())
+		return rs, nil
+	}
 
-	f, err := os.OpenFile(filename, os.O_RDWR, 0666) // 0666 - Read Write
+	data, err := decompress(f, magic)
 	if err != nil {
+		f.Close()
 		return nil, fmt.Errorf("open recordset: %v", err)
 	}
-	rs.data = f
-	rs.r = csv.NewReader(f)
+	if err := rs.load(data); err != nil {
+		rs.Close()
+		return nil, fmt.Errorf("open recordset: %v", err)
+	}
+	if info, err := f.Stat(); err == nil {
+		rs.size = info.Size()
+	}
+	rs.pos = func() int64 {
+		n, _ := f.Seek(0, io.SeekCurrent)
+		return n
+	}
+	return rs, nil
+}
+
+// NewRecordSetFromReader returns a *RecordSet that reads its Headers and Records
+// from r, such as os.Stdin, an HTTP response body or a strings.Reader in a test.
+// Data compressed with gzip or a registered Compression and data in the
+// columnar format are detected as in OpenRecordSet.  Zip archives and
+// registered Formats require random access to a file and must be opened with
+// OpenRecordSet.  When r is an io.Closer it is closed by RecordSet.Close.  The
+// RecordSet is read only, so RecordSet.Write and RecordSet.Flush return
+// ErrReadOnly.
+func NewRecordSetFromReader(r io.Reader) (*RecordSet, error) {
+	rs := NewRecordSet()
+	rs.w = csv.NewWriter(readOnlyWriter{})
+
+	var c io.Closer
+	if rc, ok := r.(io.Closer); ok {
+		c = rc
+	}
+	cr := &countingReader{r: r}
+	rs.pos = func() int64 { return cr.n }
+	br := bufio.NewReader(cr)
+	magic, _ := br.Peek(4)
+	data, err := decompressStream(br, string(magic), c)
+	if err != nil {
+		return nil, fmt.Errorf("new recordset from reader: %v", err)
+	}
+	if err := rs.load(data); err != nil {
+		rs.Close()
+		return nil, fmt.Errorf("new recordset from reader: %v", err)
+	}
+	return rs, nil
+}
+
+// Unexported load makes data the source of the RecordSet and reads its
+// Headers.  Columnar data is read with a colReader and all other data as csv.
+func (rs *RecordSet) load(data io.ReadWriter) error {
+	rs.data = data
+	br := bufio.NewReader(data)
+	m, _ := br.Peek(len(columnarMagic))
+	if string(m) == columnarMagic {
+		cr, err := newColReader(br)
+		if err != nil {
+			return err
+		}
+		rs.setSource(cr, cr.fields)
+		return nil
+	}
+	if format, ok := formatByMagic(string(m)); ok {
+		return fmt.Errorf("%s data must be opened from an uncompressed file with OpenRecordSet", format.Name)
+	}
+
+	rs.r = rs.newCSVReader(br)
 	rs.r.LazyQuotes = true
 	rs.r.Comment = '#'
 
-	rs.w = csv.NewWriter(f)
-
 	// The first non-comment line of a valid ais datafile should contain the headers.
 	// The following Read() command also advances the file pointer so that
 	// it now points at the first data line.
-	var h Headers
-	h.Fields, err = rs.r.Read()
+	h, err := readHeaders(rs.r)
 	if err != nil {
-		return nil, fmt.Errorf("open recordset: %v", err)
+		return err
 	}
 	rs.h = h
+	return nil
+}
 
-	return rs, nil
+// Unexported readHeaders reads the header line from r.  Lines that start with
+// '#' are comments unless they are the header line of a data source with a
+// known Schema, such as the "# Timestamp" header of DMA files.  When the header
+// line does not contain the canonical MarineCadastre names the Schema is
+// inferred from the known Schemas.
+func readHeaders(r *csv.Reader) (Headers, error) {
+	var h Headers
+	comment := r.Comment
+	r.Comment = 0
+	defer func() { r.Comment = comment }()
+
+	for {
+		r.FieldsPerRecord = 0
+		fields, err := r.Read()
+		if err != nil {
+			return Headers{}, err
+		}
+		if comment != 0 && len(fields) > 0 && strings.HasPrefix(fields[0], string(comment)) {
+			if s, ok := InferSchema(fields); !ok || len(s.Columns) == 0 {
+				continue
+			}
+		}
+		h.Fields = fields
+		break
+	}
+	if s, ok := InferSchema(h.Fields); ok && len(s.Columns) > 0 {
+		h.schema = &s
+	}
+	return h, nil
+}
+
+// recordReader is implemented by the readers of the binary file formats that
+// OpenRecordSet reads in place of csv.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// readMagic returns the first four bytes of f, or fewer for short files,
+// without moving its read offset.
+func readMagic(f *os.File) (string, error) {
+	magic := make([]byte, 4)
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(magic[:n]), nil
+}
+
+// setSource makes src the source of the Records of the RecordSet and sets its
+// Headers to fields, inferring the Schema as readHeaders does.
+func (rs *RecordSet) setSource(src recordReader, fields []string) {
+	rs.src = src
+	rs.line = 1 // the header line
+	rs.h = Headers{Fields: fields}
+	if s, ok := InferSchema(fields); ok && len(s.Columns) > 0 {
+		rs.h.schema = &s
+	}
 }
 
 // SetHeaders provides the expected interface to a RecordSet
@@ -212,7 +390,7 @@ func (rs *RecordSet) Read() (*Record, error) {
 		return rec, nil
 	}
 
-	r, err := rs.r.Read()
+	r, err := rs.readRaw()
 	if err == io.EOF {
 		return nil, err
 	}
@@ -223,13 +401,43 @@ func (rs *RecordSet) Read() (*Record, error) {
 	return &rec, nil
 }
 
+// readRaw returns the fields of the next line from the columnar source of
+// the RecordSet, or from its csv.Reader when it has none.  Malformed csv lines
+// are handled by the ErrorPolicy of the RecordSet.
+func (rs *RecordSet) readRaw() ([]string, error) {
+	if rs.watch != nil {
+		return rs.watch.read(rs)
+	}
+	if rs.src != nil {
+		rec, err := rs.src.Read()
+		if err == nil {
+			rs.line++
+		}
+		return rec, err
+	}
+	for {
+		rec, err := rs.r.Read()
+		if rec != nil {
+			rs.line = rs.recordLine(rec)
+		}
+		pe, ok := err.(*csv.ParseError)
+		if !ok || rs.policy == FailFast {
+			return rec, err
+		}
+		rs.line = pe.Line
+		if err := rs.reject(rec, -1, pe.Err); err != nil {
+			return nil, err
+		}
+	}
+}
+
 // ReadFirst is an unexported method used by various internal packages
 // to get the first line of the RecordSet.
 func (rs *RecordSet) readFirst() (*Record, error) {
 	if rs.first != nil {
 		return rs.first, nil
 	}
-	r, err := rs.r.Read()
+	r, err := rs.readRaw()
 	if err != nil {
 		return nil, err
 	}
@@ -267,7 +475,8 @@ func (rs *RecordSet) Flush() error {
 // that must be present in the RecordSet in order for Generator to be successful.
 // If no errors are encournterd it returns a pointer to a new *RecordSet and a
 // nil value for error.  If there is an error it will return a nil value for
-// the *RecordSet and an error.
+// the *RecordSet and an error.  Use AppendFields to append several fields in
+// one pass without buffering the data for each one.
 func (rs *RecordSet) AppendField(newField string, requiredHeaders []string, gen Generator) (*RecordSet, error) {
 	rs2 := NewRecordSet()
 
@@ -289,7 +498,7 @@ func (rs *RecordSet) AppendField(newField string, requiredHeaders []string, gen
 	written := 0
 	for {
 		var rec Record
-		rec, err := rs.r.Read()
+		rec, err := rs.readRaw()
 		if err == io.EOF {
 			break
 		}
@@ -299,7 +508,10 @@ func (rs *RecordSet) AppendField(newField string, requiredHeaders []string, gen
 
 		field, err := gen.Generate(rec, indices...)
 		if err != nil {
-			return nil, fmt.Errorf("appendfield: generate: %v", err)
+			if err := rs.reject(rec, -1, fmt.Errorf("generate: %v", err)); err != nil {
+				return nil, fmt.Errorf("appendfield: %v", err)
+			}
+			continue
 		}
 		rec = append(rec, string(field))
 		err = rs2.Write(rec)
@@ -328,6 +540,11 @@ func (rs *RecordSet) AppendField(newField string, requiredHeaders []string, gen
 //      defer rs.Close()
 // immediately after creating a NewRecordSet.
 func (rs *RecordSet) Close() error {
+	if c, ok := rs.src.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("recordset close: %v", err)
+		}
+	}
 	if rs.data == nil {
 		return nil
 	}
@@ -351,31 +568,104 @@ func (rs *RecordSet) Close() error {
 // Headers returns the encapsulated headers data of the Recordset
 func (rs *RecordSet) Headers() Headers { return rs.h }
 
-// Save writes the RecordSet to disk in the filename provided
+// Save writes the RecordSet to disk in the filename provided.  A filename
+// with the ColumnarExt extension is written in the columnar format and one
+// with the Ext of a registered Format is written in that Format.  All others
+// are written as csv.  A final GzipExt or ZipExt extension, or the Ext of a
+// registered Compression, compresses the output, so "dec2017.csv.gz" is a
+// gzipped csv file and "dec2017.zip" is a zip archive holding dec2017.csv.
 func (rs *RecordSet) Save(name string) error {
-	var err error
-	rs.data, err = os.Create(name)
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("recordset save: %v", err)
+	}
+	defer f.Close()
+
+	format := strings.TrimSuffix(name, compressionExt(name))
+	registered, ok := formatByExt(format)
+	if ok && format != name {
+		return fmt.Errorf("recordset save: %s files cannot be compressed", registered.Name)
+	}
+	w, err := compress(f, name)
+	if err != nil {
+		return fmt.Errorf("recordset save: %v", err)
+	}
+
+	switch {
+	case ok:
+		err = registered.Write(w, rs.h, rs.readRaw)
+	case filepath.Ext(format) == ColumnarExt:
+		err = rs.writeColumnar(w)
+	default:
+		err = rs.writeCSV(w)
+	}
 	if err != nil {
 		return fmt.Errorf("recordset save: %v", err)
 	}
-	rs.w = csv.NewWriter(rs.data) // FYI - csv uses bufio.NewWriter internally
-	rs.Write(rs.h.Fields)
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("recordset save: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("recordset save: %v", err)
+	}
+	return nil
+}
+
+// WriteTo writes the Headers and the Records remaining in the RecordSet to w as
+// csv and returns the number of bytes written.  It implements io.WriterTo, so a
+// RecordSet can be written to os.Stdout, an HTTP response or a bytes.Buffer.
+func (rs *RecordSet) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if err := rs.writeCSV(cw); err != nil {
+		return cw.n, fmt.Errorf("recordset write: %v", err)
+	}
+	return cw.n, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// writeCSV writes the Headers and the Records remaining in the RecordSet to w
+// as csv.
+func (rs *RecordSet) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w) // FYI - csv uses bufio.NewWriter internally
+	cw.Write(rs.h.Fields)
 
 	for {
-		rec, err := rs.r.Read()
+		rec, err := rs.readRaw()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("recordset save: read error on csv file: %v", err)
+			return fmt.Errorf("read error on csv file: %v", err)
 		}
-		rs.Write(rec)
+		cw.Write(rec)
 	}
-	err = rs.Flush()
-	if err != nil {
-		return fmt.Errorf("recordset save: flush error: %v", err)
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("flush error: %v", err)
 	}
-
 	return nil
 }
 
@@ -406,6 +696,16 @@ func (rs *RecordSet) SubsetLimit(m Matching, n int, multipass bool) (*RecordSet,
 	copyBuf := &bytes.Buffer{}
 	copyWriter := bufio.NewWriter(copyBuf)
 
+	// Blocks of a columnar RecordSet whose statistics rule out a match are
+	// skipped without being decoded.  Skipped Records are not copied, so this
+	// is only done in a single pass.
+	if cr, ok := rs.src.(*colReader); ok && !multipass {
+		if p, ok := m.(blockPruner); ok {
+			cr.prune = p.pruneBlock
+			defer func() { cr.prune = nil }()
+		}
+	}
+
 	recordsLeftToWrite := n
 	for recordsLeftToWrite != 0 {
 		var rec *Record
@@ -425,7 +725,10 @@ func (rs *RecordSet) SubsetLimit(m Matching, n int, multipass bool) (*RecordSet,
 
 		match, err := m.Match(rec)
 		if err != nil {
-			return nil, err
+			if err := rs.reject(*rec, -1, err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		if match {
@@ -452,7 +755,8 @@ func (rs *RecordSet) SubsetLimit(m Matching, n int, multipass bool) (*RecordSet,
 	}
 	if multipass {
 		copyWriter.Flush()
-		rs.r = csv.NewReader(copyBuf)
+		rs.r = rs.newCSVReader(copyBuf)
+		rs.src = nil
 	}
 	return rs2, nil
 }
@@ -520,7 +824,8 @@ func (rs *RecordSet) UniqueVesselsMulti(multipass bool) (VesselSet, error) {
 	}
 	if multipass {
 		copyWriter.Flush()
-		rs.r = csv.NewReader(copyBuf)
+		rs.r = rs.newCSVReader(copyBuf)
+		rs.src = nil
 	}
 	return vs, nil
 }
@@ -576,18 +881,32 @@ type Box struct {
 // can be caused by parse errors when converting string Record values into their
 // typed values. When Match returns a non-nil error the bool value will be false.
 func (b *Box) Match(rec *Record) (bool, error) {
-	lat, err := rec.ParseFloat(b.LatIndex)
+	lat, err := b.parse(rec, b.LatIndex)
 	if err != nil {
-		return false, fmt.Errorf("unable to parse %v", (*rec)[b.LatIndex])
+		return false, err
 	}
-	lon, err := rec.ParseFloat(b.LonIndex)
+	lon, err := b.parse(rec, b.LonIndex)
 	if err != nil {
-		return false, fmt.Errorf("unable to parse %v", (*rec)[b.LonIndex])
+		return false, err
 	}
 
 	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon, nil
 }
 
+// parse returns the float value at index of rec, or a *RecordError for the
+// column when the value is missing or cannot be parsed.
+func (b *Box) parse(rec *Record, index int) (float64, error) {
+	val, ok := rec.Value(index)
+	if !ok {
+		return 0, &RecordError{Column: index, Err: fmt.Errorf("index %d out of range", index)}
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, &RecordError{Column: index, Err: fmt.Errorf("unable to parse %v", val)}
+	}
+	return f, nil
+}
+
 // ByTimestamp implements the sort.Interface for creating a RecordSet
 // sorted by BaseDateTime. The ByTimestamp struct and its Len, Swap, and Less
 // methods are exported in order to serve as examples for how to implement the
@@ -595,21 +914,45 @@ func (b *Box) Match(rec *Record) (bool, error) {
 // do not need to call these methods.  Just call RecordSet.SortByTime() directly
 // to take advantage of the implementation provided in the package.
 type ByTimestamp struct {
-	h    Headers
-	data *[]Record
+	h     Headers
+	data  *[]Record
+	times []time.Time // UTC BaseDateTime of each Record in data
 }
 
 // NewByTimestamp returns a data structure suitable for sorting using
-// the sort.Interface tools.
+// the sort.Interface tools.  The BaseDateTime of every Record is parsed
+// once with Headers.ParseTime so that Records with timestamps in different
+// zones are compared as UTC instants.  Records whose BaseDateTime cannot be
+// parsed are handled by the ErrorPolicy of rs.
 func NewByTimestamp(rs *RecordSet) (*ByTimestamp, error) {
 	bt := new(ByTimestamp)
 	bt.h = rs.Headers()
+	timeIndex, ok := bt.h.Contains("BaseDateTime")
+	if !ok {
+		return nil, fmt.Errorf("new bytimestamp: headers does not contain BaseDateTime")
+	}
 
-	// Read the data from the underlying Recordset into a slice
-	var err error
-	bt.data, err = rs.loadRecords()
-	if err != nil {
-		return nil, fmt.Errorf("new bytimestamp: unable to load data: %v", err)
+	// Read the data from the underlying Recordset into a slice, parsing each
+	// timestamp as it is read so that malformed Records are handled by the
+	// ErrorPolicy of rs with their line numbers.
+	bt.data = new([]Record)
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("new bytimestamp: unable to load data: %v", err)
+		}
+		t, err := bt.h.ParseTime(*rec, timeIndex)
+		if err != nil {
+			if err := rs.reject(*rec, timeIndex, err); err != nil {
+				return nil, fmt.Errorf("new bytimestamp: %v", err)
+			}
+			continue
+		}
+		*bt.data = append(*bt.data, *rec)
+		bt.times = append(bt.times, t)
 	}
 
 	return bt, nil
@@ -621,23 +964,12 @@ func (bt ByTimestamp) Len() int { return len(*bt.data) }
 // Swap function to implement the sort.Interface.
 func (bt ByTimestamp) Swap(i, j int) {
 	(*bt.data)[i], (*bt.data)[j] = (*bt.data)[j], (*bt.data)[i]
+	bt.times[i], bt.times[j] = bt.times[j], bt.times[i]
 }
 
 //Less function to implement the sort.Interface.
 func (bt ByTimestamp) Less(i, j int) bool {
-	timeIndex, ok := bt.h.Contains("BaseDateTime")
-	if !ok {
-		panic("bytimestamp: less: headers does not contain BaseDateTime")
-	}
-	t1, err := time.Parse(TimeLayout, (*bt.data)[i][timeIndex])
-	if err != nil {
-		panic(err)
-	}
-	t2, err := time.Parse(TimeLayout, (*bt.data)[j][timeIndex])
-	if err != nil {
-		panic(err)
-	}
-	return t1.Before(t2)
+	return bt.times[i].Before(bt.times[j])
 }
 
 // Unexported loadRecords reads the RecordSet into memory and returns a
@@ -669,23 +1001,41 @@ type Headers struct {
 	// is called.
 	Fields []string
 
-	// DEPRECATED
-	// dictionary is a map[fieldname]description composed of string values
-	// usually created from a JSON file that contains
-	// Definition structs for each of the fields in the set of ais.Headers.
-	// dictionary map[string]string
+	// dictionary holds the Definition of each field keyed by fieldname.  It
+	// is usually created from a JSON file by RecordSet.SetDictionary or
+	// assigned with NewHeaders.
+	dictionary map[string]Definition
+
+	// schema maps canonical field names to the columns of a data source
+	// that does not use the MarineCadastre names.
+	schema *Schema
+
+	// timeLayout overrides the TimeLayout of the schema.  See
+	// RecordSet.SetTimeLayout.
+	timeLayout string
 }
 
 // Contains returns the index of a specific header.  This provides
 // a nice syntax ais.Headers().Contains("LAT") to ensure
-// an ais.Record contains a specific field.  If the Headers do not
-// contain the requested field ok is false.
+// an ais.Record contains a specific field.  When the Headers have a Schema
+// and field is not one of the Fields, the column the Schema maps field to
+// is returned instead.  If the Headers do not contain the requested field
+// ok is false.
 func (h Headers) Contains(field string) (i int, ok bool) {
 	for i, s := range h.Fields {
 		if s == field {
 			return i, true
 		}
 	}
+	if h.schema != nil {
+		if col, mapped := h.schema.Columns[field]; mapped {
+			for i, s := range h.Fields {
+				if s == col {
+					return i, true
+				}
+			}
+		}
+	}
 	return 0, false
 }
 
@@ -715,19 +1065,31 @@ func (h Headers) ContainsMulti(fields ...string) (idxMap map[string]HeaderMap, o
 }
 
 // String satisfies the fmt.Stringer interface for Headers.  It pretty prints
-// each index value and header, one line per header.
+// each index value and header, one line per header.  When the Headers have a
+// data dictionary the type, units and description of each header are printed
+// as well.
 func (h Headers) String() string {
 	const pad = ' ' //padding character for prety print
 
 	b := new(bytes.Buffer)
 	w := tabwriter.NewWriter(b, 0, 0, 2, pad, 0)
 
-	fmt.Fprintf(w, "Index\tHeader\n")
+	if h.dictionary == nil {
+		fmt.Fprintf(w, "Index\tHeader\n")
+	} else {
+		fmt.Fprintf(w, "Index\tHeader\tType\tUnits\tDefinition\n")
+	}
 
 	// For each header pretty print its name and index
 	for i, header := range h.Fields {
+		if h.dictionary == nil {
+			header = strings.TrimSpace(header)
+			fmt.Fprintf(w, "%d\t%s\n", i, header)
+			continue
+		}
+		d := h.dictionary[header]
 		header = strings.TrimSpace(header)
-		fmt.Fprintf(w, "%d\t%s\n", i, header)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i, header, d.Type, d.Units, d.Description)
 	}
 	w.Flush()
 
@@ -814,18 +1176,16 @@ func (r Record) ParseInt(index int) (int64, error) {
 	return i, nil
 }
 
-// ParseTime wraps time.Parse with a method to return a time.Time
-// from the index value of a field in the AIS Record.
-// Useful for converting the BaseDateTime from the Record.
-// NOTE: FUTURE VERSIONS OF THIS METHOD SHOULD NOT RELY ON A PACKAGE
-// CONSTANT FOR THE LAYOUT FIELD. THIS FIELD SHOULD BE INFERRED FROM
-// A LIST OF FORMATS SEEN IN COMMON DATASOURCES.
+// ParseTime returns a UTC time.Time from the index value of a field in the
+// AIS Record.  Useful for converting the BaseDateTime from the Record.  The
+// format of the timestamp is detected with ParseTimestamp, so ISO-8601 with
+// or without a zone, DMA and Unix epoch timestamps are all accepted.  Use
+// Headers.ParseTime to parse with the layout configured for a RecordSet.
 func (r Record) ParseTime(index int) (time.Time, error) {
-	t, err := time.Parse(TimeLayout, r[index])
-	if err != nil {
-		return time.Time{}, err
+	if t, err := time.Parse(TimeLayout, r[index]); err == nil {
+		return t, nil
 	}
-	return t, nil
+	return ParseTimestamp(r[index])
 }
 
 // Value returns the record value for the []string index. For out out bounds idx
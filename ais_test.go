@@ -6,6 +6,8 @@
 package ais
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
 	"io"
 	"reflect"
@@ -572,6 +574,89 @@ func TestOpenRecordSet(t *testing.T) {
 	}
 }
 
+func TestNewRecordSetFromReader(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(defaultHeadersString + "\n" + "477307901,2017-12-01T00:00:01,31.90512,-76.32652,0.0,131.0,352.0,FIRST,,,,,,,,\n"))
+	zw.Close()
+
+	tests := []struct {
+		name    string
+		r       io.Reader
+		want    int
+		wantErr bool
+	}{
+		{"csv", strings.NewReader("MMSI,BaseDateTime\n1,2017-12-01T00:00:01\n2,2017-12-01T00:00:02\n"), 2, false},
+		{"comment", strings.NewReader("# exported 2017-12-02\nMMSI,BaseDateTime\n1,2017-12-01T00:00:01\n"), 1, false},
+		{"gzip", &gz, 1, false},
+		{"empty", strings.NewReader(""), 0, true},
+		{"zip", strings.NewReader("PK\x03\x04 is not a stream"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRecordSetFromReader(tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRecordSetFromReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer rs.Close()
+			if _, ok := rs.Headers().Contains("BaseDateTime"); !ok {
+				t.Errorf("NewRecordSetFromReader() Headers = %v", rs.Headers().Fields)
+			}
+			if got := countRecords(t, rs); got != tt.want {
+				t.Errorf("NewRecordSetFromReader() read %d records, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// closeRecorder records that Close was called.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewRecordSetFromReader_Close(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("MMSI,BaseDateTime\n")}
+	rs, err := NewRecordSetFromReader(body)
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	// The RecordSet is read only, so written Records are not discarded silently.
+	if err := rs.Write(Record{"1", "2017-12-01T00:00:01"}); err != nil && err != ErrReadOnly {
+		t.Errorf("RecordSet.Write() error = %v, want nil or %v", err, ErrReadOnly)
+	}
+	if err := rs.Flush(); err != ErrReadOnly {
+		t.Errorf("RecordSet.Flush() error = %v, want %v", err, ErrReadOnly)
+	}
+	if err := rs.Close(); err != nil || !body.closed {
+		t.Errorf("RecordSet.Close() error = %v, closed %v", err, body.closed)
+	}
+}
+
+func TestRecordSet_WriteTo(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader("MMSI,VesselName\n1,\"ONE, TWO\"\n2,THREE\n"))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	var buf bytes.Buffer
+	n, err := rs.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("RecordSet.WriteTo() error = %v", err)
+	}
+	want := "MMSI,VesselName\n1,\"ONE, TWO\"\n2,THREE\n"
+	if buf.String() != want || n != int64(len(want)) {
+		t.Errorf("RecordSet.WriteTo() = %d, %q, want %d, %q", n, buf.String(), len(want), want)
+	}
+}
+
 func TestRecordSet_readFirst(t *testing.T) {
 	type fields struct {
 		r     *csv.Reader
@@ -901,7 +986,7 @@ func TestRecordSet_UniqueVessels(t *testing.T) {
 				rs.h = tt.fields.h
 			} else {
 				rec, _ := rs.Read()
-				rs.SetHeaders(Headers{[]string(*rec)})
+				rs.SetHeaders(Headers{Fields: []string(*rec)})
 			}
 			got, err := rs.UniqueVessels()
 			if (err != nil) != tt.wantErr {
@@ -960,7 +1045,7 @@ func TestRecordSet_UniqueVesselsMulti(t *testing.T) {
 				rs.h = tt.fields.h
 			} else {
 				rec, _ := rs.Read()
-				rs.SetHeaders(Headers{[]string(*rec)})
+				rs.SetHeaders(Headers{Fields: []string(*rec)})
 			}
 			got, _ := rs.UniqueVesselsMulti(true)
 			// got, _ = rs.UniqueVessels()
@@ -976,3 +1061,32 @@ func TestRecordSet_UniqueVesselsMulti(t *testing.T) {
 		})
 	}
 }
+
+func TestPrecisionGeohasher_Generate(t *testing.T) {
+	rec := Record(firstRec)
+	tests := []struct {
+		name    string
+		bits    PrecisionGeohasher
+		index   []int
+		want    Field
+		wantErr bool
+	}{
+		{"default precision matches Geohasher", PrecisionGeohasher(DefaultGeohashPrecision), []int{2, 3}, "0x1934e7", false},
+		{"too many bits", PrecisionGeohasher(65), []int{2, 3}, "", true},
+		{"zero bits", PrecisionGeohasher(0), []int{2, 3}, "", true},
+		{"wrong number of indices", PrecisionGeohasher(22), []int{2}, "", true},
+		{"unparsable lat", PrecisionGeohasher(22), []int{7, 3}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.bits.Generate(rec, tt.index...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PrecisionGeohasher.Generate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("PrecisionGeohasher.Generate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package ais
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// NewField describes a Field appended to every Record by AppendFields.  Gen
+// is called with the indices of the Required headers, which may include the
+// Name of a NewField that comes earlier in the same call.
+type NewField struct {
+	Name     string
+	Required []string
+	Gen      Generator
+}
+
+// appendBatch is the number of Records handed to a worker of AppendFields at
+// a time.
+const appendBatch = 64
+
+// AppendFields calls the Generator of each NewField on every Record in the
+// RecordSet and appends the resulting Fields in the order given.  Unlike a
+// chain of calls to AppendField, which buffers the complete data in memory once
+// for each new field, AppendFields computes all of the fields in one pass and
+// streams the Records to the returned *RecordSet as it is read, so only a
+// bounded number of Records are held in memory at any time.
+//
+// With workers greater than one the Generators are called concurrently from
+// that many goroutines and must be safe for concurrent use.  The Records keep
+// their original order.  Generators that depend on the order of the Records,
+// such as a KinematicChecker, must be used with one worker.
+//
+// Errors from missing headers are returned immediately.  Records that a
+// Generator returns an error for are handled by the ErrorPolicy of rs as in
+// AppendField.  An error that stops the run, such as from a Generator under
+// FailFast or from reading rs, is returned by the Read of the returned
+// RecordSet where io.EOF would be.  The returned RecordSet reads rs
+// concurrently, so rs must not be used again and the returned RecordSet
+// should be read to the end or closed.
+//
+//	rs2, err := rs.AppendFields(4,
+//		ais.NewField{Name: "Geohash", Required: []string{"LAT", "LON"}, Gen: ais.PrecisionGeohasher(20)},
+//		ais.NewField{Name: "Category", Required: []string{"VesselType"}, Gen: ais.VesselCategorizer{}},
+//	)
+func (rs *RecordSet) AppendFields(workers int, fields ...NewField) (*RecordSet, error) {
+	if _, _, err := appendGenerator(rs.Headers(), fields); err != nil {
+		return nil, err
+	}
+	return NewPipeline(context.Background(), rs).AppendFields(workers, fields...).RecordSet(), nil
+}
+
+// appendGenerator returns the Headers with the names of fields appended and
+// a function that appends the Fields generated for fields to a Record.
+func appendGenerator(h Headers, fields []NewField) (Headers, func(Record) (Record, error), error) {
+	h.Fields = h.Fields[:len(h.Fields):len(h.Fields)]
+	indices := make([][]int, len(fields))
+	for i, f := range fields {
+		for _, target := range f.Required {
+			index, ok := h.Contains(target)
+			if !ok {
+				return h, nil, fmt.Errorf("append: headers does not contain %s", target)
+			}
+			indices[i] = append(indices[i], index)
+		}
+		h.Fields = append(h.Fields, f.Name)
+	}
+	gen := func(rec Record) (Record, error) {
+		for i, f := range fields {
+			field, err := f.Gen.Generate(rec, indices[i]...)
+			if err != nil {
+				return nil, fmt.Errorf("appendfield: generate %s: %v", f.Name, err)
+			}
+			rec = append(rec, string(field))
+		}
+		return rec, nil
+	}
+	return h, gen, nil
+}
+
+// parallelStage returns a StageFunc that calls fn on batches of Records in
+// workers goroutines and sends the results on in their original order.  At
+// most twice as many batches as workers are in flight, so a slow consumer
+// holds back the reading of new Records.  A Record that fn returns an error
+// for is passed to reject in its original order, and the stage stops when
+// reject returns an error.
+func parallelStage(workers int, fn func(Record) (Record, error), reject func(Record, error) error) StageFunc {
+	type batch struct {
+		recs []Record
+		out  []Record
+		errs []error
+		done chan struct{}
+	}
+	return func(ctx context.Context, in <-chan Record, out chan<- Record) error {
+		ctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		jobs := make(chan *batch)
+		pending := make(chan *batch, 2*workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for b := range jobs {
+					b.out = make([]Record, len(b.recs))
+					b.errs = make([]error, len(b.recs))
+					for j, rec := range b.recs {
+						b.out[j], b.errs[j] = fn(rec)
+					}
+					close(b.done)
+				}
+			}()
+		}
+
+		// The dispatcher queues each batch in pending before handing it to a
+		// worker, so pending holds the batches in the order they were read.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(pending)
+			defer close(jobs)
+			b := &batch{done: make(chan struct{})}
+			dispatch := func() bool {
+				select {
+				case pending <- b:
+				case <-ctx.Done():
+					return false
+				}
+				select {
+				case jobs <- b:
+				case <-ctx.Done():
+					return false
+				}
+				b = &batch{done: make(chan struct{})}
+				return true
+			}
+			for {
+				select {
+				case rec, ok := <-in:
+					if !ok {
+						if len(b.recs) > 0 {
+							dispatch()
+						}
+						return
+					}
+					b.recs = append(b.recs, rec)
+					// Dispatch a partial batch when no more Records are
+					// waiting so that a slow source is not held up.
+					if (len(b.recs) == appendBatch || len(in) == 0) && !dispatch() {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for b := range pending {
+			select {
+			case <-b.done:
+			case <-ctx.Done():
+				return nil
+			}
+			for j, rec := range b.out {
+				if b.errs[j] != nil {
+					if err := reject(b.recs[j], b.errs[j]); err != nil {
+						return err
+					}
+					continue
+				}
+				if !send(ctx, out, rec) {
+					return nil
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package ais
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// numberedRecordSet returns a MemRecordSet of n Records whose MMSI is the
+// index of the Record.
+func numberedRecordSet(n int) *MemRecordSet {
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+	m := NewMemRecordSet(h)
+	for i := 0; i < n; i++ {
+		lat := strconv.FormatFloat(30+float64(i%100)/10, 'f', 1, 64)
+		m.Append(Record{strconv.Itoa(i), "2017-12-01T00:00:01", lat, "-76.5"})
+	}
+	return m
+}
+
+// concatGenerator joins the values at each index with a colon.
+type concatGenerator struct{}
+
+func (concatGenerator) Generate(rec Record, index ...int) (Field, error) {
+	s := ""
+	for _, i := range index {
+		s += ":" + rec[i]
+	}
+	return Field(s), nil
+}
+
+// failGenerator returns an error for the Record whose MMSI is the int value.
+type failGenerator int
+
+func (f failGenerator) Generate(rec Record, index ...int) (Field, error) {
+	if rec[0] == strconv.Itoa(int(f)) {
+		return "", fmt.Errorf("bad record %s", rec[0])
+	}
+	return "", nil
+}
+
+func TestRecordSet_AppendFields(t *testing.T) {
+	const n = 1000
+	fields := []NewField{
+		{Name: "Geohash", Required: []string{"LAT", "LON"}, Gen: PrecisionGeohasher(DefaultGeohashPrecision)},
+		{Name: "Flag", Required: []string{"MMSI"}, Gen: FlagGenerator{}},
+		{Name: "Both", Required: []string{"MMSI", "Geohash"}, Gen: concatGenerator{}},
+	}
+
+	// The Records from chained calls to AppendField are the expected result.
+	want := numberedRecordSet(n).RecordSet()
+	var err error
+	for _, f := range fields {
+		if want, err = want.AppendField(f.Name, f.Required, f.Gen); err != nil {
+			t.Fatalf("RecordSet.AppendField() error = %v", err)
+		}
+	}
+	wantRecs := readAll(t, want)
+
+	for _, workers := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			rs2, err := numberedRecordSet(n).RecordSet().AppendFields(workers, fields...)
+			if err != nil {
+				t.Fatalf("RecordSet.AppendFields() error = %v", err)
+			}
+			defer rs2.Close()
+			if !rs2.Headers().Equals(want.Headers()) {
+				t.Errorf("Headers = %v, want %v", rs2.Headers().Fields, want.Headers().Fields)
+			}
+			if got := readAll(t, rs2); !reflect.DeepEqual(got, wantRecs) {
+				t.Errorf("AppendFields() Records differ from chained AppendField")
+			}
+		})
+	}
+}
+
+func TestRecordSet_AppendFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		fields  []NewField
+		wantErr bool // error from AppendFields rather than from Read
+	}{
+		{"missing header", 4, []NewField{{Name: "Tag", Required: []string{"Latitude"}, Gen: tagGenerator{}}}, true},
+		{"later field", 4, []NewField{
+			{Name: "Both", Required: []string{"Tag"}, Gen: concatGenerator{}},
+			{Name: "Tag", Gen: tagGenerator{}},
+		}, true},
+		{"generate serial", 1, []NewField{{Name: "Fail", Gen: failGenerator(700)}}, false},
+		{"generate parallel", 4, []NewField{{Name: "Fail", Gen: failGenerator(700)}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs2, err := numberedRecordSet(1000).RecordSet().AppendFields(tt.workers, tt.fields...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecordSet.AppendFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer rs2.Close()
+			n := 0
+			for {
+				_, err = rs2.Read()
+				if err != nil {
+					break
+				}
+				n++
+			}
+			if err == io.EOF || n != 700 {
+				t.Errorf("read %d records and error %v, want 700 records and an error", n, err)
+			}
+		})
+	}
+}
@@ -40,13 +40,39 @@ type ClusterMap map[uint64]*Cluster
 // into common Clusters that share the same geohash.  It requires that
 // the RecordSet Window it is operating on has a 'Geohash' field stored as
 // a Uint64 with the proper prefix for the hash (i.e. 0x for hex representation).
+// Records whose geohash cannot be parsed are left out of the ClusterMap.  Use
+// Clusters to handle them with the ErrorPolicy of the Window.
 func (win *Window) FindClusters(geohashIndex int) ClusterMap {
+	cm, _ := win.clusters(geohashIndex, true)
+	return cm
+}
+
+// Clusters is FindClusters with the ErrorPolicy of the Window applied to
+// Records whose geohash cannot be parsed.  Under FailFast it returns nil and a
+// *RecordError for the first one.
+func (win *Window) Clusters(geohashIndex int) (ClusterMap, error) {
+	return win.clusters(geohashIndex, false)
+}
+
+// clusters groups the Records of the Window by geohash.  When skip is true
+// malformed Records are left out whatever the ErrorPolicy.
+func (win *Window) clusters(geohashIndex int, skip bool) (ClusterMap, error) {
 	cm := make(ClusterMap)
-	for _, rec := range win.Data {
-		geoString := (*rec)[geohashIndex]
+	for _, rec := range win.snapshotRecords() {
+		geoString, ok := rec.Value(geohashIndex)
 		geohash, err := strconv.ParseUint(geoString, 0, 64)
-		if err != nil {
-			panic(err)
+		if !ok || err != nil {
+			if !ok {
+				err = fmt.Errorf("index %d out of range", geohashIndex)
+			}
+			if skip {
+				continue
+			}
+			re := &RecordError{Column: geohashIndex, Record: *rec, Err: fmt.Errorf("geohash: %v", err)}
+			if err := handleReject(win.policy, win.sink, &win.skipped, re); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		if cluster, ok := cm[geohash]; ok {
 			cluster.Append(rec)
@@ -58,5 +84,5 @@ func (win *Window) FindClusters(geohashIndex int) ClusterMap {
 			}(rec)
 		}
 	}
-	return cm
+	return cm, nil
 }
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FATHOM5/ais"
+)
+
+func runSubset(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("subset")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output csv `file`, - for standard output (required)")
+	limit := fs.Int("limit", -1, "stop after `n` matches, negative values keep all matches")
+	var ff filterFlags
+	ff.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	m, err := ff.matcher(rs.Headers())
+	if err != nil {
+		return err
+	}
+	matches, err := rs.SubsetLimit(m, *limit, false)
+	if err == ais.ErrEmptySet {
+		return fmt.Errorf("no records matched the filters")
+	}
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(matches, &err)
+	return saveOutput(matches, *out, stdout)
+}
+
+func runSort(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("sort")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output csv `file`, - for standard output (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	sorted, err := rs.SortByTime()
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(sorted, &err)
+	return saveOutput(sorted, *out, stdout)
+}
+
+func runGeohash(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("geohash")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output csv `file`, - for standard output (required)")
+	precision := fs.Uint("precision", ais.DefaultGeohashPrecision, "geohash precision in `bits` (1-64)")
+	field := fs.String("field", "Geohash", "`name` of the appended field")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	rs2, err := rs.AppendField(*field, []string{"LAT", "LON"}, ais.PrecisionGeohasher(*precision))
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs2, &err)
+	return saveOutput(rs2, *out, stdout)
+}
+
+func runInteractions(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("interactions")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output csv `file` for the interactions (required)")
+	width := fs.Duration("width", 10*time.Minute, "width of the sliding Window")
+	slide := fs.Duration("slide", 5*time.Minute, "amount the Window slides after each step")
+	precision := fs.Uint("precision", ais.DefaultGeohashPrecision, "geohash precision in `bits` when the input has no geohash field")
+	field := fs.String("field", "Geohash", "`name` of the geohash field, appended when missing")
+	sorted := fs.Bool("sorted", false, "input is already sorted by BaseDateTime")
+	session := fs.Duration("session", 0, "use session windows that close after a `gap` with no records in place of -width and -slide")
+	maxSlides := fs.Int("maxslides", -1, "stop after `n` windows, negative values slide over the full file")
+	snapshot := fs.String("snapshot", "all", "reports of each vessel compared in a window: all, latest or interpolated")
+	var ff filterFlags
+	ff.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+	if *width <= 0 || *slide <= 0 {
+		return fmt.Errorf("width and slide must be positive durations")
+	}
+	mode, err := parseSnapshot(*snapshot)
+	if err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	if ff.active() {
+		var m ais.Matching
+		if m, err = ff.matcher(rs.Headers()); err != nil {
+			return err
+		}
+		rs, err = rs.Subset(m)
+		if err == ais.ErrEmptySet {
+			return fmt.Errorf("no records matched the filters")
+		}
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	if _, ok := rs.Headers().Contains(*field); !ok {
+		rs, err = rs.AppendField(*field, []string{"LAT", "LON"}, ais.PrecisionGeohasher(*precision))
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	if !*sorted {
+		rs, err = rs.SortByTime()
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	win, err := ais.NewWindow(rs, *width)
+	if err != nil {
+		return err
+	}
+	if err := win.SetSnapshot(mode); err != nil {
+		return err
+	}
+	inter, err := ais.NewInteractions(rs.Headers())
+	if err != nil {
+		return err
+	}
+	geoIndex, _ := rs.Headers().Contains(*field)
+
+	windows := 0
+	search := func(win *ais.Window) error {
+		for _, cluster := range win.FindClusters(geoIndex) {
+			if cluster.Size() > 1 {
+				if err := inter.AddCluster(cluster); err != nil {
+					return err
+				}
+			}
+		}
+		if windows++; windows == *maxSlides {
+			return ais.ErrStopRun
+		}
+		return nil
+	}
+	switch {
+	case *maxSlides == 0:
+	case *session > 0:
+		err = win.RunSessions(rs, *session, search)
+	default:
+		err = win.Run(rs, *slide, search)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "found %d interactions\n", inter.Len())
+	return inter.Save(*out)
+}
+
+// parseSnapshot returns the ais.SnapshotMode named by s.
+func parseSnapshot(s string) (ais.SnapshotMode, error) {
+	for _, mode := range []ais.SnapshotMode{ais.AllReports, ais.LatestReports, ais.InterpolatedReports} {
+		if s == mode.String() {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown snapshot %q, want all, latest or interpolated", s)
+}
+
+func runDensity(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("density")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output `file` with extension .csv, .geojson, .asc or .png, - for csv on standard output (required)")
+	grid := fs.String("grid", "", "lat/lon grid `minLat,maxLat,minLon,maxLon` with cells of -size degrees")
+	size := fs.Float64("size", 0.01, "cell size in `degrees` of a -grid")
+	geohashBits := fs.Uint("geohash", 0, "use geohash cells with this precision in `bits` in place of -grid")
+	stat := fs.String("stat", "reports", "statistic written to .asc and .png files: reports, vessels or hours")
+	category := fs.Bool("category", false, "count each vessel category separately as well as in total")
+	only := fs.String("only", "", "write the .asc or .png raster for this vessel `category` in place of the total")
+	maxGap := fs.Duration("maxgap", ais.DefaultDensityGap, "longest time between reports counted as vessel-hours")
+	sorted := fs.Bool("sorted", false, "input is already sorted by BaseDateTime")
+	var ff filterFlags
+	ff.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+	var g *ais.Grid
+	switch {
+	case *geohashBits > 0 && *grid != "":
+		return fmt.Errorf("use only one of -grid and -geohash")
+	case *geohashBits > 0:
+		g, err = ais.NewGeohashGrid(*geohashBits)
+	case *grid != "":
+		var b [4]float64
+		if b, err = parseBounds(*grid); err != nil {
+			return fmt.Errorf("grid: %v", err)
+		}
+		g, err = ais.NewGrid(b[0], b[1], b[2], b[3], *size)
+	default:
+		return fmt.Errorf("missing required flag -grid or -geohash")
+	}
+	if err != nil {
+		return err
+	}
+	densityStat, err := parseDensityStat(*stat)
+	if err != nil {
+		return err
+	}
+	write, err := densityWriter(*out, densityStat, *only)
+	if err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	if ff.active() {
+		var m ais.Matching
+		if m, err = ff.matcher(rs.Headers()); err != nil {
+			return err
+		}
+		rs, err = rs.Subset(m)
+		if err == ais.ErrEmptySet {
+			return fmt.Errorf("no records matched the filters")
+		}
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+	// Vessel-hours are counted between consecutive reports of each vessel.
+	if !*sorted {
+		rs, err = rs.SortByTime()
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	opts := ais.DensityOptions{MaxGap: *maxGap}
+	if *category {
+		opts.Category = &ais.NewField{Name: "Category", Required: []string{"VesselType"}, Gen: ais.VesselCategorizer{}}
+	}
+	d, err := rs.Density(g, opts)
+	if err != nil {
+		return err
+	}
+	if *out == "-" {
+		return d.WriteCSV(stdout)
+	}
+	fmt.Fprintf(stdout, "counted %d cells\n", len(d.Cells()))
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := write(d, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseDensityStat returns the ais.DensityStat named by s.
+func parseDensityStat(s string) (ais.DensityStat, error) {
+	for _, stat := range []ais.DensityStat{ais.DensityReports, ais.DensityVessels, ais.DensityVesselHours} {
+		if s == stat.String() {
+			return stat, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown stat %q, want reports, vessels or hours", s)
+}
+
+// densityWriter returns the function that writes a Density in the format
+// given by the extension of the file name.
+func densityWriter(name string, stat ais.DensityStat, category string) (func(*ais.Density, io.Writer) error, error) {
+	switch filepath.Ext(name) {
+	case ".csv", "":
+		return (*ais.Density).WriteCSV, nil
+	case ".geojson", ".json":
+		return (*ais.Density).WriteGeoJSON, nil
+	case ".asc":
+		return func(d *ais.Density, w io.Writer) error { return d.WriteASCIIGrid(w, stat, category) }, nil
+	case ".png":
+		return func(d *ais.Density, w io.Writer) error { return d.WritePNG(w, stat, category) }, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, want .csv, .geojson, .asc or .png", filepath.Ext(name))
+}
+
+func runTracks(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("tracks")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	out := fs.String("out", "", "output `file` with extension .geojson or .kml, - for GeoJSON on standard output (required)")
+	var ff filterFlags
+	ff.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+	if err := requireFlag("out", *out); err != nil {
+		return err
+	}
+	var write func(*ais.RecordSet, io.Writer) error
+	switch filepath.Ext(*out) {
+	case ais.GeoJSONExt, ".json", "":
+		write = (*ais.RecordSet).WriteTracksGeoJSON
+	case ais.KMLExt:
+		write = (*ais.RecordSet).WriteTracksKML
+	default:
+		return fmt.Errorf("unknown output format %q, want .geojson or .kml", filepath.Ext(*out))
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	if ff.active() {
+		var m ais.Matching
+		if m, err = ff.matcher(rs.Headers()); err != nil {
+			return err
+		}
+		rs, err = rs.Subset(m)
+		if err == ais.ErrEmptySet {
+			return fmt.Errorf("no records matched the filters")
+		}
+		if err != nil {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	if *out == "-" {
+		return write(rs, stdout)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := write(rs, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runVessels(args []string, stdout io.Writer) (err error) {
+	fs := newFlagSet("vessels")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	var ff filterFlags
+	ff.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer closeRecordSet(rs, &err)
+
+	if ff.active() {
+		var m ais.Matching
+		if m, err = ff.matcher(rs.Headers()); err != nil {
+			return err
+		}
+		rs, err = rs.Subset(m)
+		if err != nil && err != ais.ErrEmptySet {
+			return err
+		}
+		defer closeRecordSet(rs, &err)
+	}
+
+	vs, err := rs.UniqueVessels()
+	if err != nil {
+		return err
+	}
+
+	vessels := make([]ais.Vessel, 0, len(vs))
+	for v := range vs {
+		vessels = append(vessels, v)
+	}
+	sort.Slice(vessels, func(i, j int) bool {
+		if vs[vessels[i]] != vs[vessels[j]] {
+			return vs[vessels[i]] > vs[vessels[j]]
+		}
+		return vessels[i].MMSI < vessels[j].MMSI
+	})
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "MMSI\tVesselName\tRecords\n")
+	for _, v := range vessels {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", v.MMSI, v.VesselName, vs[v])
+	}
+	return w.Flush()
+}
+
+func runStats(args []string, stdout io.Writer) error {
+	fs := newFlagSet("stats")
+	in := fs.String("in", "", "input csv `file`, - for standard input (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("in", *in); err != nil {
+		return err
+	}
+
+	rs, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	st, err := collectStats(rs)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Fields\t%d\n", len(rs.Headers().Fields))
+	fmt.Fprintf(w, "Records\t%d\n", st.records)
+	fmt.Fprintf(w, "Unique MMSI\t%d\n", len(st.mmsi))
+	if !st.first.IsZero() {
+		fmt.Fprintf(w, "First report\t%s\n", st.first.Format(ais.TimeLayout))
+		fmt.Fprintf(w, "Last report\t%s\n", st.last.Format(ais.TimeLayout))
+	}
+	if st.positions > 0 {
+		fmt.Fprintf(w, "Latitude\t%.5f to %.5f\n", st.minLat, st.maxLat)
+		fmt.Fprintf(w, "Longitude\t%.5f to %.5f\n", st.minLon, st.maxLon)
+	}
+	fmt.Fprintf(w, "Unparsable times\t%d\n", st.badTimes)
+	fmt.Fprintf(w, "Unparsable positions\t%d\n", st.badPositions)
+	return w.Flush()
+}
+
+// stats is the summary computed by the stats command.
+type stats struct {
+	records                        int
+	mmsi                           map[string]bool
+	first, last                    time.Time
+	positions                      int
+	minLat, maxLat, minLon, maxLon float64
+	badTimes, badPositions         int
+}
+
+// collectStats makes a single pass over rs.  Fields that are not present in
+// the Headers are skipped rather than treated as an error.
+func collectStats(rs *ais.RecordSet) (*stats, error) {
+	st := &stats{mmsi: make(map[string]bool)}
+	h := rs.Headers()
+	mmsiIndex, okMMSI := h.Contains("MMSI")
+	timeIndex, okTime := h.Contains("BaseDateTime")
+	latIndex, okLat := h.Contains("LAT")
+	lonIndex, okLon := h.Contains("LON")
+
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		st.records++
+
+		if okMMSI {
+			st.mmsi[(*rec)[mmsiIndex]] = true
+		}
+		if okTime {
+			t, err := h.ParseTime(*rec, timeIndex)
+			if err != nil {
+				st.badTimes++
+			} else {
+				if st.first.IsZero() || t.Before(st.first) {
+					st.first = t
+				}
+				if t.After(st.last) {
+					st.last = t
+				}
+			}
+		}
+		if okLat && okLon {
+			lat, errLat := rec.ParseFloat(latIndex)
+			lon, errLon := rec.ParseFloat(lonIndex)
+			if errLat != nil || errLon != nil {
+				st.badPositions++
+				continue
+			}
+			if st.positions == 0 {
+				st.minLat, st.maxLat, st.minLon, st.maxLon = lat, lat, lon, lon
+			}
+			if lat < st.minLat {
+				st.minLat = lat
+			}
+			if lat > st.maxLat {
+				st.maxLat = lat
+			}
+			if lon < st.minLon {
+				st.minLon = lon
+			}
+			if lon > st.maxLon {
+				st.maxLon = lon
+			}
+			st.positions++
+		}
+	}
+	return st, nil
+}
+
+// openInput opens the RecordSet named by an -in flag.  The name "-" reads
+// standard input.
+func openInput(name string) (*ais.RecordSet, error) {
+	if name == "-" {
+		return ais.NewRecordSetFromReader(os.Stdin)
+	}
+	return ais.OpenRecordSet(name)
+}
+
+// closeRecordSet closes rs and returns the error of Close through err when the
+// command has not already failed.
+func closeRecordSet(rs *ais.RecordSet, err *error) {
+	if cerr := rs.Close(); cerr != nil && *err == nil {
+		*err = cerr
+	}
+}
+
+// saveOutput saves rs to the file named by an -out flag.  The name "-" writes
+// csv to stdout.
+func saveOutput(rs *ais.RecordSet, name string, stdout io.Writer) error {
+	if name == "-" {
+		_, err := rs.WriteTo(stdout)
+		return err
+	}
+	return rs.Save(name)
+}
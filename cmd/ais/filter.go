@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FATHOM5/ais"
+)
+
+// filterFlags holds the command line values shared by the commands that
+// can restrict the records they operate on.
+type filterFlags struct {
+	box   string
+	start string
+	end   string
+	mmsi  string
+	ships bool
+}
+
+// register adds the filter flags to fs.
+func (ff *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&ff.box, "box", "", "geographic filter `minLat,maxLat,minLon,maxLon`")
+	fs.StringVar(&ff.start, "start", "", "keep records at or after this time (2006-01-02 or an ISO-8601 timestamp)")
+	fs.StringVar(&ff.end, "end", "", "keep records before this time (2006-01-02 or an ISO-8601 timestamp)")
+	fs.StringVar(&ff.mmsi, "mmsi", "", "comma separated list of MMSI to keep")
+	fs.BoolVar(&ff.ships, "ships", false, "keep only records from ship stations, dropping AtoN, base stations and SAR aircraft")
+}
+
+// active reports whether any filter flag was provided.
+func (ff *filterFlags) active() bool {
+	return ff.box != "" || ff.start != "" || ff.end != "" || ff.mmsi != "" || ff.ships
+}
+
+// matcher builds an ais.Matching from the filter flags using the Headers
+// of the RecordSet that will be filtered.
+func (ff *filterFlags) matcher(h ais.Headers) (ais.Matching, error) {
+	var all ais.MatchAll
+
+	if ff.box != "" {
+		idxMap, ok := h.ContainsMulti("LAT", "LON")
+		if !ok {
+			return nil, fmt.Errorf("box filter: headers do not contain LAT and LON")
+		}
+		bounds, err := parseBounds(ff.box)
+		if err != nil {
+			return nil, fmt.Errorf("box filter: %v", err)
+		}
+		all = append(all, &ais.Box{
+			MinLat:   bounds[0],
+			MaxLat:   bounds[1],
+			MinLon:   bounds[2],
+			MaxLon:   bounds[3],
+			LatIndex: idxMap["LAT"].Idx,
+			LonIndex: idxMap["LON"].Idx,
+		})
+	}
+
+	if ff.start != "" || ff.end != "" {
+		var start, end time.Time
+		var err error
+		if ff.start != "" {
+			if start, err = parseFlagTime(ff.start); err != nil {
+				return nil, fmt.Errorf("time filter: %v", err)
+			}
+		}
+		if ff.end != "" {
+			if end, err = parseFlagTime(ff.end); err != nil {
+				return nil, fmt.Errorf("time filter: %v", err)
+			}
+		}
+		tr, err := ais.NewTimeRange(h, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("time filter: %v", err)
+		}
+		all = append(all, tr)
+	}
+
+	if ff.mmsi != "" {
+		mmsiIndex, ok := h.Contains("MMSI")
+		if !ok {
+			return nil, fmt.Errorf("mmsi filter: headers do not contain MMSI")
+		}
+		ml := &mmsiList{mmsiIndex: mmsiIndex, set: make(map[string]bool)}
+		for _, m := range strings.Split(ff.mmsi, ",") {
+			ml.set[strings.TrimSpace(m)] = true
+		}
+		all = append(all, ml)
+	}
+
+	if ff.ships {
+		mmsiIndex, ok := h.Contains("MMSI")
+		if !ok {
+			return nil, fmt.Errorf("ships filter: headers do not contain MMSI")
+		}
+		all = append(all, &ais.ShipStationMatcher{MMSIIndex: mmsiIndex})
+	}
+
+	return all, nil
+}
+
+// parseBounds parses a minLat,maxLat,minLon,maxLon flag value.
+func parseBounds(s string) ([4]float64, error) {
+	var bounds [4]float64
+	vals := strings.Split(s, ",")
+	if len(vals) != 4 {
+		return bounds, fmt.Errorf("want minLat,maxLat,minLon,maxLon got %q", s)
+	}
+	for i, v := range vals {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return bounds, err
+		}
+		bounds[i] = f
+	}
+	return bounds, nil
+}
+
+// parseFlagTime accepts either a timestamp in one of the formats detected by
+// ais.ParseTimestamp or a date.
+func parseFlagTime(s string) (time.Time, error) {
+	if t, err := ais.ParseTimestamp(s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// mmsiList implements ais.Matching for records from a set of vessels.
+type mmsiList struct {
+	mmsiIndex int
+	set       map[string]bool
+}
+
+func (ml *mmsiList) Match(rec *ais.Record) (bool, error) {
+	return ml.set[(*rec)[ml.mmsiIndex]], nil
+}
@@ -0,0 +1,110 @@
+// Command ais wraps the most common package ais workflows in a single
+// command line tool so that subsets, sorting, geohashing, two-vessel
+// interaction searches, traffic density grids and vessel tracks can be run
+// without writing a new main program.
+//
+// Usage:
+//
+//	ais <command> [flags]
+//
+// The commands are:
+//
+//	subset        write the records that match a box, time range or MMSI list
+//	sort          sort a file chronologically by BaseDateTime
+//	geohash       append a Geohash field to every record
+//	interactions  find two-vessel interactions with a sliding Window
+//	density       count reports, vessels and vessel-hours in the cells of a grid
+//	tracks        write the track of each vessel as GeoJSON or KML
+//	vessels       list the unique vessels in a file
+//	stats         print summary statistics for a file
+//
+// Run 'ais <command> -h' for the flags of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	_ "github.com/FATHOM5/ais/parquet"
+	_ "github.com/FATHOM5/ais/zstd"
+)
+
+// command is a single subcommand of the ais tool.
+type command struct {
+	name  string
+	short string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"subset", "write the records that match a box, time range or MMSI list", runSubset},
+	{"sort", "sort a file chronologically by BaseDateTime", runSort},
+	{"geohash", "append a Geohash field to every record", runGeohash},
+	{"interactions", "find two-vessel interactions with a sliding Window", runInteractions},
+	{"density", "count reports, vessels and vessel-hours in the cells of a grid", runDensity},
+	{"tracks", "write the track of each vessel as GeoJSON or KML", runTracks},
+	{"vessels", "list the unique vessels in a file", runVessels},
+	{"stats", "print summary statistics for a file", runStats},
+}
+
+// errUsage is returned when the command line could not be parsed and the
+// usage message has already been printed.
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if err == errUsage || err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ais: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches args to the matching subcommand.  It is separated from main
+// so that the commands can be tested without spawning a process.
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) < 1 {
+		usage(stderr)
+		return errUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout)
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return nil
+	}
+	fmt.Fprintf(stderr, "ais: unknown command %q\n", args[0])
+	usage(stderr)
+	return errUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage:\n\n\tais <command> [flags]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%-13s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintf(w, "\nRun 'ais <command> -h' for the flags of each command.\n")
+}
+
+// newFlagSet returns a FlagSet for the named command that reports its own
+// parse errors instead of exiting the process.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("ais "+name, flag.ContinueOnError)
+	return fs
+}
+
+// requireFlag returns an error if the string flag value is empty.
+func requireFlag(name, val string) error {
+	if val == "" {
+		return fmt.Errorf("missing required flag -%s", name)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tenFile = "../../testdata/ten.csv"
+
+// closeShips has two vessels in the same geohash one minute apart and a
+// third vessel far away.
+const closeShips = `MMSI,BaseDateTime,LAT,LON,SOG,COG,Heading,VesselName,IMO,CallSign,VesselType,Status,Length,Width,Draft,Cargo
+477307901,2017-12-01T00:00:01,36.90512,-76.32652,0.0,131.0,352.0,FIRST,IMO9739666,VRPJ6,1004,moored,337,,,
+338029922,2017-12-01T00:01:01,36.90612,-76.32552,7.7,110.6,511.0,SECOND,,,,,,,,
+369080003,2017-12-01T00:02:03,43.60792,-74.20417,4.1,1.0,5.0,THIRD,IMO9795933,WDI7248,1025,under way using engine,,,,
+`
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "aiscmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func countLines(t *testing.T, filename string) int {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+func TestRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	shipsFile := filepath.Join(dir, "ships.csv")
+	if err := ioutil.WriteFile(shipsFile, []byte(closeShips), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantOut   string // substring expected in stdout
+		wantLines int    // lines in the file named by -out, including headers
+		wantErr   bool
+	}{
+		{
+			name:    "no command",
+			args:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"convolve"},
+			wantErr: true,
+		},
+		{
+			name:    "missing -in",
+			args:    []string{"stats"},
+			wantErr: true,
+		},
+		{
+			name:    "stats",
+			args:    []string{"stats", "-in", tenFile},
+			wantOut: "Unique MMSI           10",
+		},
+		{
+			name:    "vessels with mmsi filter",
+			args:    []string{"vessels", "-in", tenFile, "-mmsi", "477307901"},
+			wantOut: "477307901  FIRST",
+		},
+		{
+			name:      "subset by box",
+			args:      []string{"subset", "-in", tenFile, "-out", filepath.Join(dir, "box.csv"), "-box", "30,35,-77,-76"},
+			wantLines: 4,
+		},
+		{
+			name:      "subset by time",
+			args:      []string{"subset", "-in", tenFile, "-out", filepath.Join(dir, "day.csv"), "-start", "2017-12-25"},
+			wantLines: 2,
+		},
+		{
+			name:      "subset ship stations",
+			args:      []string{"subset", "-in", tenFile, "-out", filepath.Join(dir, "shipstations.csv"), "-ships"},
+			wantLines: 11,
+		},
+		{
+			name:    "subset bad box",
+			args:    []string{"subset", "-in", tenFile, "-out", filepath.Join(dir, "bad.csv"), "-box", "30,35"},
+			wantErr: true,
+		},
+		{
+			name: "subset to parquet",
+			args: []string{"subset", "-in", tenFile, "-out", filepath.Join(dir, "day.parquet"), "-start", "2017-12-25"},
+		},
+		{
+			name:    "subset without matches",
+			args:    []string{"subset", "-in", tenFile, "-out", "-", "-start", "2018-01-01"},
+			wantErr: true,
+		},
+		{
+			name:    "subset to stdout",
+			args:    []string{"subset", "-in", tenFile, "-out", "-", "-start", "2017-12-25"},
+			wantOut: "MMSI,BaseDateTime,LAT,LON,SOG,COG,Heading,VesselName,IMO,CallSign,VesselType,Status,Length,Width,Draft,Cargo\n367180910,2017-12-25",
+		},
+		{
+			name:      "sort",
+			args:      []string{"sort", "-in", tenFile, "-out", filepath.Join(dir, "sorted.csv")},
+			wantLines: 11,
+		},
+		{
+			name:      "geohash",
+			args:      []string{"geohash", "-in", tenFile, "-out", filepath.Join(dir, "geo.csv"), "-precision", "30"},
+			wantLines: 11,
+		},
+		{
+			name:    "geohash bad precision",
+			args:    []string{"geohash", "-in", tenFile, "-out", filepath.Join(dir, "geo2.csv"), "-precision", "65"},
+			wantErr: true,
+		},
+		{
+			name:      "interactions",
+			args:      []string{"interactions", "-in", shipsFile, "-out", filepath.Join(dir, "inter.csv"), "-width", "2m", "-slide", "1m"},
+			wantOut:   "found 1 interactions",
+			wantLines: 2,
+		},
+		{
+			name:      "interactions in sessions",
+			args:      []string{"interactions", "-in", shipsFile, "-out", filepath.Join(dir, "session.csv"), "-session", "2m"},
+			wantOut:   "found 1 interactions",
+			wantLines: 2,
+		},
+		{
+			name:      "interactions latest reports",
+			args:      []string{"interactions", "-in", shipsFile, "-out", filepath.Join(dir, "latest.csv"), "-width", "2m", "-slide", "1m", "-snapshot", "latest"},
+			wantOut:   "found 1 interactions",
+			wantLines: 2,
+		},
+		{
+			name:      "density",
+			args:      []string{"density", "-in", tenFile, "-out", filepath.Join(dir, "density.csv"), "-geohash", "10"},
+			wantOut:   "counted 4 cells",
+			wantLines: 5,
+		},
+		{
+			name:    "density categories to stdout",
+			args:    []string{"density", "-in", tenFile, "-out", "-", "-grid", "20,50,-130,-60", "-size", "10", "-category"},
+			wantOut: "Tug,2,5,40,-80,50,-70,3,3,0.000",
+		},
+		{
+			name:    "density png",
+			args:    []string{"density", "-in", tenFile, "-out", filepath.Join(dir, "density.png"), "-grid", "20,50,-130,-60", "-size", "1", "-stat", "vessels"},
+			wantOut: "counted 10 cells",
+		},
+		{
+			name:    "density without grid",
+			args:    []string{"density", "-in", tenFile, "-out", filepath.Join(dir, "density.geojson")},
+			wantErr: true,
+		},
+		{
+			name:    "density bad format",
+			args:    []string{"density", "-in", tenFile, "-out", filepath.Join(dir, "density.tif"), "-geohash", "10"},
+			wantErr: true,
+		},
+		{
+			name:      "interactions geojson",
+			args:      []string{"interactions", "-in", shipsFile, "-out", filepath.Join(dir, "inter.geojson"), "-width", "2m", "-slide", "1m"},
+			wantOut:   "found 1 interactions",
+			wantLines: 1,
+		},
+		{
+			name:    "tracks to stdout",
+			args:    []string{"tracks", "-in", tenFile, "-out", "-", "-start", "2017-12-25"},
+			wantOut: `{"type":"FeatureCollection","features":[{"type":"Feature"`,
+		},
+		{
+			name: "tracks kml",
+			args: []string{"tracks", "-in", tenFile, "-out", filepath.Join(dir, "tracks.kml")},
+		},
+		{
+			name:    "tracks bad format",
+			args:    []string{"tracks", "-in", tenFile, "-out", filepath.Join(dir, "tracks.shp")},
+			wantErr: true,
+		},
+		{
+			name:    "interactions bad snapshot",
+			args:    []string{"interactions", "-in", shipsFile, "-out", filepath.Join(dir, "bad.csv"), "-snapshot", "first"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(tt.args, &stdout, &stderr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("run() stdout = \n%v\nwant it to contain %q", stdout.String(), tt.wantOut)
+			}
+			if tt.wantLines == 0 {
+				return
+			}
+			out := tt.args[len(tt.args)-1]
+			for i, a := range tt.args {
+				if a == "-out" {
+					out = tt.args[i+1]
+				}
+			}
+			if got := countLines(t, out); got != tt.wantLines {
+				t.Errorf("run() wrote %d lines to %s, want %d", got, out, tt.wantLines)
+			}
+		})
+	}
+}
@@ -0,0 +1,640 @@
+package ais
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnarExt is the filename extension that RecordSet.Save uses to select the
+// columnar format.  OpenRecordSet detects columnar files from their content, so
+// a columnar file can be opened under any name.
+//
+// The columnar format stores Records in blocks.  Each column of a block is
+// encoded on its own as integers, decimals, MarineCadastre timestamps or a
+// dictionary of distinct strings, whichever reproduces every value exactly.
+// Each block also holds the minimum and maximum BaseDateTime and the bounding
+// box of LAT and LON of its Records.  When a *Box, *TimeRange or MatchAll of
+// them is passed to Subset on a columnar RecordSet, blocks whose statistics
+// rule out a match are skipped without being decoded.
+//
+//	rs.Save("dec2017.aisc")
+//	rs2, _ := ais.OpenRecordSet("dec2017.aisc")
+//	rs3, _ := rs2.Subset(&ais.Box{MinLat: 36, MaxLat: 37, MinLon: -77, MaxLon: -76, LatIndex: 2, LonIndex: 3})
+const ColumnarExt = ".aisc"
+
+const (
+	columnarMagic   = "AISC"
+	columnarVersion = 1
+)
+
+// columnarBlockSize is the number of Records written in each block.
+var columnarBlockSize = 8192
+
+// maxColumnarBlock is the largest payload in bytes accepted for a block.
+const maxColumnarBlock = 1 << 30
+
+// Column encodings.
+const (
+	encDict byte = iota
+	encInt
+	encFloat
+	encTime
+)
+
+// Block markers.
+const (
+	markEnd byte = iota
+	markBlock
+)
+
+// Flags for the statistics held by a block.
+const (
+	statTime byte = 1 << iota
+	statBox
+)
+
+// blockStats are the statistics of one block of a columnar file.  The indices
+// are the columns the statistics were computed from, or -1 when the file has no
+// such column.
+type blockStats struct {
+	rows                           int
+	hasTime                        bool
+	minTime, maxTime               time.Time
+	hasBox                         bool
+	minLat, maxLat, minLon, maxLon float64
+	timeIndex, latIndex, lonIndex  int
+}
+
+// blockPruner is implemented by Matching types that can rule out every Record
+// of a block from its statistics alone.
+type blockPruner interface {
+	pruneBlock(bs *blockStats) bool
+}
+
+// pruneBlock returns true when the bounding box of the block does not overlap b.
+func (b *Box) pruneBlock(bs *blockStats) bool {
+	if !bs.hasBox || b.LatIndex != bs.latIndex || b.LonIndex != bs.lonIndex {
+		return false
+	}
+	return bs.maxLat < b.MinLat || bs.minLat > b.MaxLat || bs.maxLon < b.MinLon || bs.minLon > b.MaxLon
+}
+
+// pruneBlock returns true when every BaseDateTime in the block is outside tr.
+func (tr *TimeRange) pruneBlock(bs *blockStats) bool {
+	if !bs.hasTime || tr.TimeIndex != bs.timeIndex {
+		return false
+	}
+	if !tr.Start.IsZero() && bs.maxTime.Before(tr.Start) {
+		return true
+	}
+	return !tr.End.IsZero() && !bs.minTime.Before(tr.End)
+}
+
+// pruneBlock returns true when any of the Matching values rules out the block.
+func (all MatchAll) pruneBlock(bs *blockStats) bool {
+	for _, m := range all {
+		if p, ok := m.(blockPruner); ok && p.pruneBlock(bs) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeColumnar writes the Records remaining in the RecordSet to w in the
+// columnar format.
+func (rs *RecordSet) writeColumnar(w io.Writer) error {
+	cw := newColWriter(w, rs.h)
+	for {
+		rec, err := rs.readRaw()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read error on csv file: %v", err)
+		}
+		if err := cw.write(rec); err != nil {
+			return err
+		}
+	}
+	return cw.close()
+}
+
+// colWriter buffers Records and writes them to w one block at a time.
+type colWriter struct {
+	w                             *bufio.Writer
+	h                             Headers
+	timeIndex, latIndex, lonIndex int
+	rows                          [][]string
+	err                           error
+}
+
+// newColWriter writes the file header for h to w and returns a colWriter.
+func newColWriter(w io.Writer, h Headers) *colWriter {
+	cw := &colWriter{w: bufio.NewWriter(w), h: h}
+	cw.timeIndex = columnIndex(h, "BaseDateTime")
+	cw.latIndex = columnIndex(h, "LAT")
+	cw.lonIndex = columnIndex(h, "LON")
+
+	var buf bytes.Buffer
+	buf.WriteString(columnarMagic)
+	buf.WriteByte(columnarVersion)
+	putUvarint(&buf, uint64(len(h.Fields)))
+	for _, f := range h.Fields {
+		putString(&buf, f)
+	}
+	putUvarint(&buf, uint64(cw.timeIndex+1))
+	putUvarint(&buf, uint64(cw.latIndex+1))
+	putUvarint(&buf, uint64(cw.lonIndex+1))
+	_, cw.err = cw.w.Write(buf.Bytes())
+	return cw
+}
+
+// columnIndex returns the index of field in h or -1.
+func columnIndex(h Headers, field string) int {
+	if i, ok := h.Contains(field); ok {
+		return i
+	}
+	return -1
+}
+
+// write adds rec to the current block and writes the block when it is full.
+func (cw *colWriter) write(rec []string) error {
+	if cw.err != nil {
+		return cw.err
+	}
+	if len(rec) != len(cw.h.Fields) {
+		return fmt.Errorf("columnar: record has %d fields, want %d", len(rec), len(cw.h.Fields))
+	}
+	cw.rows = append(cw.rows, append([]string(nil), rec...))
+	if len(cw.rows) >= columnarBlockSize {
+		return cw.flush()
+	}
+	return nil
+}
+
+// close writes the last block and the end marker.
+func (cw *colWriter) close() error {
+	if err := cw.flush(); err != nil {
+		return err
+	}
+	cw.w.WriteByte(markEnd)
+	return cw.w.Flush()
+}
+
+// flush writes the buffered Records as a block.
+func (cw *colWriter) flush() error {
+	if cw.err != nil || len(cw.rows) == 0 {
+		return cw.err
+	}
+	bs := cw.stats()
+
+	var payload bytes.Buffer
+	for col := range cw.h.Fields {
+		values := make([]string, len(cw.rows))
+		for i, row := range cw.rows {
+			values[i] = row[col]
+		}
+		encodeColumn(&payload, values)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(markBlock)
+	putUvarint(&buf, uint64(bs.rows))
+	var flags byte
+	if bs.hasTime {
+		flags |= statTime
+	}
+	if bs.hasBox {
+		flags |= statBox
+	}
+	buf.WriteByte(flags)
+	if bs.hasTime {
+		putUint64(&buf, uint64(bs.minTime.UnixNano()))
+		putUint64(&buf, uint64(bs.maxTime.UnixNano()))
+	}
+	if bs.hasBox {
+		for _, f := range []float64{bs.minLat, bs.maxLat, bs.minLon, bs.maxLon} {
+			putUint64(&buf, math.Float64bits(f))
+		}
+	}
+	putUvarint(&buf, uint64(payload.Len()))
+	buf.Write(payload.Bytes())
+
+	_, cw.err = cw.w.Write(buf.Bytes())
+	cw.rows = cw.rows[:0]
+	return cw.err
+}
+
+// stats computes the statistics of the buffered Records.  A statistic is only
+// kept when every Record in the block can be parsed for it, so that a Matching
+// value still reports the parse errors of a block it cannot rule out.
+func (cw *colWriter) stats() blockStats {
+	bs := blockStats{rows: len(cw.rows), hasTime: cw.timeIndex >= 0, hasBox: cw.latIndex >= 0 && cw.lonIndex >= 0}
+	for i, row := range cw.rows {
+		rec := Record(row)
+		if bs.hasTime {
+			t, err := cw.h.ParseTime(rec, cw.timeIndex)
+			switch {
+			case err != nil:
+				bs.hasTime = false
+			case i == 0:
+				bs.minTime, bs.maxTime = t, t
+			case t.Before(bs.minTime):
+				bs.minTime = t
+			case t.After(bs.maxTime):
+				bs.maxTime = t
+			}
+		}
+		if bs.hasBox {
+			lat, err1 := rec.ParseFloat(cw.latIndex)
+			lon, err2 := rec.ParseFloat(cw.lonIndex)
+			if err1 != nil || err2 != nil {
+				bs.hasBox = false
+				continue
+			}
+			if i == 0 {
+				bs.minLat, bs.maxLat, bs.minLon, bs.maxLon = lat, lat, lon, lon
+				continue
+			}
+			bs.minLat, bs.maxLat = math.Min(bs.minLat, lat), math.Max(bs.maxLat, lat)
+			bs.minLon, bs.maxLon = math.Min(bs.minLon, lon), math.Max(bs.maxLon, lon)
+		}
+	}
+	return bs
+}
+
+// encodeColumn writes values to buf with the first encoding of encInt, encFloat
+// and encTime that reproduces every non-empty value exactly, or else with
+// encDict.  Empty values are recorded in a presence bitmap for all but encDict.
+func encodeColumn(buf *bytes.Buffer, values []string) {
+	var (
+		ints     = make([]int64, 0, len(values))
+		isInt    = true
+		isFloat  = true
+		isTime   = true
+		present  = make([]byte, (len(values)+7)/8)
+		nonEmpty []string
+	)
+	for i, s := range values {
+		if s == "" {
+			continue
+		}
+		present[i/8] |= 1 << uint(i%8)
+		nonEmpty = append(nonEmpty, s)
+		if isInt {
+			v, ok := exactInt(s)
+			isInt = ok
+			ints = append(ints, v)
+		}
+		if isFloat {
+			_, _, isFloat = exactFloat(s)
+		}
+		if isTime {
+			_, isTime = exactTime(s)
+		}
+	}
+
+	switch {
+	case isInt:
+		buf.WriteByte(encInt)
+		buf.Write(present)
+		var prev int64
+		for _, v := range ints {
+			putVarint(buf, v-prev)
+			prev = v
+		}
+	case isFloat:
+		buf.WriteByte(encFloat)
+		buf.Write(present)
+		for _, s := range nonEmpty {
+			f, dec, _ := exactFloat(s)
+			putUint64(buf, math.Float64bits(f))
+			buf.WriteByte(dec)
+		}
+	case isTime:
+		buf.WriteByte(encTime)
+		buf.Write(present)
+		var prev int64
+		for _, s := range nonEmpty {
+			t, _ := exactTime(s)
+			putVarint(buf, t-prev)
+			prev = t
+		}
+	default:
+		buf.WriteByte(encDict)
+		index := make(map[string]uint64)
+		var dict []string
+		for _, s := range values {
+			if _, ok := index[s]; !ok {
+				index[s] = uint64(len(dict))
+				dict = append(dict, s)
+			}
+		}
+		putUvarint(buf, uint64(len(dict)))
+		for _, s := range dict {
+			putString(buf, s)
+		}
+		for _, s := range values {
+			putUvarint(buf, index[s])
+		}
+	}
+}
+
+// exactInt parses s as a base 10 integer that formats back to s.
+func exactInt(s string) (int64, bool) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	return v, err == nil && strconv.FormatInt(v, 10) == s
+}
+
+// exactFloat parses s as a decimal number that formats back to s with the
+// returned number of digits after the decimal point.
+func exactFloat(s string) (float64, byte, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	dec := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		dec = len(s) - i - 1
+	}
+	if dec > math.MaxUint8 {
+		return 0, 0, false
+	}
+	return f, byte(dec), strconv.FormatFloat(f, 'f', dec, 64) == s
+}
+
+// exactTime parses s with TimeLayout and returns its Unix time when it
+// formats back to s.
+func exactTime(s string) (int64, bool) {
+	t, err := time.Parse(TimeLayout, s)
+	return t.Unix(), err == nil && t.Format(TimeLayout) == s
+}
+
+func putUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func putVarint(buf *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func putUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func putString(buf *bytes.Buffer, s string) {
+	putUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// colReader reads the Records of a columnar file one block at a time.  When
+// prune is set it is called with the statistics of each block, and blocks it
+// returns true for are skipped.
+type colReader struct {
+	d                             colDecoder
+	fields                        []string
+	timeIndex, latIndex, lonIndex int
+	rows                          [][]string
+	prune                         func(*blockStats) bool
+	done                          bool
+}
+
+// newColReader reads the file header from r and returns a colReader.
+func newColReader(r io.Reader) (*colReader, error) {
+	cr := &colReader{d: colDecoder{r: bufio.NewReader(r)}}
+	magic := cr.d.bytes(len(columnarMagic))
+	if cr.d.err == nil && string(magic) != columnarMagic {
+		return nil, fmt.Errorf("columnar: not a columnar file")
+	}
+	if v := cr.d.byte(); cr.d.err == nil && v != columnarVersion {
+		return nil, fmt.Errorf("columnar: unsupported version %d", v)
+	}
+	n := cr.d.uvarint()
+	for i := uint64(0); i < n && cr.d.err == nil; i++ {
+		cr.fields = append(cr.fields, cr.d.string())
+	}
+	cr.timeIndex = cr.d.length(len(cr.fields)) - 1
+	cr.latIndex = cr.d.length(len(cr.fields)) - 1
+	cr.lonIndex = cr.d.length(len(cr.fields)) - 1
+	if cr.d.err != nil {
+		return nil, fmt.Errorf("columnar: header: %v", cr.d.err)
+	}
+	return cr, nil
+}
+
+// Read returns the fields of the next Record, or io.EOF after the last one.
+func (cr *colReader) Read() ([]string, error) {
+	for len(cr.rows) == 0 {
+		if cr.done {
+			return nil, io.EOF
+		}
+		if err := cr.next(); err != nil {
+			return nil, err
+		}
+	}
+	row := cr.rows[0]
+	cr.rows = cr.rows[1:]
+	return row, nil
+}
+
+// next reads the next block, skipping any that are pruned, and decodes its
+// Records.
+func (cr *colReader) next() error {
+	d := &cr.d
+	if mark := d.byte(); d.err != nil || mark == markEnd {
+		cr.done = true
+		if d.err != nil {
+			return fmt.Errorf("columnar: %v", d.err)
+		}
+		return nil
+	}
+
+	bs := blockStats{timeIndex: cr.timeIndex, latIndex: cr.latIndex, lonIndex: cr.lonIndex}
+	bs.rows = d.length(math.MaxInt32)
+	flags := d.byte()
+	if flags&statTime != 0 {
+		bs.hasTime = true
+		bs.minTime = time.Unix(0, int64(d.uint64())).UTC()
+		bs.maxTime = time.Unix(0, int64(d.uint64())).UTC()
+	}
+	if flags&statBox != 0 {
+		bs.hasBox = true
+		bs.minLat = math.Float64frombits(d.uint64())
+		bs.maxLat = math.Float64frombits(d.uint64())
+		bs.minLon = math.Float64frombits(d.uint64())
+		bs.maxLon = math.Float64frombits(d.uint64())
+	}
+	payload := d.bytes(d.length(maxColumnarBlock))
+	// Every column stores at least one bit for each row.
+	if d.err == nil && len(cr.fields) > 0 && bs.rows > 8*len(payload) {
+		d.err = fmt.Errorf("invalid row count %d", bs.rows)
+	}
+	if d.err != nil {
+		cr.done = true
+		return fmt.Errorf("columnar: block: %v", d.err)
+	}
+	if cr.prune != nil && cr.prune(&bs) {
+		return nil
+	}
+
+	rows := make([][]string, bs.rows)
+	for i := range rows {
+		rows[i] = make([]string, len(cr.fields))
+	}
+	pd := colDecoder{r: bytes.NewReader(payload)}
+	for col := range cr.fields {
+		if err := pd.column(rows, col); err != nil {
+			cr.done = true
+			return fmt.Errorf("columnar: column %s: %v", cr.fields[col], err)
+		}
+	}
+	cr.rows = rows
+	return nil
+}
+
+// colDecoder reads the primitive values of the columnar format.  The first
+// error is held in err and later reads return zero values.
+type colDecoder struct {
+	r interface {
+		io.Reader
+		io.ByteReader
+	}
+	err error
+}
+
+func (d *colDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *colDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *colDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+// remaining returns the number of unread bytes of a block payload, or
+// maxColumnarBlock when the reader does not know its length.
+func (d *colDecoder) remaining() int {
+	if br, ok := d.r.(*bytes.Reader); ok {
+		return br.Len()
+	}
+	return maxColumnarBlock
+}
+
+// length reads a length or count and sets err when it is larger than max.
+func (d *colDecoder) length(max int) int {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(max) {
+		d.err = fmt.Errorf("invalid length %d", n)
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *colDecoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.bytes(8))
+}
+
+// bytes reads n bytes.  The buffer grows as the bytes are read, so a corrupt
+// length in a truncated file does not allocate more than the file holds.
+func (d *colDecoder) bytes(n int) []byte {
+	if d.err != nil || n < 0 || n > maxColumnarBlock {
+		if d.err == nil {
+			d.err = fmt.Errorf("invalid length %d", n)
+		}
+		return make([]byte, 8)
+	}
+	var buf bytes.Buffer
+	if n <= bytes.MinRead {
+		buf.Grow(n)
+	}
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		d.err = err
+		if err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return make([]byte, 8)
+	}
+	return buf.Bytes()
+}
+
+func (d *colDecoder) string() string {
+	return string(d.bytes(int(d.uvarint())))
+}
+
+// column decodes one column of a block into rows[i][col].
+func (d *colDecoder) column(rows [][]string, col int) error {
+	enc := d.byte()
+	if enc == encDict {
+		// Every entry stores at least the byte of its length.
+		dict := make([]string, d.length(d.remaining()))
+		for i := range dict {
+			dict[i] = d.string()
+		}
+		for _, row := range rows {
+			i := d.uvarint()
+			if d.err == nil && i >= uint64(len(dict)) {
+				return fmt.Errorf("dictionary index %d out of range", i)
+			}
+			if d.err == nil {
+				row[col] = dict[i]
+			}
+		}
+		return d.err
+	}
+
+	present := d.bytes((len(rows) + 7) / 8)
+	var prev int64
+	for i, row := range rows {
+		if d.err != nil {
+			break
+		}
+		if present[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		switch enc {
+		case encInt:
+			prev += d.varint()
+			row[col] = strconv.FormatInt(prev, 10)
+		case encFloat:
+			f := math.Float64frombits(d.uint64())
+			row[col] = strconv.FormatFloat(f, 'f', int(d.byte()), 64)
+		case encTime:
+			prev += d.varint()
+			row[col] = time.Unix(prev, 0).UTC().Format(TimeLayout)
+		default:
+			return fmt.Errorf("unknown encoding %d", enc)
+		}
+	}
+	return d.err
+}
@@ -0,0 +1,257 @@
+package ais
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// saveColumnarFile opens the csv file and saves it in the columnar format in dir
+// with the given block size.
+func saveColumnarFile(t *testing.T, dir, csvFile string, blockSize int) string {
+	t.Helper()
+	defer func(n int) { columnarBlockSize = n }(columnarBlockSize)
+	columnarBlockSize = blockSize
+
+	rs, err := OpenRecordSet(csvFile)
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	name := filepath.Join(dir, "data"+ColumnarExt)
+	if err := rs.Save(name); err != nil {
+		t.Fatalf("RecordSet.Save() error = %v", err)
+	}
+	return name
+}
+
+func TestColumnar_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		file      string
+		blockSize int
+	}{
+		{"ten", "testdata/ten.csv", 8192},
+		{"ten small blocks", "testdata/ten.csv", 3},
+		{"track", "testdata/track.csv", 5},
+		{"dma", "testdata/dma.csv", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := saveColumnarFile(t, dir, tt.file, tt.blockSize)
+
+			want, err := OpenRecordSet(tt.file)
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer want.Close()
+			got, err := OpenRecordSet(name)
+			if err != nil {
+				t.Fatalf("OpenRecordSet(columnar) error = %v", err)
+			}
+			defer got.Close()
+
+			if !got.Headers().Equals(want.Headers()) {
+				t.Errorf("Headers = %v, want %v", got.Headers().Fields, want.Headers().Fields)
+			}
+			gotSchema, _ := got.Headers().Schema()
+			wantSchema, _ := want.Headers().Schema()
+			if gotSchema.Name != wantSchema.Name {
+				t.Errorf("Headers.Schema() = %q, want %q", gotSchema.Name, wantSchema.Name)
+			}
+			gotRecs, wantRecs := readAll(t, got), readAll(t, want)
+			if !reflect.DeepEqual(gotRecs, wantRecs) {
+				t.Errorf("Records = %v, want %v", gotRecs, wantRecs)
+			}
+		})
+	}
+}
+
+func TestEncodeColumn(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   byte
+	}{
+		{"ints", []string{"367157579", "", "-3", "0"}, encInt},
+		{"leading zero", []string{"007", "12"}, encDict},
+		{"floats", []string{"31.90512", "-76.30", "", "12", "-0.0"}, encFloat},
+		{"times", []string{"2017-12-01T00:00:01", "", "2016-02-29T23:59:59"}, encTime},
+		{"other time layout", []string{"2017-12-01 00:00:01"}, encDict},
+		{"strings", []string{"FIRST", "", "FIRST", "IMO9739666"}, encDict},
+		{"empty", []string{"", ""}, encInt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encodeColumn(&buf, tt.values)
+			if got := buf.Bytes()[0]; got != tt.want {
+				t.Errorf("encodeColumn() encoding = %d, want %d", got, tt.want)
+			}
+			rows := make([][]string, len(tt.values))
+			for i := range rows {
+				rows[i] = make([]string, 1)
+			}
+			d := colDecoder{r: &buf}
+			if err := d.column(rows, 0); err != nil {
+				t.Fatalf("colDecoder.column() error = %v", err)
+			}
+			for i, row := range rows {
+				if row[0] != tt.values[i] {
+					t.Errorf("colDecoder.column()[%d] = %q, want %q", i, row[0], tt.values[i])
+				}
+			}
+		})
+	}
+}
+
+// countMatches counts the Records passed to Match.
+type countMatches struct{ n int }
+
+func (c *countMatches) Match(rec *Record) (bool, error) {
+	c.n++
+	return true, nil
+}
+
+func TestColumnar_Pushdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := saveColumnarFile(t, dir, "testdata/track.csv", 4)
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+
+	tests := []struct {
+		name     string
+		m        func() Matching
+		wantRead int
+		wantRecs int
+	}{
+		{"box", func() Matching {
+			return &Box{MinLat: 45, MaxLat: 50, MinLon: -80, MaxLon: -70, LatIndex: 2, LonIndex: 3}
+		}, 8, 2},
+		{"box outside", func() Matching {
+			return &Box{MinLat: 0, MaxLat: 10, MinLon: 0, MaxLon: 10, LatIndex: 2, LonIndex: 3}
+		}, 0, 0},
+		{"time range", func() Matching {
+			tr, _ := NewTimeRange(h, time.Date(2017, 12, 2, 0, 0, 0, 0, time.UTC), time.Time{})
+			return tr
+		}, 4, 1},
+		{"time range end", func() Matching {
+			tr, _ := NewTimeRange(h, time.Time{}, time.Date(2017, 12, 1, 0, 0, 5, 0, time.UTC))
+			return tr
+		}, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := OpenRecordSet(name)
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+
+			c := &countMatches{}
+			rs2, err := rs.Subset(MatchAll{c, tt.m()})
+			if err != nil && err != ErrEmptySet {
+				t.Fatalf("RecordSet.Subset() error = %v", err)
+			}
+			if c.n != tt.wantRead {
+				t.Errorf("RecordSet.Subset() decoded %d records, want %d", c.n, tt.wantRead)
+			}
+			if got := countRecords(t, rs2); got != tt.wantRecs {
+				t.Errorf("RecordSet.Subset() matched %d records, want %d", got, tt.wantRecs)
+			}
+		})
+	}
+}
+
+func TestOpenRecordSet_BadColumnar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := saveColumnarFile(t, dir, "testdata/ten.csv", 4)
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, data[:len(data)-20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := OpenRecordSet(name)
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	for {
+		_, err := rs.Read()
+		if err == nil {
+			continue
+		}
+		if err == io.EOF {
+			t.Errorf("RecordSet.Read() on a truncated file error = EOF, want error")
+		}
+		break
+	}
+}
+
+func TestColReader_CorruptLengths(t *testing.T) {
+	// block returns a columnar file with one MMSI field and one block of rows
+	// whose payload is given.
+	block := func(rows uint64, payload []byte) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(columnarMagic)
+		buf.WriteByte(columnarVersion)
+		putUvarint(&buf, 1)
+		putString(&buf, "MMSI")
+		putUvarint(&buf, 0)
+		putUvarint(&buf, 0)
+		putUvarint(&buf, 0)
+		buf.WriteByte(markBlock)
+		putUvarint(&buf, rows)
+		buf.WriteByte(0)
+		putUvarint(&buf, uint64(len(payload)))
+		buf.Write(payload)
+		buf.WriteByte(markEnd)
+		return buf.Bytes()
+	}
+	var dict bytes.Buffer
+	dict.WriteByte(encDict)
+	putUvarint(&dict, 1<<40)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"rows", block(1<<40, []byte{encInt, 0})},
+		{"rows beyond payload", block(1<<20, []byte{encInt, 0})},
+		{"dictionary", block(1, dict.Bytes())},
+		{"truncated payload", block(1, []byte{encInt, 1, 2})[:20]},
+		{"payload length", append(block(1, nil)[:17], 0xff, 0xff, 0xff, 0xff, 0x0f)},
+		{"time index", append([]byte(columnarMagic), columnarVersion, 1, 4, 'M', 'M', 'S', 'I', 9, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, err := newColReader(bytes.NewReader(tt.data))
+			for err == nil {
+				_, err = cr.Read()
+			}
+			if err == io.EOF {
+				t.Errorf("colReader.Read() error = EOF, want error")
+			}
+		})
+	}
+}
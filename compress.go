@@ -0,0 +1,261 @@
+package ais
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The filename extensions that RecordSet.Save compresses its output with.
+// OpenRecordSet detects compressed files from their content.
+const (
+	GzipExt = ".gz"
+	ZipExt  = ".zip"
+)
+
+const (
+	gzipMagic = "\x1f\x8b"
+	zipMagic  = "PK\x03\x04"
+)
+
+// Compression describes a stream compression that OpenRecordSet and
+// NewRecordSetFromReader decompress and RecordSet.Save writes once it is
+// registered with RegisterCompression.  Gzip and zip are built in.  Importing
+// the zstd subpackage for its side effect adds Zstandard without adding its
+// dependencies to package ais.
+//
+//	import _ "github.com/FATHOM5/ais/zstd"
+type Compression struct {
+	// Name is used in error messages.
+	Name string
+
+	// Ext is the final filename extension that RecordSet.Save compresses.
+	Ext string
+
+	// Magic is the start of every compressed stream, of at most four bytes.
+	Magic string
+
+	// NewReader returns a reader of the decompressed data of r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+
+	// NewWriter returns a writer that compresses to w.  Closing it flushes the
+	// compressed data but does not close w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   []Compression
+)
+
+// RegisterCompression registers a Compression.  It is usually called from the
+// init function of the package that implements the Compression and panics if
+// the Compression is incomplete or its Ext or Magic is already in use.
+func RegisterCompression(c Compression) {
+	if c.Ext == "" || c.Magic == "" || len(c.Magic) > 4 || c.NewReader == nil || c.NewWriter == nil {
+		panic("ais: RegisterCompression of an incomplete compression " + c.Name)
+	}
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	for _, d := range compressions {
+		if d.Ext == c.Ext || d.Magic == c.Magic {
+			panic("ais: RegisterCompression called twice for compression " + c.Name)
+		}
+	}
+	if c.Ext == GzipExt || c.Ext == ZipExt || strings.HasPrefix(c.Magic, gzipMagic) || c.Magic == zipMagic {
+		panic("ais: RegisterCompression of built in compression " + c.Name)
+	}
+	compressions = append(compressions, c)
+}
+
+// registeredCompression returns the registered Compression whose Ext is ext
+// or, when ext is empty, whose Magic starts magic.
+func registeredCompression(ext, magic string) (Compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for _, c := range compressions {
+		if (ext != "" && ext == c.Ext) || (ext == "" && strings.HasPrefix(magic, c.Magic)) {
+			return c, true
+		}
+	}
+	return Compression{}, false
+}
+
+// ErrReadOnly is returned by RecordSet.Write and RecordSet.Flush on a
+// RecordSet opened with OpenRecordSet, whose file is opened read only, or
+// created with NewRecordSetFromReader.
+var ErrReadOnly = errors.New("recordset is read only")
+
+// readOnlyWriter is written to by the csv.Writer of a RecordSet whose data is
+// read only, so that the Records written to it are not silently discarded.
+type readOnlyWriter struct{}
+
+// Write implements io.Writer.  It always returns ErrReadOnly.
+func (readOnlyWriter) Write(p []byte) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// readOnlyData is the data of a RecordSet that is read from a stream or a
+// compressed file.  Reads return the decompressed data and Close closes the
+// decompressor and then the underlying stream.
+type readOnlyData struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Write implements io.Writer so that readOnlyData can be held as the data of a
+// RecordSet.  It always returns ErrReadOnly.
+func (d *readOnlyData) Write(p []byte) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Close closes the decompressor and the underlying stream.
+func (d *readOnlyData) Close() error {
+	var first error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// decompress returns the data of f for a RecordSet, decompressing it when magic
+// is that of gzip, zip or a registered Compression.  Files that are not
+// compressed are returned as is.
+func decompress(f *os.File, magic string) (io.ReadWriter, error) {
+	if magic != zipMagic {
+		if _, ok := registeredCompression("", magic); !ok && !strings.HasPrefix(magic, gzipMagic) {
+			return f, nil
+		}
+		return decompressStream(f, magic, f)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("zip: %v", err)
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("zip: %v", err)
+	}
+	member, err := zipMember(zr)
+	if err != nil {
+		return nil, fmt.Errorf("zip: %v", err)
+	}
+	rc, err := member.Open()
+	if err != nil {
+		return nil, fmt.Errorf("zip: %v", err)
+	}
+	return &readOnlyData{rc, []io.Closer{rc, f}}, nil
+}
+
+// decompressStream returns read only data for a RecordSet that decompresses r
+// when magic is that of gzip or a registered Compression.  The closer c, which
+// may be nil, is closed with the data.
+func decompressStream(r io.Reader, magic string, c io.Closer) (io.ReadWriter, error) {
+	var closers []io.Closer
+	registered, ok := registeredCompression("", magic)
+	switch {
+	case strings.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %v", err)
+		}
+		r = zr
+		closers = append(closers, zr)
+	case magic == zipMagic:
+		return nil, fmt.Errorf("zip archives must be opened from a file with OpenRecordSet")
+	case ok:
+		zr, err := registered.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", registered.Name, err)
+		}
+		r = zr
+		closers = append(closers, zr)
+	}
+	if c != nil {
+		closers = append(closers, c)
+	}
+	return &readOnlyData{r, closers}, nil
+}
+
+// zipMember returns the first csv file in the archive, ignoring directories
+// and the metadata that macOS adds to archives.  An archive with a single
+// file returns that file whatever its name.
+func zipMember(zr *zip.Reader) (*zip.File, error) {
+	var files []*zip.File
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || strings.HasPrefix(zf.Name, "__MACOSX/") {
+			continue
+		}
+		if strings.EqualFold(path.Ext(zf.Name), ".csv") {
+			return zf, nil
+		}
+		files = append(files, zf)
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	return nil, fmt.Errorf("archive does not contain a csv file")
+}
+
+// compressionExt returns the compression extension of name, or "" when name is
+// not compressed.
+func compressionExt(name string) string {
+	switch ext := filepath.Ext(name); ext {
+	case GzipExt, ZipExt:
+		return ext
+	}
+	if c, ok := registeredCompression(filepath.Ext(name), ""); ok {
+		return c.Ext
+	}
+	return ""
+}
+
+// compress returns a writer that compresses to f according to the extension of
+// name.  Closing the writer flushes the compressed data but does not close f.
+func compress(f *os.File, name string) (io.WriteCloser, error) {
+	switch ext := compressionExt(name); ext {
+	case "":
+	case GzipExt:
+		return gzip.NewWriter(f), nil
+	case ZipExt:
+		member := strings.TrimSuffix(filepath.Base(name), ZipExt)
+		if filepath.Ext(member) == "" {
+			member += ".csv"
+		}
+		zw := zip.NewWriter(f)
+		w, err := zw.Create(member)
+		if err != nil {
+			return nil, err
+		}
+		return &zipMemberWriter{w, zw}, nil
+	default:
+		c, _ := registeredCompression(ext, "")
+		return c.NewWriter(f)
+	}
+	return nopWriteCloser{f}, nil
+}
+
+// zipMemberWriter writes the single member of a zip archive and closes the
+// archive on Close.
+type zipMemberWriter struct {
+	io.Writer
+	zw *zip.Writer
+}
+
+func (z *zipMemberWriter) Close() error { return z.zw.Close() }
+
+// nopWriteCloser adds a Close method that does nothing to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
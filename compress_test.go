@@ -0,0 +1,153 @@
+package ais
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecordSet_SaveCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name  string
+		file  string
+		magic string
+	}{
+		{"gzip", "ten.csv.gz", gzipMagic},
+		{"zip", "ten.zip", zipMagic},
+		{"zip csv", "ten.csv.zip", zipMagic},
+		{"columnar gzip", "ten.aisc.gz", gzipMagic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := OpenRecordSet("testdata/ten.csv")
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			name := filepath.Join(dir, tt.file)
+			if err := rs.Save(name); err != nil {
+				t.Fatalf("RecordSet.Save() error = %v", err)
+			}
+			b, _ := ioutil.ReadFile(name)
+			if len(b) < len(tt.magic) || string(b[:len(tt.magic)]) != tt.magic {
+				t.Fatalf("RecordSet.Save() did not compress %s", tt.file)
+			}
+
+			want, _ := OpenRecordSet("testdata/ten.csv")
+			defer want.Close()
+			got, err := OpenRecordSet(name)
+			if err != nil {
+				t.Fatalf("OpenRecordSet(%s) error = %v", tt.file, err)
+			}
+			if !got.Headers().Equals(want.Headers()) {
+				t.Errorf("Headers = %v, want %v", got.Headers().Fields, want.Headers().Fields)
+			}
+			gotRecs, wantRecs := readAll(t, got), readAll(t, want)
+			if !reflect.DeepEqual(gotRecs, wantRecs) {
+				t.Errorf("Records = %v, want %v", gotRecs, wantRecs)
+			}
+			if err := got.Close(); err != nil {
+				t.Errorf("RecordSet.Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenRecordSet_ZipMember(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvData, err := ioutil.ReadFile("testdata/ten.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		members map[string]string
+		wantErr bool
+	}{
+		{"csv member", map[string]string{"AIS_ASCII_by_UTM_Month/": "", "__MACOSX/._ten.csv": "junk", "README.txt": "readme", "AIS_ASCII_by_UTM_Month/ten.csv": string(csvData)}, false},
+		{"single member", map[string]string{"ten.txt": string(csvData)}, false},
+		{"no csv", map[string]string{"a.txt": "a", "b.txt": "b"}, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, string(rune('a'+i))+".zip")
+			f, err := os.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zw := zip.NewWriter(f)
+			for _, member := range []string{"AIS_ASCII_by_UTM_Month/", "__MACOSX/._ten.csv", "README.txt", "AIS_ASCII_by_UTM_Month/ten.csv", "ten.txt", "a.txt", "b.txt"} {
+				data, ok := tt.members[member]
+				if !ok {
+					continue
+				}
+				w, _ := zw.Create(member)
+				w.Write([]byte(data))
+			}
+			zw.Close()
+			f.Close()
+
+			rs, err := OpenRecordSet(name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenRecordSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer rs.Close()
+			if got := countRecords(t, rs); got != 10 {
+				t.Errorf("OpenRecordSet() read %d records, want 10", got)
+			}
+		})
+	}
+}
+
+func TestOpenRecordSet_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvData, err := ioutil.ReadFile("testdata/ten.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "ten.csv")
+	if err := ioutil.WriteFile(name, csvData, 0444); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := OpenRecordSet(name)
+	if err != nil {
+		t.Fatalf("OpenRecordSet() on a read only file error = %v", err)
+	}
+	defer rs.Close()
+	if got := countRecords(t, rs); got != 10 {
+		t.Errorf("OpenRecordSet() read %d records, want 10", got)
+	}
+	// Records written to the RecordSet are not silently discarded.
+	if err := rs.Write(Record{"1", "2017-12-01T00:00:01"}); err != nil && err != ErrReadOnly {
+		t.Errorf("RecordSet.Write() error = %v, want nil or %v", err, ErrReadOnly)
+	}
+	if err := rs.Flush(); err != ErrReadOnly {
+		t.Errorf("RecordSet.Flush() error = %v, want %v", err, ErrReadOnly)
+	}
+	if got, _ := ioutil.ReadFile(name); !bytes.Equal(got, csvData) {
+		t.Errorf("RecordSet.Flush() changed the file")
+	}
+}
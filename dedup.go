@@ -0,0 +1,130 @@
+package ais
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/FATHOM5/haversine"
+)
+
+// dedupEntry is a Record that was kept by Dedup and may still match a later
+// report of the same vessel.
+type dedupEntry struct {
+	mmsi     string
+	t        time.Time
+	lat, lon float64
+}
+
+// Dedup returns a pointer to a new RecordSet without the repeated position
+// reports that occur when feeds from overlapping receivers are merged.  A Record
+// is a duplicate when a Record that was already kept has the same MMSI, a
+// BaseDateTime within timeTol and a position within distTol nautical miles.
+// Window.AddRecord only removes byte-identical Records, so Dedup is the way to
+// remove reports that differ by a second or a few meters.
+//
+// Dedup streams over a RecordSet that is sorted by BaseDateTime and only
+// remembers the Records kept within the last timeTol, so memory use is bounded by
+// the traffic in a single tolerance interval rather than the size of the set.
+// It returns an error if a Record is more than timeTol earlier than a Record
+// that preceded it.
+func (rs *RecordSet) Dedup(timeTol time.Duration, distTol float64) (*RecordSet, error) {
+	idxMap, ok := rs.Headers().ContainsMulti("MMSI", "BaseDateTime", "LAT", "LON")
+	if !ok {
+		return nil, fmt.Errorf("dedup: headers must contain MMSI, BaseDateTime, LAT and LON")
+	}
+	mmsiIndex, timeIndex := idxMap["MMSI"].Idx, idxMap["BaseDateTime"].Idx
+	latIndex, lonIndex := idxMap["LAT"].Idx, idxMap["LON"].Idx
+
+	rs2 := NewRecordSet()
+	rs2.SetHeaders(rs.Headers())
+
+	var queue []*dedupEntry // kept entries in time order
+	recent := make(map[string][]*dedupEntry)
+	var latest time.Time
+
+	written := 0
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dedup: read error on csv file: %v", err)
+		}
+
+		t, err := rs.Headers().ParseTime(*rec, timeIndex)
+		if err != nil {
+			return nil, fmt.Errorf("dedup: %v", err)
+		}
+		lat, err := rec.ParseFloat(latIndex)
+		if err != nil {
+			return nil, fmt.Errorf("dedup: unable to parse %v", (*rec)[latIndex])
+		}
+		lon, err := rec.ParseFloat(lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("dedup: unable to parse %v", (*rec)[lonIndex])
+		}
+		if latest.Sub(t) > timeTol {
+			return nil, fmt.Errorf("dedup: recordset is not sorted by time at %s", (*rec)[timeIndex])
+		}
+		if t.After(latest) {
+			latest = t
+		}
+
+		// Forget the entries that can no longer match any Record.
+		for len(queue) > 0 && latest.Sub(queue[0].t) > timeTol {
+			old := queue[0]
+			queue[0] = nil
+			queue = queue[1:]
+			entries := recent[old.mmsi][1:]
+			if len(entries) == 0 {
+				delete(recent, old.mmsi)
+			} else {
+				recent[old.mmsi] = entries
+			}
+		}
+
+		mmsi := (*rec)[mmsiIndex]
+		if isDuplicate(recent[mmsi], t, lat, lon, timeTol, distTol) {
+			continue
+		}
+
+		e := &dedupEntry{mmsi: mmsi, t: t, lat: lat, lon: lon}
+		queue = append(queue, e)
+		recent[mmsi] = append(recent[mmsi], e)
+
+		if err := rs2.Write(*rec); err != nil {
+			return nil, fmt.Errorf("dedup: csv write error: %v", err)
+		}
+		written++
+		if written%flushThreshold == 0 {
+			if err := rs2.Flush(); err != nil {
+				return nil, fmt.Errorf("dedup: csv flush error: %v", err)
+			}
+		}
+	}
+	if err := rs2.Flush(); err != nil {
+		return nil, fmt.Errorf("dedup: csv flush error: %v", err)
+	}
+	return rs2, nil
+}
+
+// isDuplicate returns true when any of the kept entries is within the time and
+// distance tolerance of the report.
+func isDuplicate(entries []*dedupEntry, t time.Time, lat, lon float64, timeTol time.Duration, distTol float64) bool {
+	p := haversine.Coord{Lat: lat, Lon: lon}
+	for _, e := range entries {
+		dt := t.Sub(e.t)
+		if dt < 0 {
+			dt = -dt
+		}
+		if dt > timeTol {
+			continue
+		}
+		if haversine.Distance(p, haversine.Coord{Lat: e.lat, Lon: e.lon}) <= distTol {
+			return true
+		}
+	}
+	return false
+}
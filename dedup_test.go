@@ -0,0 +1,91 @@
+package ais
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testDedupString contains the same report from two receivers one second apart,
+// a byte-identical repeat, a report from the same vessel that has moved, and a
+// report from a second vessel at the same position.
+var testDedupString = `MMSI,BaseDateTime,LAT,LON
+111111111,2017-12-01T00:00:00,36.00000,-76.00000
+111111111,2017-12-01T00:00:01,36.00001,-76.00001
+111111111,2017-12-01T00:00:01,36.00001,-76.00001
+222222222,2017-12-01T00:00:02,36.00000,-76.00000
+111111111,2017-12-01T00:00:03,36.05000,-76.00000
+111111111,2017-12-01T00:01:00,36.00000,-76.00000
+`
+
+func TestRecordSet_Dedup(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		timeTol  time.Duration
+		distTol  float64
+		wantMMSI []string
+		wantErr  bool
+	}{
+		{
+			name:     "two second and tenth of a mile tolerance",
+			data:     testDedupString,
+			timeTol:  2 * time.Second,
+			distTol:  0.1,
+			wantMMSI: []string{"111111111", "222222222", "111111111", "111111111"},
+		},
+		{
+			name:     "zero tolerance removes identical reports only",
+			data:     testDedupString,
+			timeTol:  0,
+			distTol:  0,
+			wantMMSI: []string{"111111111", "111111111", "222222222", "111111111", "111111111"},
+		},
+		{
+			name:     "large tolerance keeps one report per vessel",
+			data:     testDedupString,
+			timeTol:  2 * time.Minute,
+			distTol:  5,
+			wantMMSI: []string{"111111111", "222222222"},
+		},
+		{
+			name:    "missing headers",
+			data:    "MMSI,LAT,LON\n111111111,36.0,-76.0\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsorted",
+			data:    "MMSI,BaseDateTime,LAT,LON\n111111111,2017-12-01T00:10:00,36.0,-76.0\n111111111,2017-12-01T00:00:00,36.0,-76.0\n",
+			timeTol: time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "bad lat",
+			data:    "MMSI,BaseDateTime,LAT,LON\n111111111,2017-12-01T00:10:00,3x.0,-76.0\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newTestRecordSet(t, tt.data)
+			got, err := rs.Dedup(tt.timeTol, tt.distTol)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecordSet.Dedup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var gotMMSI []string
+			for {
+				rec, err := got.Read()
+				if err != nil {
+					break
+				}
+				gotMMSI = append(gotMMSI, (*rec)[0])
+			}
+			if !reflect.DeepEqual(gotMMSI, tt.wantMMSI) {
+				t.Errorf("RecordSet.Dedup() kept %v, want %v", gotMMSI, tt.wantMMSI)
+			}
+		})
+	}
+}
@@ -0,0 +1,582 @@
+package ais
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// Grid divides the surface into rectangular cells for a Density.  Rows count
+// north from MinLat and columns count east from MinLon.  A Grid is created with
+// NewGrid for a regular latitude and longitude grid over a bounding box, or with
+// NewGeohashGrid for the cells of a geohash precision over the whole globe.
+type Grid struct {
+	MinLat, MinLon   float64
+	LatStep, LonStep float64 // size of a cell in degrees
+	Rows, Cols       int
+	geohashBits      uint
+}
+
+// MaxGridCells is the largest number of cells in a raster written by
+// WriteASCIIGrid or WritePNG, which hold a value for every cell in memory.
+// NewGrid returns an error for a Grid with more cells.
+const MaxGridCells = 1 << 24
+
+// NewGrid returns a *Grid of square cells size degrees on a side that covers the
+// box from minLat to maxLat and minLon to maxLon.  The last row and column are
+// extended to the edge of a whole cell when the box is not a multiple of size.
+// It returns an error when the Grid would have more than MaxGridCells cells.
+func NewGrid(minLat, maxLat, minLon, maxLon, size float64) (*Grid, error) {
+	if !(size > 0) || !(minLat < maxLat) || !(minLon < maxLon) {
+		return nil, fmt.Errorf("grid: want minLat < maxLat, minLon < maxLon and a positive size")
+	}
+	rows, cols := gridCells(maxLat-minLat, size), gridCells(maxLon-minLon, size)
+	if rows*cols > MaxGridCells {
+		return nil, fmt.Errorf("grid: %.0f by %.0f cells of %g degrees is more than %d cells, use a larger size", rows, cols, size, MaxGridCells)
+	}
+	return &Grid{
+		MinLat:  minLat,
+		MinLon:  minLon,
+		LatStep: size,
+		LonStep: size,
+		Rows:    int(rows),
+		Cols:    int(cols),
+	}, nil
+}
+
+// gridCells returns the number of cells of size that cover span, allowing for
+// the rounding of a span that is a whole number of cells.  It is a float64 so
+// that a number too large for an int can be rejected.
+func gridCells(span, size float64) float64 {
+	return math.Ceil(span/size - 1e-9)
+}
+
+// NewGeohashGrid returns a *Grid whose cells are the geohashes with bits of
+// precision, the same cells as a Geohash field appended with a
+// PrecisionGeohasher of that precision.  Bits must be between 2 and 62.
+func NewGeohashGrid(bits uint) (*Grid, error) {
+	if bits < 2 || bits > 62 {
+		return nil, fmt.Errorf("grid: geohash precision must be between 2 and 62 bits, got %d", bits)
+	}
+	latBits, lonBits := bits/2, (bits+1)/2
+	return &Grid{
+		MinLat:      -90,
+		MinLon:      -180,
+		LatStep:     180 / float64(uint64(1)<<latBits),
+		LonStep:     360 / float64(uint64(1)<<lonBits),
+		Rows:        1 << latBits,
+		Cols:        1 << lonBits,
+		geohashBits: bits,
+	}, nil
+}
+
+// Cell returns the row and column of the cell holding the position.  The bool
+// is false for positions outside of the Grid.
+func (g *Grid) Cell(lat, lon float64) (row, col int, ok bool) {
+	r := math.Floor((lat - g.MinLat) / g.LatStep)
+	c := math.Floor((lon - g.MinLon) / g.LonStep)
+	// The northern and eastern edges of a geohash grid belong to the last
+	// row and column as they do in a geohash.
+	if g.geohashBits > 0 {
+		r = math.Min(r, float64(g.Rows-1))
+		c = math.Min(c, float64(g.Cols-1))
+	}
+	if !(r >= 0 && r < float64(g.Rows) && c >= 0 && c < float64(g.Cols)) {
+		return 0, 0, false
+	}
+	return int(r), int(c), true
+}
+
+// Bounds returns the southwest and northeast corners of a cell.
+func (g *Grid) Bounds(row, col int) (minLat, minLon, maxLat, maxLon float64) {
+	minLat = g.MinLat + float64(row)*g.LatStep
+	minLon = g.MinLon + float64(col)*g.LonStep
+	return minLat, minLon, minLat + g.LatStep, minLon + g.LonStep
+}
+
+// Geohash returns the geohash of a cell in the format of a PrecisionGeohasher,
+// or the empty string when the Grid was not created by NewGeohashGrid.
+func (g *Grid) Geohash(row, col int) string {
+	if g.geohashBits == 0 {
+		return ""
+	}
+	minLat, minLon, maxLat, maxLon := g.Bounds(row, col)
+	return fmt.Sprintf("%#x", geohash.EncodeIntWithPrecision((minLat+maxLat)/2, (minLon+maxLon)/2, g.geohashBits))
+}
+
+// DefaultDensityGap is the longest time between two reports of a vessel that
+// is counted as vessel-hours when DensityOptions.MaxGap is zero.
+const DefaultDensityGap = 30 * time.Minute
+
+// DensityOptions configures a Density.  MaxGap is the longest time between two
+// consecutive reports of a vessel that is counted as vessel-hours, and is
+// DefaultDensityGap when zero.  When Category is not nil its Generator is
+// called on every Record and each category is counted separately as well as in
+// the totals.
+//
+//	opts := ais.DensityOptions{
+//		Category: &ais.NewField{Name: "Category", Required: []string{"VesselType"}, Gen: ais.VesselCategorizer{}},
+//	}
+type DensityOptions struct {
+	MaxGap   time.Duration
+	Category *NewField
+}
+
+// DensityStat selects the statistic of a Density that is written to a raster.
+type DensityStat int
+
+// The DensityStat values.
+const (
+	DensityReports DensityStat = iota
+	DensityVessels
+	DensityVesselHours
+)
+
+// String implements the Stringer interface for DensityStat.
+func (s DensityStat) String() string {
+	switch s {
+	case DensityReports:
+		return "reports"
+	case DensityVessels:
+		return "vessels"
+	case DensityVesselHours:
+		return "hours"
+	}
+	return "DensityStat(" + strconv.Itoa(int(s)) + ")"
+}
+
+// DensityCell holds the statistics of one cell of a Density.  Category is
+// empty for the totals of all vessels.  Reports is the number of Records in the
+// cell, Vessels the number of unique MMSI and VesselHours the time vessels spent
+// in the cell.
+type DensityCell struct {
+	Category    string
+	Row, Col    int
+	Reports     int
+	Vessels     int
+	VesselHours float64
+}
+
+// value returns the DensityStat s of the cell.
+func (c *DensityCell) value(s DensityStat) float64 {
+	switch s {
+	case DensityVessels:
+		return float64(c.Vessels)
+	case DensityVesselHours:
+		return c.VesselHours
+	}
+	return float64(c.Reports)
+}
+
+// Density counts the reports, unique vessels and vessel-hours of AIS Records
+// in each cell of a Grid.  The time between two consecutive reports of a vessel
+// is credited to the cell of the first report when it is no longer than the
+// MaxGap of the DensityOptions, so the Records of each vessel should be added in
+// time order.  Reports that are older than the previous report of their vessel
+// are counted but add no vessel-hours.
+type Density struct {
+	Grid *Grid
+
+	h       Headers
+	opts    DensityOptions
+	indices [4]int // MMSI, BaseDateTime, LAT and LON
+	catGen  func(Record) (Record, error)
+	cells   map[densityKey]*densityCell
+	last    map[string]densityReport // previous report by MMSI
+}
+
+type densityKey struct {
+	category string
+	row, col int
+}
+
+type densityCell struct {
+	reports int
+	vessels map[string]bool
+	hours   float64
+}
+
+type densityReport struct {
+	t        time.Time
+	key      densityKey
+	category string
+	in       bool
+}
+
+// NewDensity returns an empty *Density over grid for Records with Headers h,
+// which must contain MMSI, BaseDateTime, LAT and LON.
+func NewDensity(h Headers, grid *Grid, opts DensityOptions) (*Density, error) {
+	d := &Density{
+		Grid:  grid,
+		h:     h,
+		opts:  opts,
+		cells: make(map[densityKey]*densityCell),
+		last:  make(map[string]densityReport),
+	}
+	if d.opts.MaxGap == 0 {
+		d.opts.MaxGap = DefaultDensityGap
+	}
+	for i, name := range []string{"MMSI", "BaseDateTime", "LAT", "LON"} {
+		index, ok := h.Contains(name)
+		if !ok {
+			return nil, fmt.Errorf("density: headers does not contain %s", name)
+		}
+		d.indices[i] = index
+	}
+	if opts.Category != nil {
+		_, gen, err := appendGenerator(h, []NewField{*opts.Category})
+		if err != nil {
+			return nil, fmt.Errorf("density: %v", err)
+		}
+		d.catGen = gen
+	}
+	return d, nil
+}
+
+// Add counts a Record in the Density.  Records outside of the Grid are
+// ignored.  It returns a *RecordError when the MMSI, position or time of the
+// Record cannot be read or the category cannot be generated.
+func (d *Density) Add(rec *Record) error {
+	var lat, lon float64
+	for i, p := range []*float64{&lat, &lon} {
+		index := d.indices[2+i]
+		s, ok := rec.Value(index)
+		f, err := strconv.ParseFloat(s, 64)
+		if !ok || err != nil {
+			return &RecordError{Column: index, Err: fmt.Errorf("density: unable to parse %q", s)}
+		}
+		*p = f
+	}
+	mmsi, ok := rec.Value(d.indices[0])
+	if !ok {
+		return &RecordError{Column: d.indices[0], Err: fmt.Errorf("density: missing MMSI")}
+	}
+	t, err := d.h.ParseTime(*rec, d.indices[1])
+	if err != nil {
+		return &RecordError{Column: d.indices[1], Err: fmt.Errorf("density: %v", err)}
+	}
+	category := ""
+	if d.catGen != nil {
+		r, err := d.catGen((*rec)[:len(*rec):len(*rec)])
+		if err != nil {
+			return &RecordError{Column: -1, Err: fmt.Errorf("density: %v", err)}
+		}
+		category = r[len(r)-1]
+	}
+
+	row, col, in := d.Grid.Cell(lat, lon)
+	prev, seen := d.last[mmsi]
+	if seen && t.Before(prev.t) {
+		if in {
+			d.count(densityKey{"", row, col}, category, mmsi)
+		}
+		return nil
+	}
+	if seen && prev.in {
+		if dt := t.Sub(prev.t); dt > 0 && dt <= d.opts.MaxGap {
+			hours := dt.Hours()
+			d.cell(prev.key).hours += hours
+			if d.catGen != nil {
+				d.cell(densityKey{prev.category, prev.key.row, prev.key.col}).hours += hours
+			}
+		}
+	}
+	key := densityKey{"", row, col}
+	d.last[mmsi] = densityReport{t: t, key: key, category: category, in: in}
+	if in {
+		d.count(key, category, mmsi)
+	}
+	return nil
+}
+
+// count adds a report of mmsi to the totals cell of key and to the cell of the
+// category when the Density has categories.
+func (d *Density) count(key densityKey, category, mmsi string) {
+	keys := []densityKey{key}
+	if d.catGen != nil {
+		keys = append(keys, densityKey{category, key.row, key.col})
+	}
+	for _, k := range keys {
+		c := d.cell(k)
+		c.reports++
+		c.vessels[mmsi] = true
+	}
+}
+
+// cell returns the cell of key, creating it when needed.
+func (d *Density) cell(key densityKey) *densityCell {
+	c, ok := d.cells[key]
+	if !ok {
+		c = &densityCell{vessels: make(map[string]bool)}
+		d.cells[key] = c
+	}
+	return c
+}
+
+// Density reads the RecordSet and returns the *Density of its Records over
+// grid.  Malformed Records are handled by the ErrorPolicy of the RecordSet.
+//
+//	grid, _ := ais.NewGrid(47, 48.5, -123.5, -122, 0.01)
+//	d, err := rs.Density(grid, ais.DensityOptions{})
+//	err = d.WriteCSV(f)
+func (rs *RecordSet) Density(grid *Grid, opts DensityOptions) (*Density, error) {
+	d, err := NewDensity(rs.Headers(), grid, opts)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("density: %v", err)
+		}
+		if err := d.Add(rec); err != nil {
+			if err := rs.reject(*rec, -1, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Cells returns the cells of the Density that have at least one report or
+// vessel-hour, sorted by category and then by row and column.  The totals of
+// all vessels have the empty Category and come first.
+func (d *Density) Cells() []DensityCell {
+	cells := make([]DensityCell, 0, len(d.cells))
+	for k, c := range d.cells {
+		cells = append(cells, DensityCell{
+			Category:    k.category,
+			Row:         k.row,
+			Col:         k.col,
+			Reports:     c.reports,
+			Vessels:     len(c.vessels),
+			VesselHours: c.hours,
+		})
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Col < b.Col
+	})
+	return cells
+}
+
+// Categories returns the categories counted by the Density in sorted order.
+// It is empty when DensityOptions.Category was nil.
+func (d *Density) Categories() []string {
+	seen := make(map[string]bool)
+	var cats []string
+	for k := range d.cells {
+		if d.catGen != nil && !seen[k.category] && k.category != "" {
+			seen[k.category] = true
+			cats = append(cats, k.category)
+		}
+	}
+	sort.Strings(cats)
+	return cats
+}
+
+// WriteCSV writes the Cells of the Density to w as csv with the bounds of each
+// cell.  A Category field is written when the Density has categories and a
+// Geohash field when its Grid was created by NewGeohashGrid.
+func (d *Density) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	fields := []string{"Row", "Col", "MinLat", "MinLon", "MaxLat", "MaxLon"}
+	if d.catGen != nil {
+		fields = append([]string{"Category"}, fields...)
+	}
+	if d.Grid.geohashBits > 0 {
+		fields = append(fields, "Geohash")
+	}
+	fields = append(fields, "Reports", "Vessels", "VesselHours")
+	if err := cw.Write(fields); err != nil {
+		return fmt.Errorf("density: write csv: %v", err)
+	}
+	for _, c := range d.Cells() {
+		minLat, minLon, maxLat, maxLon := d.Grid.Bounds(c.Row, c.Col)
+		rec := []string{strconv.Itoa(c.Row), strconv.Itoa(c.Col), formatDegrees(minLat), formatDegrees(minLon), formatDegrees(maxLat), formatDegrees(maxLon)}
+		if d.catGen != nil {
+			rec = append([]string{c.Category}, rec...)
+		}
+		if d.Grid.geohashBits > 0 {
+			rec = append(rec, d.Grid.Geohash(c.Row, c.Col))
+		}
+		rec = append(rec, strconv.Itoa(c.Reports), strconv.Itoa(c.Vessels), strconv.FormatFloat(c.VesselHours, 'f', 3, 64))
+		if err := cw.Write(rec); err != nil {
+			return fmt.Errorf("density: write csv: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("density: write csv: %v", err)
+	}
+	return nil
+}
+
+// formatDegrees formats a latitude or longitude without trailing zeros.
+func formatDegrees(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// WriteGeoJSON writes the Cells of the Density to w as a GeoJSON
+// FeatureCollection with a Polygon Feature for each cell.  The properties of a
+// Feature are the fields written by WriteCSV other than the bounds.
+func (d *Density) WriteGeoJSON(w io.Writer) error {
+	var features []geoFeature
+	for _, c := range d.Cells() {
+		minLat, minLon, maxLat, maxLon := d.Grid.Bounds(c.Row, c.Col)
+		props := map[string]interface{}{
+			"row":         c.Row,
+			"col":         c.Col,
+			"reports":     c.Reports,
+			"vessels":     c.Vessels,
+			"vesselHours": c.VesselHours,
+		}
+		if d.catGen != nil {
+			props["category"] = c.Category
+		}
+		if d.Grid.geohashBits > 0 {
+			props["geohash"] = d.Grid.Geohash(c.Row, c.Col)
+		}
+		features = append(features, geoFeature{
+			geometries: []geoGeometry{cellPolygon(minLat, minLon, maxLat, maxLon)},
+			props:      props,
+		})
+	}
+	if err := writeGeoJSON(w, features); err != nil {
+		return fmt.Errorf("density: write geojson: %v", err)
+	}
+	return nil
+}
+
+// raster returns the values of stat for category in rows from north to south
+// and the row and column of the southwest cell.  A Grid created by NewGrid is
+// covered completely and a geohash Grid is cropped to the cells with data.  It
+// returns an error when the raster would have more than MaxGridCells cells.
+func (d *Density) raster(stat DensityStat, category string) (values [][]float64, row0, col0 int, err error) {
+	var cells []DensityCell
+	for _, c := range d.Cells() {
+		if c.Category == category {
+			cells = append(cells, c)
+		}
+	}
+	if len(cells) == 0 {
+		return nil, 0, 0, fmt.Errorf("density: no cells for category %q", category)
+	}
+	rows, cols := d.Grid.Rows, d.Grid.Cols
+	if d.Grid.geohashBits > 0 {
+		row0, col0 = cells[0].Row, cells[0].Col
+		maxRow, maxCol := row0, col0
+		for _, c := range cells {
+			row0, col0 = minInt(row0, c.Row), minInt(col0, c.Col)
+			maxRow, maxCol = maxInt(maxRow, c.Row), maxInt(maxCol, c.Col)
+		}
+		rows, cols = maxRow-row0+1, maxCol-col0+1
+	}
+	if float64(rows)*float64(cols) > MaxGridCells {
+		return nil, 0, 0, fmt.Errorf("density: raster of %d by %d cells is more than %d cells", rows, cols, MaxGridCells)
+	}
+	values = make([][]float64, rows)
+	for i := range values {
+		values[i] = make([]float64, cols)
+	}
+	for _, c := range cells {
+		values[rows-1-(c.Row-row0)][c.Col-col0] = c.value(stat)
+	}
+	return values, row0, col0, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// WriteASCIIGrid writes stat for category, or for all vessels when category is
+// empty, to w as an ESRI ASCII grid that GIS tools such as QGIS and GDAL open
+// as a georeferenced raster.  Cells without data are 0.  Grids whose cells are
+// not square are written with the dx and dy keys in place of cellsize.
+func (d *Density) WriteASCIIGrid(w io.Writer, stat DensityStat, category string) error {
+	values, row0, col0, err := d.raster(stat, category)
+	if err != nil {
+		return err
+	}
+	yll, xll, _, _ := d.Grid.Bounds(row0, col0)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ncols %d\nnrows %d\nxllcorner %s\nyllcorner %s\n", len(values[0]), len(values), formatDegrees(xll), formatDegrees(yll))
+	if d.Grid.LatStep == d.Grid.LonStep {
+		fmt.Fprintf(bw, "cellsize %s\n", formatDegrees(d.Grid.LatStep))
+	} else {
+		fmt.Fprintf(bw, "dx %s\ndy %s\n", formatDegrees(d.Grid.LonStep), formatDegrees(d.Grid.LatStep))
+	}
+	fmt.Fprintln(bw, "NODATA_value -9999")
+	for _, row := range values {
+		for j, v := range row {
+			if j > 0 {
+				bw.WriteByte(' ')
+			}
+			bw.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("density: write grid: %v", err)
+	}
+	return nil
+}
+
+// WritePNG writes stat for category, or for all vessels when category is
+// empty, to w as a PNG image with one pixel per cell and north at the top.
+// Empty cells are transparent and the others are shaded from yellow to red on
+// a logarithmic scale of the largest value.
+func (d *Density) WritePNG(w io.Writer, stat DensityStat, category string) error {
+	values, _, _, err := d.raster(stat, category)
+	if err != nil {
+		return err
+	}
+	max := 0.0
+	for _, row := range values {
+		for _, v := range row {
+			max = math.Max(max, v)
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, len(values[0]), len(values)))
+	for y, row := range values {
+		for x, v := range row {
+			if v <= 0 {
+				continue
+			}
+			f := math.Log1p(v) / math.Log1p(max)
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, G: uint8(255 * (1 - f)), B: 0, A: uint8(96 + 159*f)})
+		}
+	}
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("density: write png: %v", err)
+	}
+	return nil
+}
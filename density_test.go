@@ -0,0 +1,339 @@
+package ais
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// densityData has two vessels in a grid of four cells and a third vessel
+// outside of it.  Vessel 1 spends twenty minutes in the southwest cell and then
+// reports from the northeast cell twice with a gap longer than MaxGap.
+const densityData = `MMSI,BaseDateTime,LAT,LON,VesselType
+1,2017-12-01T00:00:00,36.05,-76.05,70
+2,2017-12-01T00:05:00,36.05,-76.05,60
+1,2017-12-01T00:10:00,36.05,-76.05,70
+2,2017-12-01T00:15:00,36.05,-76.05,60
+1,2017-12-01T00:20:00,36.15,-75.95,70
+3,2017-12-01T00:30:00,40.00,-70.00,70
+1,2017-12-01T02:00:00,36.15,-75.95,70
+`
+
+// densityGrid returns the 2 by 2 Grid of 0.1 degree cells of densityData.
+func densityGrid(t *testing.T) *Grid {
+	g, err := NewGrid(36, 36.2, -76.1, -75.9, 0.1)
+	if err != nil {
+		t.Fatalf("NewGrid() error = %v", err)
+	}
+	return g
+}
+
+// densityOf returns the Density of data over g.
+func densityOf(t *testing.T, data string, g *Grid, opts DensityOptions) *Density {
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	d, err := rs.Density(g, opts)
+	if err != nil {
+		t.Fatalf("RecordSet.Density() error = %v", err)
+	}
+	return d
+}
+
+func TestNewGrid(t *testing.T) {
+	tests := []struct {
+		name    string
+		bounds  [5]float64
+		rows    int
+		cols    int
+		wantErr bool
+	}{
+		{"exact", [5]float64{36, 37, -77, -75, 0.5}, 2, 4, false},
+		{"partial cell", [5]float64{36, 37.2, -77, -75, 0.5}, 3, 4, false},
+		{"empty box", [5]float64{37, 36, -77, -75, 0.5}, 0, 0, true},
+		{"zero size", [5]float64{36, 37, -77, -75, 0}, 0, 0, true},
+		{"too many cells", [5]float64{-90, 90, -180, 180, 0.01}, 0, 0, true},
+		{"tiny size", [5]float64{36, 37, -77, -75, 1e-300}, 0, 0, true},
+		{"globe", [5]float64{-90, 90, -180, 180, 0.1}, 1800, 3600, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.bounds
+			g, err := NewGrid(b[0], b[1], b[2], b[3], b[4])
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGrid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (g.Rows != tt.rows || g.Cols != tt.cols) {
+				t.Errorf("NewGrid() = %d rows and %d cols, want %d and %d", g.Rows, g.Cols, tt.rows, tt.cols)
+			}
+		})
+	}
+}
+
+func TestGrid_Cell(t *testing.T) {
+	g, _ := NewGrid(36, 37, -77, -75, 0.5)
+	tests := []struct {
+		lat, lon float64
+		row, col int
+		ok       bool
+	}{
+		{36, -77, 0, 0, true},
+		{36.7, -75.1, 1, 3, true},
+		{37, -76, 0, 0, false},
+		{35.9, -76, 0, 0, false},
+	}
+	for _, tt := range tests {
+		row, col, ok := g.Cell(tt.lat, tt.lon)
+		if row != tt.row || col != tt.col || ok != tt.ok {
+			t.Errorf("Grid.Cell(%v, %v) = %d, %d, %v, want %d, %d, %v", tt.lat, tt.lon, row, col, ok, tt.row, tt.col, tt.ok)
+		}
+	}
+	minLat, minLon, maxLat, maxLon := g.Bounds(1, 3)
+	if minLat != 36.5 || minLon != -75.5 || maxLat != 37 || maxLon != -75 {
+		t.Errorf("Grid.Bounds(1, 3) = %v, %v, %v, %v", minLat, minLon, maxLat, maxLon)
+	}
+}
+
+func TestNewGeohashGrid(t *testing.T) {
+	if _, err := NewGeohashGrid(63); err == nil {
+		t.Errorf("NewGeohashGrid(63) error = nil, want error")
+	}
+	// The cells of a geohash Grid are the geohashes of a PrecisionGeohasher.
+	positions := [][2]float64{{36.5, -76.5}, {-33.9, 151.2}, {89.99, 179.99}, {-90, -180}, {0, 0}}
+	for _, bits := range []uint{2, 15, 22, 40} {
+		g, err := NewGeohashGrid(bits)
+		if err != nil {
+			t.Fatalf("NewGeohashGrid(%d) error = %v", bits, err)
+		}
+		for _, p := range positions {
+			rec := Record{formatDegrees(p[0]), formatDegrees(p[1])}
+			want, err := PrecisionGeohasher(bits).Generate(rec, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			row, col, ok := g.Cell(p[0], p[1])
+			if got := g.Geohash(row, col); !ok || got != string(want) {
+				t.Errorf("%d bits: Grid.Geohash() at %v = %s, %v, want %s", bits, p, got, ok, want)
+			}
+		}
+	}
+}
+
+func TestRecordSet_Density(t *testing.T) {
+	d := densityOf(t, densityData, densityGrid(t), DensityOptions{})
+	want := []DensityCell{
+		{Row: 0, Col: 0, Reports: 4, Vessels: 2, VesselHours: 0.5},
+		{Row: 1, Col: 1, Reports: 2, Vessels: 1},
+	}
+	if got := d.Cells(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Density.Cells() = %+v, want %+v", got, want)
+	}
+	if cats := d.Categories(); len(cats) != 0 {
+		t.Errorf("Density.Categories() = %v, want none", cats)
+	}
+
+	// A longer MaxGap counts the time between the last two reports of vessel 1.
+	d = densityOf(t, densityData, densityGrid(t), DensityOptions{MaxGap: 2 * time.Hour})
+	if got, want := d.Cells()[1].VesselHours, (100 * time.Minute).Hours(); got != want {
+		t.Errorf("VesselHours with a two hour MaxGap = %v, want %v", got, want)
+	}
+}
+
+func TestRecordSet_DensityCategory(t *testing.T) {
+	opts := DensityOptions{
+		Category: &NewField{Name: "Category", Required: []string{"VesselType"}, Gen: VesselCategorizer{}},
+	}
+	d := densityOf(t, densityData, densityGrid(t), opts)
+	want := []DensityCell{
+		{Row: 0, Col: 0, Reports: 4, Vessels: 2, VesselHours: 0.5},
+		{Row: 1, Col: 1, Reports: 2, Vessels: 1},
+		{Category: "Cargo", Row: 0, Col: 0, Reports: 2, Vessels: 1, VesselHours: 20.0 / 60},
+		{Category: "Cargo", Row: 1, Col: 1, Reports: 2, Vessels: 1},
+		{Category: "Passenger", Row: 0, Col: 0, Reports: 2, Vessels: 1, VesselHours: 10.0 / 60},
+	}
+	if got := d.Cells(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Density.Cells() = %+v, want %+v", got, want)
+	}
+	if cats := d.Categories(); !reflect.DeepEqual(cats, []string{"Cargo", "Passenger"}) {
+		t.Errorf("Density.Categories() = %v", cats)
+	}
+
+	if _, err := NewDensity(Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}, densityGrid(t), opts); err == nil {
+		t.Errorf("NewDensity() without VesselType error = nil, want error")
+	}
+}
+
+func TestRecordSet_DensityErrors(t *testing.T) {
+	data := densityData + "4,2017-12-01T00:00:00,bad,-76.05,70\n4,notatime,36.05,-76.05,70\n"
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	if _, err := rs.Density(densityGrid(t), DensityOptions{}); err == nil || !strings.Contains(err.Error(), "line 9: LAT") {
+		t.Errorf("RecordSet.Density() error = %v, want a LAT error on line 9", err)
+	}
+
+	rs, _ = NewRecordSetFromReader(strings.NewReader(data))
+	var rejects RejectList
+	rs.SetErrorPolicy(RejectInvalid, &rejects)
+	d, err := rs.Density(densityGrid(t), DensityOptions{})
+	if err != nil {
+		t.Fatalf("RecordSet.Density() error = %v", err)
+	}
+	if len(d.Cells()) != 2 || len(rejects) != 2 || rejects[1].Field != "BaseDateTime" {
+		t.Errorf("RecordSet.Density() = %+v with rejects %v", d.Cells(), rejects)
+	}
+
+	if _, err := NewDensity(Headers{Fields: []string{"MMSI", "LAT", "LON"}}, densityGrid(t), DensityOptions{}); err == nil {
+		t.Errorf("NewDensity() without BaseDateTime error = nil, want error")
+	}
+}
+
+func TestDensity_WriteCSV(t *testing.T) {
+	d := densityOf(t, densityData, densityGrid(t), DensityOptions{})
+	var buf bytes.Buffer
+	if err := d.WriteCSV(&buf); err != nil {
+		t.Fatalf("Density.WriteCSV() error = %v", err)
+	}
+	want := "Row,Col,MinLat,MinLon,MaxLat,MaxLon,Reports,Vessels,VesselHours\n" +
+		"0,0,36,-76.1,36.1,-76,4,2,0.500\n" +
+		"1,1,36.1,-76,36.2,-75.9,2,1,0.000\n"
+	if got := buf.String(); !similarCSV(got, want) {
+		t.Errorf("Density.WriteCSV() wrote\n%s\nwant\n%s", got, want)
+	}
+
+	g, _ := NewGeohashGrid(10)
+	d = densityOf(t, densityData, g, DensityOptions{})
+	buf.Reset()
+	if err := d.WriteCSV(&buf); err != nil {
+		t.Fatalf("Density.WriteCSV() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "Row,Col,MinLat,MinLon,MaxLat,MaxLon,Geohash,Reports") {
+		t.Errorf("Density.WriteCSV() of a geohash Grid wrote\n%s", buf.String())
+	}
+}
+
+// similarCSV reports whether two csv documents have the same fields, comparing
+// numbers to six decimal places so that floating point rounding in the cell
+// bounds does not matter.
+func similarCSV(a, b string) bool {
+	la, lb := strings.Split(a, "\n"), strings.Split(b, "\n")
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		fa, fb := strings.Split(la[i], ","), strings.Split(lb[i], ",")
+		if len(fa) != len(fb) {
+			return false
+		}
+		for j := range fa {
+			if fa[j] == fb[j] {
+				continue
+			}
+			x, errx := Record{fa[j]}.ParseFloat(0)
+			y, erry := Record{fb[j]}.ParseFloat(0)
+			if errx != nil || erry != nil || x-y > 1e-6 || y-x > 1e-6 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestDensity_WriteGeoJSON(t *testing.T) {
+	d := densityOf(t, densityData, densityGrid(t), DensityOptions{})
+	var buf bytes.Buffer
+	if err := d.WriteGeoJSON(&buf); err != nil {
+		t.Fatalf("Density.WriteGeoJSON() error = %v", err)
+	}
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates [][][2]float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("Density.WriteGeoJSON() wrote invalid json: %v", err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 2 {
+		t.Fatalf("Density.WriteGeoJSON() = %+v, want a FeatureCollection of 2 Features", fc)
+	}
+	f := fc.Features[0]
+	ring := f.Geometry.Coordinates[0]
+	if f.Geometry.Type != "Polygon" || len(ring) != 5 || ring[0] != ring[4] || ring[0][1] != 36 {
+		t.Errorf("Feature geometry = %+v", f.Geometry)
+	}
+	if f.Properties["reports"] != 4.0 || f.Properties["vessels"] != 2.0 || f.Properties["vesselHours"] != 0.5 {
+		t.Errorf("Feature properties = %v", f.Properties)
+	}
+}
+
+func TestDensity_WriteASCIIGrid(t *testing.T) {
+	d := densityOf(t, densityData, densityGrid(t), DensityOptions{})
+	tests := []struct {
+		stat DensityStat
+		want string
+	}{
+		{DensityReports, "0 2\n4 0\n"},
+		{DensityVessels, "0 1\n2 0\n"},
+		{DensityVesselHours, "0 0\n0.5 0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stat.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := d.WriteASCIIGrid(&buf, tt.stat, ""); err != nil {
+				t.Fatalf("Density.WriteASCIIGrid() error = %v", err)
+			}
+			header := "ncols 2\nnrows 2\nxllcorner -76.1\nyllcorner 36\ncellsize 0.1\nNODATA_value -9999\n"
+			if got := buf.String(); got != header+tt.want {
+				t.Errorf("Density.WriteASCIIGrid() wrote\n%s\nwant\n%s", got, header+tt.want)
+			}
+		})
+	}
+	if err := d.WriteASCIIGrid(&bytes.Buffer{}, DensityReports, "Cargo"); err == nil {
+		t.Errorf("Density.WriteASCIIGrid() of a missing category error = nil, want error")
+	}
+
+	// A geohash Grid is cropped to the cells with data.
+	g, _ := NewGeohashGrid(20)
+	d = densityOf(t, densityData, g, DensityOptions{})
+	var buf bytes.Buffer
+	if err := d.WriteASCIIGrid(&buf, DensityReports, ""); err != nil {
+		t.Fatalf("Density.WriteASCIIGrid() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "ncols ") || !strings.Contains(buf.String(), "\ndx ") {
+		t.Errorf("Density.WriteASCIIGrid() of a geohash Grid wrote\n%s", buf.String())
+	}
+}
+
+func TestDensity_WritePNG(t *testing.T) {
+	d := densityOf(t, densityData, densityGrid(t), DensityOptions{})
+	var buf bytes.Buffer
+	if err := d.WritePNG(&buf, DensityReports, ""); err != nil {
+		t.Fatalf("Density.WritePNG() error = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Density.WritePNG() wrote an invalid png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("image bounds = %v, want 2 by 2", b)
+	}
+	// The southwest cell is at the bottom left and the empty cells are
+	// transparent.
+	if _, _, _, a := img.At(0, 1).RGBA(); a != 0xffff {
+		t.Errorf("busiest cell alpha = %#x, want opaque", a)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("empty cell alpha = %#x, want transparent", a)
+	}
+}
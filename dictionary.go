@@ -0,0 +1,143 @@
+package ais
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// FieldType is the type of the values held by a field as declared in a data
+// dictionary.
+type FieldType string
+
+// The FieldTypes recognized in a data dictionary.  TypeTime values are parsed
+// with Record.ParseTime.
+const (
+	TypeString FieldType = "string"
+	TypeInt    FieldType = "int"
+	TypeFloat  FieldType = "float"
+	TypeTime   FieldType = "time"
+)
+
+// Definition is the data dictionary entry for a single field of a RecordSet.
+// A data dictionary is a JSON array of Definitions, for example
+//
+//	[
+//	  {"fieldname": "MMSI", "description": "Maritime Mobile Service Identity", "type": "int"},
+//	  {"fieldname": "SOG", "description": "Speed over ground", "units": "knots", "type": "float"}
+//	]
+//
+// Units and Type are optional.  A field without a Type is not type checked.
+type Definition struct {
+	Fieldname   string    `json:"fieldname"`
+	Description string    `json:"description"`
+	Units       string    `json:"units,omitempty"`
+	Type        FieldType `json:"type,omitempty"`
+}
+
+// NewHeaders returns Headers for the fields with the data dictionary defs.
+// Pass nil for defs to create Headers without a dictionary.  Definitions for
+// fieldnames that are not in fields are kept so that they apply to fields added
+// later with AppendField.
+func NewHeaders(fields []string, defs []Definition) Headers {
+	h := Headers{Fields: fields}
+	if len(defs) > 0 {
+		h.dictionary = make(map[string]Definition, len(defs))
+		for _, d := range defs {
+			h.dictionary[d.Fieldname] = d
+		}
+	}
+	return h
+}
+
+// ParseDictionary decodes a JSON data dictionary into a slice of Definitions.
+// It returns an error for malformed JSON, a Definition without a fieldname, a
+// fieldname that is defined twice and an unknown Type.
+func ParseDictionary(jsonBlob []byte) ([]Definition, error) {
+	var defs []Definition
+	if err := json.Unmarshal(jsonBlob, &defs); err != nil {
+		return nil, fmt.Errorf("parse dictionary: %v", err)
+	}
+	seen := make(map[string]bool)
+	for i, d := range defs {
+		if d.Fieldname == "" {
+			return nil, fmt.Errorf("parse dictionary: definition %d has no fieldname", i)
+		}
+		if seen[d.Fieldname] {
+			return nil, fmt.Errorf("parse dictionary: %s is defined more than once", d.Fieldname)
+		}
+		seen[d.Fieldname] = true
+		switch d.Type {
+		case "", TypeString, TypeInt, TypeFloat, TypeTime:
+		default:
+			return nil, fmt.Errorf("parse dictionary: %s has unknown type %q", d.Fieldname, d.Type)
+		}
+	}
+	return defs, nil
+}
+
+// SetDictionary assigns the JSON data dictionary in jsonBlob to the Headers of
+// the RecordSet.  Any previous dictionary is replaced.
+func (rs *RecordSet) SetDictionary(jsonBlob []byte) error {
+	defs, err := ParseDictionary(jsonBlob)
+	if err != nil {
+		return err
+	}
+	rs.h.dictionary = NewHeaders(rs.h.Fields, defs).dictionary
+	return nil
+}
+
+// Definition returns the data dictionary entry for field.  The bool is false
+// when the Headers have no dictionary or the dictionary does not define field.
+func (h Headers) Definition(field string) (Definition, bool) {
+	d, ok := h.dictionary[field]
+	return d, ok
+}
+
+// Definitions returns the data dictionary entries for the Fields in the order
+// of the Fields.  Fields that are not defined are omitted.
+func (h Headers) Definitions() []Definition {
+	var defs []Definition
+	for _, f := range h.Fields {
+		if d, ok := h.dictionary[f]; ok {
+			defs = append(defs, d)
+		}
+	}
+	return defs
+}
+
+// typeReasons are the ValidationError reasons for values of the wrong type.
+var typeReasons = map[FieldType]string{
+	TypeInt:   "not an integer",
+	TypeFloat: "not a number",
+	TypeTime:  "unparsable timestamp",
+}
+
+// checkType returns a non-empty reason when s is not a value of type t.  Blank
+// values are allowed for every type because AIS data routinely omits fields.
+func checkType(t FieldType) func(string) string {
+	return func(s string) string {
+		if isBlank(s) {
+			return ""
+		}
+		var err error
+		switch t {
+		case TypeInt:
+			_, err = strconv.ParseInt(s, 10, 64)
+		case TypeFloat:
+			var f float64
+			f, err = strconv.ParseFloat(s, 64)
+			if err == nil && math.IsNaN(f) {
+				return typeReasons[t]
+			}
+		case TypeTime:
+			tmp := Record{s}
+			_, err = tmp.ParseTime(0)
+		}
+		if err != nil {
+			return typeReasons[t]
+		}
+		return ""
+	}
+}
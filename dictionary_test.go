@@ -0,0 +1,149 @@
+package ais
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseDictionary(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantLen int
+		wantErr bool
+	}{
+		{"valid", `[{"fieldname": "LAT", "description": "Latitude", "units": "degrees", "type": "float"}]`, 1, false},
+		{"no type", `[{"fieldname": "VesselName", "description": "Name"}]`, 1, false},
+		{"empty", `[]`, 0, false},
+		{"malformed", `[{"fieldname": "LAT"`, 0, true},
+		{"no fieldname", `[{"description": "Latitude"}]`, 0, true},
+		{"duplicate", `[{"fieldname": "LAT"}, {"fieldname": "LAT"}]`, 0, true},
+		{"unknown type", `[{"fieldname": "LAT", "type": "double"}]`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, err := ParseDictionary([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDictionary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(defs) != tt.wantLen {
+				t.Errorf("ParseDictionary() returned %d definitions, want %d", len(defs), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestRecordSet_SetDictionary(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	jsonBlob, err := ioutil.ReadFile("testdata/dictionary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.SetDictionary(jsonBlob); err != nil {
+		t.Fatalf("RecordSet.SetDictionary() error = %v", err)
+	}
+	h := rs.Headers()
+	d, ok := h.Definition("SOG")
+	if !ok || d.Units != "knots" || d.Type != TypeFloat {
+		t.Errorf("Headers.Definition(SOG) = %+v, %v", d, ok)
+	}
+	if n := len(h.Definitions()); n != len(h.Fields) {
+		t.Errorf("Headers.Definitions() returned %d definitions, want %d", n, len(h.Fields))
+	}
+
+	s := h.String()
+	for _, want := range []string{"Definition", "knots", "Speed over ground"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Headers.String() = \n%s\nwant it to contain %q", s, want)
+		}
+	}
+
+	// AppendField keeps the dictionary.
+	rs2, err := rs.AppendField("Geohash", []string{"LAT", "LON"}, NewGeohasher(rs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rs2.Headers().Definition("SOG"); !ok {
+		t.Errorf("AppendField() did not keep the dictionary")
+	}
+
+	if err := rs.SetDictionary([]byte("not json")); err == nil {
+		t.Errorf("RecordSet.SetDictionary() error = nil, want error")
+	}
+}
+
+func TestHeaders_StringWithoutDictionary(t *testing.T) {
+	h := NewHeaders([]string{"MMSI", "LAT"}, nil)
+	if _, ok := h.Definition("MMSI"); ok {
+		t.Errorf("Headers.Definition() ok = true for Headers without a dictionary")
+	}
+	want := "Index  Header\n0      MMSI\n1      LAT\n"
+	if got := h.String(); got != want {
+		t.Errorf("Headers.String() = %q, want %q", got, want)
+	}
+}
+
+func TestValidator_TypeCheck(t *testing.T) {
+	defs := []Definition{
+		{Fieldname: "VesselType", Type: TypeInt},
+		{Fieldname: "Length", Type: TypeFloat},
+		{Fieldname: "Updated", Type: TypeTime},
+		{Fieldname: "VesselName", Type: TypeString},
+		{Fieldname: "LAT", Type: TypeString}, // ignored, LAT has its own rule
+	}
+	h := NewHeaders([]string{"MMSI", "BaseDateTime", "LAT", "LON", "VesselName", "VesselType", "Length", "Updated"}, defs)
+	v, err := NewValidator(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		rec  Record
+		want []ValidationError
+	}{
+		{
+			name: "valid",
+			rec:  Record{"477307901", "2017-12-01T00:00:01", "31.9", "-76.3", "FIRST", "1004", "337", "2017-12-01T00:00:01"},
+		},
+		{
+			name: "blanks are valid",
+			rec:  Record{"477307901", "2017-12-01T00:00:01", "31.9", "-76.3", "", "", "", ""},
+		},
+		{
+			name: "wrong types",
+			rec:  Record{"477307901", "2017-12-01T00:00:01", "31.9", "-76.3", "FIRST", "1004.5", "long", "yesterday"},
+			want: []ValidationError{
+				{"VesselType", "1004.5", "not an integer"},
+				{"Length", "long", "not a number"},
+				{"Updated", "yesterday", "unparsable timestamp"},
+			},
+		},
+		{
+			name: "nan float",
+			rec:  Record{"477307901", "2017-12-01T00:00:01", "31.9", "-76.3", "FIRST", "1004", "NaN", ""},
+			want: []ValidationError{
+				{"Length", "NaN", "not a number"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.Validate(&tt.rec)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Validator.Validate() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Validator.Validate()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
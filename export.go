@@ -0,0 +1,503 @@
+package ais
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// GeoJSONExt and KMLExt are the filename extensions that Interactions.Save
+// uses to write GeoJSON and KML.  Both formats open directly in QGIS, and KML
+// opens in Google Earth.
+const (
+	GeoJSONExt = ".geojson"
+	KMLExt     = ".kml"
+)
+
+// geoFeature is a feature written by the GeoJSON and KML exporters.  Times that
+// are zero are not written.
+type geoFeature struct {
+	name       string
+	geometries []geoGeometry
+	props      map[string]interface{}
+	begin, end time.Time
+}
+
+// geoGeometry is a Point, MultiPoint, LineString or Polygon.  The coordinates
+// are longitude and latitude pairs, and the ring of a Polygon is closed.
+type geoGeometry struct {
+	kind   string
+	coords [][2]float64
+}
+
+// MarshalJSON implements the json.Marshaler interface to write a GeoJSON
+// geometry object.
+func (g geoGeometry) MarshalJSON() ([]byte, error) {
+	var coords interface{} = g.coords
+	switch g.kind {
+	case "Point":
+		coords = g.coords[0]
+	case "Polygon":
+		coords = [][][2]float64{g.coords}
+	}
+	return json.Marshal(struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}{g.kind, coords})
+}
+
+// cellPolygon returns the Polygon of a cell with its ring in counterclockwise
+// order.
+func cellPolygon(minLat, minLon, maxLat, maxLon float64) geoGeometry {
+	return geoGeometry{
+		kind:   "Polygon",
+		coords: [][2]float64{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat}},
+	}
+}
+
+// formatGeoTime formats a time of a geoFeature.
+func formatGeoTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeGeoJSON writes features to w as a GeoJSON FeatureCollection.  A feature
+// with several geometries is written as one Feature for each with the same
+// properties, so that GIS tools can load each geometry type as a layer.
+func writeGeoJSON(w io.Writer, features []geoFeature) error {
+	type feature struct {
+		Type       string                 `json:"type"`
+		Geometry   geoGeometry            `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+	for _, f := range features {
+		for _, g := range f.geometries {
+			fc.Features = append(fc.Features, feature{"Feature", g, f.props})
+		}
+	}
+	return json.NewEncoder(w).Encode(fc)
+}
+
+type kmlCoordinates struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Outer kmlCoordinates `xml:"outerBoundaryIs>LinearRing"`
+}
+
+type kmlGeometries struct {
+	Points      []kmlCoordinates `xml:"Point"`
+	LineStrings []kmlCoordinates `xml:"LineString"`
+	Polygons    []kmlPolygon     `xml:"Polygon"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+type kmlPlacemark struct {
+	Name     string       `xml:"name"`
+	TimeSpan *kmlTimeSpan `xml:"TimeSpan,omitempty"`
+	Data     []kmlData    `xml:"ExtendedData>Data"`
+	kmlGeometries
+	MultiGeometry *kmlGeometries `xml:"MultiGeometry,omitempty"`
+}
+
+// kmlString returns the coordinates of a KML geometry.
+func kmlString(coords [][2]float64) kmlCoordinates {
+	s := make([]string, len(coords))
+	for i, c := range coords {
+		s[i] = formatDegrees(c[0]) + "," + formatDegrees(c[1])
+	}
+	return kmlCoordinates{strings.Join(s, " ")}
+}
+
+// writeKML writes features to w as a KML Document with the given name and a
+// Placemark for each feature.  The properties of a feature are written as
+// ExtendedData and a feature with several geometries or a MultiPoint is written
+// as a MultiGeometry.
+func writeKML(w io.Writer, name string, features []geoFeature) error {
+	doc := struct {
+		XMLName    xml.Name       `xml:"kml"`
+		Xmlns      string         `xml:"xmlns,attr"`
+		Name       string         `xml:"Document>name"`
+		Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	}{Xmlns: "http://www.opengis.net/kml/2.2", Name: name}
+	for _, f := range features {
+		pm := kmlPlacemark{Name: f.name}
+		if !f.begin.IsZero() {
+			pm.TimeSpan = &kmlTimeSpan{formatGeoTime(f.begin), formatGeoTime(f.end)}
+		}
+		keys := make([]string, 0, len(f.props))
+		for k := range f.props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			pm.Data = append(pm.Data, kmlData{k, fmt.Sprint(f.props[k])})
+		}
+		var geoms kmlGeometries
+		for _, g := range f.geometries {
+			switch g.kind {
+			case "Point":
+				geoms.Points = append(geoms.Points, kmlString(g.coords))
+			case "MultiPoint":
+				for _, c := range g.coords {
+					geoms.Points = append(geoms.Points, kmlString([][2]float64{c}))
+				}
+			case "LineString":
+				geoms.LineStrings = append(geoms.LineStrings, kmlString(g.coords))
+			case "Polygon":
+				geoms.Polygons = append(geoms.Polygons, kmlPolygon{kmlString(g.coords)})
+			}
+		}
+		if len(geoms.Points)+len(geoms.LineStrings)+len(geoms.Polygons) == 1 {
+			pm.kmlGeometries = geoms
+		} else {
+			pm.MultiGeometry = &geoms
+		}
+		doc.Placemarks = append(doc.Placemarks, pm)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// trackPoint is a report of a vessel in a track.
+type trackPoint struct {
+	t   time.Time
+	pos [2]float64
+}
+
+// WriteTracksGeoJSON reads the RecordSet and writes the track of each vessel to
+// w as a GeoJSON FeatureCollection.  Each track is a LineString of the reports of
+// one MMSI in time order, or a Point for a vessel with a single report, with the
+// properties mmsi, reports, start, end and vesselName when the Headers contain
+// VesselName.  The tracks are in order of MMSI.  Records without an MMSI or
+// whose time cannot be parsed or whose position is not a finite number are
+// handled by the ErrorPolicy of the RecordSet.
+func (rs *RecordSet) WriteTracksGeoJSON(w io.Writer) error {
+	features, err := rs.tracks()
+	if err != nil {
+		return err
+	}
+	if err := writeGeoJSON(w, features); err != nil {
+		return fmt.Errorf("tracks: write geojson: %v", err)
+	}
+	return nil
+}
+
+// WriteTracksKML is WriteTracksGeoJSON for KML.  Each track is a Placemark
+// named for the vessel with a TimeSpan from its first to its last report, so
+// that the time slider of Google Earth shows the vessels underway.
+func (rs *RecordSet) WriteTracksKML(w io.Writer) error {
+	features, err := rs.tracks()
+	if err != nil {
+		return err
+	}
+	if err := writeKML(w, "Tracks", features); err != nil {
+		return fmt.Errorf("tracks: write kml: %v", err)
+	}
+	return nil
+}
+
+// tracks reads the RecordSet and returns a feature for the track of each
+// vessel.
+func (rs *RecordSet) tracks() ([]geoFeature, error) {
+	idx, ok := rs.Headers().ContainsMulti("MMSI", "BaseDateTime", "LAT", "LON")
+	if !ok {
+		return nil, fmt.Errorf("tracks: headers must contain MMSI, BaseDateTime, LAT and LON")
+	}
+	nameIndex, hasName := rs.Headers().Contains("VesselName")
+	tracks := make(map[string][]trackPoint)
+	names := make(map[string]string)
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tracks: %v", err)
+		}
+		p, err := rs.h.position(*rec, idx["BaseDateTime"].Idx, idx["LAT"].Idx, idx["LON"].Idx)
+		if err != nil {
+			if err := rs.reject(*rec, -1, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		mmsi, ok := rec.Value(idx["MMSI"].Idx)
+		if !ok {
+			if err := rs.reject(*rec, idx["MMSI"].Idx, fmt.Errorf("missing MMSI")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		tracks[mmsi] = append(tracks[mmsi], p)
+		if name, ok := rec.Value(nameIndex); hasName && ok && strings.TrimSpace(name) != "" {
+			names[mmsi] = strings.TrimSpace(name)
+		}
+	}
+
+	mmsis := make([]string, 0, len(tracks))
+	for mmsi := range tracks {
+		mmsis = append(mmsis, mmsi)
+	}
+	sort.Strings(mmsis)
+	features := make([]geoFeature, 0, len(mmsis))
+	for _, mmsi := range mmsis {
+		track := tracks[mmsi]
+		sort.SliceStable(track, func(i, j int) bool { return track[i].t.Before(track[j].t) })
+		g := geoGeometry{kind: "LineString"}
+		for _, p := range track {
+			g.coords = append(g.coords, p.pos)
+		}
+		if len(g.coords) == 1 {
+			g.kind = "Point"
+		}
+		first, last := track[0].t, track[len(track)-1].t
+		f := geoFeature{
+			name:       mmsi,
+			geometries: []geoGeometry{g},
+			props: map[string]interface{}{
+				"mmsi":    mmsi,
+				"reports": len(track),
+				"start":   formatGeoTime(first),
+				"end":     formatGeoTime(last),
+			},
+			begin: first,
+			end:   last,
+		}
+		if name, ok := names[mmsi]; ok {
+			f.name = name
+			f.props["vesselName"] = name
+		}
+		features = append(features, f)
+	}
+	return features, nil
+}
+
+// position returns the time and position of rec.  A position that cannot be
+// parsed or is not finite, which could not be encoded, is a *RecordError.
+func (h Headers) position(rec Record, timeIndex, latIndex, lonIndex int) (trackPoint, error) {
+	var p trackPoint
+	t, err := h.ParseTime(rec, timeIndex)
+	if err != nil {
+		return p, &RecordError{Column: timeIndex, Err: err}
+	}
+	p.t = t
+	for i, index := range []int{lonIndex, latIndex} {
+		s, ok := rec.Value(index)
+		f, err := strconv.ParseFloat(s, 64)
+		if !ok || err != nil {
+			return p, &RecordError{Column: index, Err: fmt.Errorf("unable to parse %q", s)}
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return p, &RecordError{Column: index, Err: fmt.Errorf("%q is not a finite coordinate", s)}
+		}
+		p.pos[i] = f
+	}
+	return p, nil
+}
+
+// WriteGeoJSON writes the ClusterMap to w as a GeoJSON FeatureCollection.  Each
+// Cluster is written as a Polygon Feature of its geohash cell and a MultiPoint
+// Feature of the positions of its Records, both with the properties geohash,
+// reports, vessels, start and end.  Bits is the precision of the geohashes,
+// and h the Headers of the Records, which must contain MMSI, BaseDateTime, LAT
+// and LON.  Records whose position or time cannot be parsed are left out of
+// the points and times.  The Clusters are in order of geohash.
+func (cm ClusterMap) WriteGeoJSON(w io.Writer, h Headers, bits uint) error {
+	features, err := cm.features(h, bits)
+	if err != nil {
+		return err
+	}
+	if err := writeGeoJSON(w, features); err != nil {
+		return fmt.Errorf("clusters: write geojson: %v", err)
+	}
+	return nil
+}
+
+// WriteKML is WriteGeoJSON for KML.  Each Cluster is a Placemark named for its
+// geohash with a MultiGeometry of the cell and the points.
+func (cm ClusterMap) WriteKML(w io.Writer, h Headers, bits uint) error {
+	features, err := cm.features(h, bits)
+	if err != nil {
+		return err
+	}
+	if err := writeKML(w, "Clusters", features); err != nil {
+		return fmt.Errorf("clusters: write kml: %v", err)
+	}
+	return nil
+}
+
+// features returns a feature for each Cluster of the ClusterMap.
+func (cm ClusterMap) features(h Headers, bits uint) ([]geoFeature, error) {
+	if bits < 1 || bits > 64 {
+		return nil, fmt.Errorf("clusters: geohash precision must be between 1 and 64 bits, got %d", bits)
+	}
+	idx, ok := h.ContainsMulti("MMSI", "BaseDateTime", "LAT", "LON")
+	if !ok {
+		return nil, fmt.Errorf("clusters: headers must contain MMSI, BaseDateTime, LAT and LON")
+	}
+	hashes := make([]uint64, 0, len(cm))
+	for hash := range cm {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	features := make([]geoFeature, 0, len(cm))
+	for _, hash := range hashes {
+		box := geohash.BoundingBoxIntWithPrecision(hash, bits)
+		points := geoGeometry{kind: "MultiPoint"}
+		vessels := make(map[string]bool)
+		var first, last time.Time
+		for _, rec := range cm[hash].data {
+			if mmsi, ok := rec.Value(idx["MMSI"].Idx); ok {
+				vessels[mmsi] = true
+			}
+			p, err := h.position(*rec, idx["BaseDateTime"].Idx, idx["LAT"].Idx, idx["LON"].Idx)
+			if err != nil {
+				continue
+			}
+			points.coords = append(points.coords, p.pos)
+			if first.IsZero() || p.t.Before(first) {
+				first = p.t
+			}
+			if p.t.After(last) {
+				last = p.t
+			}
+		}
+		name := fmt.Sprintf("%#x", hash)
+		f := geoFeature{
+			name:       name,
+			geometries: []geoGeometry{cellPolygon(box.MinLat, box.MinLng, box.MaxLat, box.MaxLng)},
+			props: map[string]interface{}{
+				"geohash": name,
+				"reports": cm[hash].Size(),
+				"vessels": len(vessels),
+			},
+			begin: first,
+			end:   last,
+		}
+		if len(points.coords) > 0 {
+			f.geometries = append(f.geometries, points)
+			f.props["start"] = formatGeoTime(first)
+			f.props["end"] = formatGeoTime(last)
+		}
+		features = append(features, f)
+	}
+	return features, nil
+}
+
+// WriteGeoJSON writes the Interactions to w as a GeoJSON FeatureCollection with
+// a LineString Feature joining the positions of the two vessels of each
+// interaction.  The properties are interactionHash, distance in nautical miles,
+// mmsi1, mmsi2, time1, time2 and timeDiff, the seconds between the two reports.
+// The interactions are in the order written by Save.
+func (inter *Interactions) WriteGeoJSON(w io.Writer) error {
+	features, err := inter.features()
+	if err != nil {
+		return err
+	}
+	if err := writeGeoJSON(w, features); err != nil {
+		return fmt.Errorf("interactions: write geojson: %v", err)
+	}
+	return nil
+}
+
+// WriteKML is WriteGeoJSON for KML.  Each interaction is a Placemark named for
+// the two MMSI with a TimeSpan covering both reports.
+func (inter *Interactions) WriteKML(w io.Writer) error {
+	features, err := inter.features()
+	if err != nil {
+		return err
+	}
+	if err := writeKML(w, "Interactions", features); err != nil {
+		return fmt.Errorf("interactions: write kml: %v", err)
+	}
+	return nil
+}
+
+// features returns a feature for each interaction.
+func (inter *Interactions) features() ([]geoFeature, error) {
+	mmsiIndex, timeIndex, latIndex, lonIndex := inter.hashIndices[0], inter.hashIndices[1], inter.hashIndices[2], inter.hashIndices[3]
+	hashes := inter.sortedHashes()
+	features := make([]geoFeature, 0, len(hashes))
+	for _, hash := range hashes {
+		pair := inter.data[hash]
+		p1, err := inter.RecordHeaders.position(*pair.rec1, timeIndex, latIndex, lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("interactions: %v", err)
+		}
+		p2, err := inter.RecordHeaders.position(*pair.rec2, timeIndex, latIndex, lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("interactions: %v", err)
+		}
+		d, err := pair.rec1.Distance(*pair.rec2, latIndex, lonIndex)
+		if err != nil {
+			return nil, fmt.Errorf("interactions: %v", err)
+		}
+		mmsi1, _ := pair.rec1.Value(mmsiIndex)
+		mmsi2, _ := pair.rec2.Value(mmsiIndex)
+		begin, end := p1.t, p2.t
+		if end.Before(begin) {
+			begin, end = end, begin
+		}
+		features = append(features, geoFeature{
+			name:       mmsi1 + "-" + mmsi2,
+			geometries: []geoGeometry{{kind: "LineString", coords: [][2]float64{p1.pos, p2.pos}}},
+			props: map[string]interface{}{
+				"interactionHash": fmt.Sprintf("%0#16x", hash),
+				"distance":        d,
+				"mmsi1":           mmsi1,
+				"mmsi2":           mmsi2,
+				"time1":           formatGeoTime(p1.t),
+				"time2":           formatGeoTime(p2.t),
+				"timeDiff":        end.Sub(begin).Seconds(),
+			},
+			begin: begin,
+			end:   end,
+		})
+	}
+	return features, nil
+}
+
+// saveGeo writes the interactions to filename with write.
+func (inter *Interactions) saveGeo(filename string, write func(io.Writer) error) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("interactions save: %v", err)
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return fmt.Errorf("interactions save: %v", err)
+	}
+	return out.Close()
+}
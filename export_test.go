@@ -0,0 +1,325 @@
+package ais
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// trackData has two reports of vessel 1 out of time order, a single report of
+// vessel 2 and a report of vessel 3 with a bad LAT.
+const trackData = `MMSI,BaseDateTime,LAT,LON,VesselName
+1,2017-12-01T00:02:00,36.2,-76.2,
+2,2017-12-01T00:01:00,36.5,-76.5,SEA DOG
+1,2017-12-01T00:01:00,36.1,-76.1,EVER GIVEN
+3,2017-12-01T00:01:00,bad,-76.5,
+`
+
+// geoJSONCollection is the part of a GeoJSON FeatureCollection checked by the
+// tests.
+type geoJSONCollection struct {
+	Type     string
+	Features []struct {
+		Type     string
+		Geometry struct {
+			Type        string
+			Coordinates json.RawMessage
+		}
+		Properties map[string]interface{}
+	}
+}
+
+func decodeGeoJSON(t *testing.T, b []byte) geoJSONCollection {
+	var fc geoJSONCollection
+	if err := json.Unmarshal(b, &fc); err != nil {
+		t.Fatalf("invalid GeoJSON: %v\n%s", err, b)
+	}
+	if fc.Type != "FeatureCollection" {
+		t.Fatalf("GeoJSON type = %q, want FeatureCollection", fc.Type)
+	}
+	return fc
+}
+
+// kmlDocument is the part of a KML file checked by the tests.
+type kmlDocument struct {
+	Placemarks []struct {
+		Name     string `xml:"name"`
+		TimeSpan struct {
+			Begin string `xml:"begin"`
+			End   string `xml:"end"`
+		} `xml:"TimeSpan"`
+		Data []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value"`
+		} `xml:"ExtendedData>Data"`
+		LineString    string   `xml:"LineString>coordinates"`
+		Point         string   `xml:"Point>coordinates"`
+		MultiPolygon  []string `xml:"MultiGeometry>Polygon>outerBoundaryIs>LinearRing>coordinates"`
+		MultiGeometry []string `xml:"MultiGeometry>Point>coordinates"`
+	} `xml:"Document>Placemark"`
+}
+
+func decodeKML(t *testing.T, b []byte) kmlDocument {
+	var doc kmlDocument
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("invalid KML: %v\n%s", err, b)
+	}
+	return doc
+}
+
+func TestRecordSet_WriteTracksGeoJSON(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(trackData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	if err := rs.WriteTracksGeoJSON(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "line 5: LAT") {
+		t.Fatalf("RecordSet.WriteTracksGeoJSON() error = %v, want a LAT error on line 5", err)
+	}
+
+	rs, _ = NewRecordSetFromReader(strings.NewReader(trackData))
+	rs.SetErrorPolicy(SkipInvalid, nil)
+	var buf bytes.Buffer
+	if err := rs.WriteTracksGeoJSON(&buf); err != nil {
+		t.Fatalf("RecordSet.WriteTracksGeoJSON() error = %v", err)
+	}
+	fc := decodeGeoJSON(t, buf.Bytes())
+	if len(fc.Features) != 2 {
+		t.Fatalf("RecordSet.WriteTracksGeoJSON() wrote %d features, want 2", len(fc.Features))
+	}
+	tests := []struct {
+		kind   string
+		coords string
+		props  map[string]interface{}
+	}{
+		{"LineString", "[[-76.1,36.1],[-76.2,36.2]]", map[string]interface{}{
+			"mmsi": "1", "reports": 2.0, "start": "2017-12-01T00:01:00Z", "end": "2017-12-01T00:02:00Z", "vesselName": "EVER GIVEN",
+		}},
+		{"Point", "[-76.5,36.5]", map[string]interface{}{
+			"mmsi": "2", "reports": 1.0, "start": "2017-12-01T00:01:00Z", "end": "2017-12-01T00:01:00Z", "vesselName": "SEA DOG",
+		}},
+	}
+	for i, tt := range tests {
+		f := fc.Features[i]
+		if f.Geometry.Type != tt.kind || string(f.Geometry.Coordinates) != tt.coords {
+			t.Errorf("feature %d geometry = %s %s, want %s %s", i, f.Geometry.Type, f.Geometry.Coordinates, tt.kind, tt.coords)
+		}
+		if !reflect.DeepEqual(f.Properties, tt.props) {
+			t.Errorf("feature %d properties = %v, want %v", i, f.Properties, tt.props)
+		}
+	}
+}
+
+func TestRecordSet_WriteTracksMalformed(t *testing.T) {
+	h := Headers{Fields: []string{"LAT", "LON", "BaseDateTime", "MMSI", "VesselName"}}
+	recs := []Record{
+		{"36.1", "-76.1", "2017-12-01T00:01:00", "1", "EVER GIVEN"},
+		{"36.2", "-76.2", "2017-12-01T00:02:00", "1"},
+		{"36.3", "-76.3", "2017-12-01T00:03:00"},
+		{"NaN", "-76.4", "2017-12-01T00:04:00", "2", ""},
+		{"36.5", "+Inf", "2017-12-01T00:05:00", "3", ""},
+	}
+	tests := []struct {
+		name    string
+		policy  ErrorPolicy
+		wantErr string
+	}{
+		{"fail fast", FailFast, "line 3: MMSI"},
+		{"reject", RejectInvalid, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewMemRecordSet(h, recs...).RecordSet()
+			var rejects RejectList
+			rs.SetErrorPolicy(tt.policy, &rejects)
+			var buf bytes.Buffer
+			err := rs.WriteTracksGeoJSON(&buf)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RecordSet.WriteTracksGeoJSON() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecordSet.WriteTracksGeoJSON() error = %v", err)
+			}
+			fc := decodeGeoJSON(t, buf.Bytes())
+			if len(fc.Features) != 1 || fc.Features[0].Properties["reports"] != 2.0 {
+				t.Errorf("RecordSet.WriteTracksGeoJSON() wrote %s", buf.Bytes())
+			}
+			if len(rejects) != 3 {
+				t.Errorf("rejected %d records, want 3", len(rejects))
+			}
+		})
+	}
+}
+
+func TestRecordSet_WriteTracksKML(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(trackData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	rs.SetErrorPolicy(SkipInvalid, nil)
+	var buf bytes.Buffer
+	if err := rs.WriteTracksKML(&buf); err != nil {
+		t.Fatalf("RecordSet.WriteTracksKML() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2">`) {
+		t.Errorf("RecordSet.WriteTracksKML() wrote\n%s", buf.String())
+	}
+	doc := decodeKML(t, buf.Bytes())
+	if len(doc.Placemarks) != 2 {
+		t.Fatalf("RecordSet.WriteTracksKML() wrote %d Placemarks, want 2", len(doc.Placemarks))
+	}
+	pm := doc.Placemarks[0]
+	if pm.Name != "EVER GIVEN" || pm.LineString != "-76.1,36.1 -76.2,36.2" {
+		t.Errorf("Placemark = %+v", pm)
+	}
+	if pm.TimeSpan.Begin != "2017-12-01T00:01:00Z" || pm.TimeSpan.End != "2017-12-01T00:02:00Z" {
+		t.Errorf("Placemark TimeSpan = %+v", pm.TimeSpan)
+	}
+	if pm := doc.Placemarks[1]; pm.Point != "-76.5,36.5" || len(pm.Data) != 5 || pm.Data[0].Name != "end" {
+		t.Errorf("Placemark = %+v", pm)
+	}
+}
+
+// clusterMap returns the ClusterMap of the Records in data, which have a
+// Geohash field of 10 bits.
+func clusterMap(t *testing.T, data string) (ClusterMap, Headers) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	rs, err = rs.AppendField("Geohash", []string{"LAT", "LON"}, PrecisionGeohasher(10))
+	if err != nil {
+		t.Fatalf("RecordSet.AppendField() error = %v", err)
+	}
+	win := &Window{timeIndex: 1}
+	for _, rec := range readAll(t, rs) {
+		win.AddRecord(rec)
+	}
+	return win.FindClusters(5), rs.Headers()
+}
+
+func TestClusterMap_WriteGeoJSON(t *testing.T) {
+	data := strings.Replace(trackData, "bad", "50.0", 1)
+	cm, h := clusterMap(t, data)
+	var buf bytes.Buffer
+	if err := cm.WriteGeoJSON(&buf, h, 10); err != nil {
+		t.Fatalf("ClusterMap.WriteGeoJSON() error = %v", err)
+	}
+	fc := decodeGeoJSON(t, buf.Bytes())
+	// Vessels 1 and 2 share a cell and vessel 3 is in another.
+	if len(fc.Features) != 4 {
+		t.Fatalf("ClusterMap.WriteGeoJSON() wrote %d features, want 4", len(fc.Features))
+	}
+	cell, points := fc.Features[0], fc.Features[1]
+	if cell.Geometry.Type != "Polygon" || points.Geometry.Type != "MultiPoint" {
+		t.Fatalf("geometries = %s and %s, want Polygon and MultiPoint", cell.Geometry.Type, points.Geometry.Type)
+	}
+	var ring [][][2]float64
+	if err := json.Unmarshal(cell.Geometry.Coordinates, &ring); err != nil || len(ring) != 1 || len(ring[0]) != 5 {
+		t.Fatalf("Polygon coordinates = %s", cell.Geometry.Coordinates)
+	}
+	var pts [][2]float64
+	if err := json.Unmarshal(points.Geometry.Coordinates, &pts); err != nil || len(pts) != 3 {
+		t.Fatalf("MultiPoint coordinates = %s", points.Geometry.Coordinates)
+	}
+	sw, ne := ring[0][0], ring[0][2]
+	for _, p := range pts {
+		if p[0] < sw[0] || p[0] > ne[0] || p[1] < sw[1] || p[1] > ne[1] {
+			t.Errorf("point %v is outside of the cell %v", p, ring[0])
+		}
+	}
+	want := map[string]interface{}{
+		"geohash": cell.Properties["geohash"], "reports": 3.0, "vessels": 2.0,
+		"start": "2017-12-01T00:01:00Z", "end": "2017-12-01T00:02:00Z",
+	}
+	if !reflect.DeepEqual(cell.Properties, want) || !reflect.DeepEqual(points.Properties, want) {
+		t.Errorf("properties = %v and %v, want %v", cell.Properties, points.Properties, want)
+	}
+
+	if err := cm.WriteGeoJSON(&buf, h, 0); err == nil {
+		t.Errorf("ClusterMap.WriteGeoJSON() with 0 bits error = nil, want error")
+	}
+}
+
+func TestClusterMap_WriteKML(t *testing.T) {
+	data := strings.Replace(trackData, "bad", "50.0", 1)
+	cm, h := clusterMap(t, data)
+	var buf bytes.Buffer
+	if err := cm.WriteKML(&buf, h, 10); err != nil {
+		t.Fatalf("ClusterMap.WriteKML() error = %v", err)
+	}
+	doc := decodeKML(t, buf.Bytes())
+	if len(doc.Placemarks) != 2 {
+		t.Fatalf("ClusterMap.WriteKML() wrote %d Placemarks, want 2", len(doc.Placemarks))
+	}
+	pm := doc.Placemarks[0]
+	if len(pm.MultiPolygon) != 1 || len(pm.MultiGeometry) != 3 || !strings.HasPrefix(pm.Name, "0x") {
+		t.Errorf("Placemark = %+v", pm)
+	}
+}
+
+func TestInteractions_SaveGeo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+	inter, err := NewInteractions(h)
+	if err != nil {
+		t.Fatalf("NewInteractions() error = %v", err)
+	}
+	c := &Cluster{data: []*Record{
+		{"1", "2017-12-01T00:00:00", "36.0", "-76.0"},
+		{"2", "2017-12-01T00:00:30", "36.1", "-76.0"},
+	}}
+	if err := inter.AddCluster(c); err != nil {
+		t.Fatalf("Interactions.AddCluster() error = %v", err)
+	}
+
+	name := filepath.Join(dir, "inter"+GeoJSONExt)
+	if err := inter.Save(name); err != nil {
+		t.Fatalf("Interactions.Save() error = %v", err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := decodeGeoJSON(t, b)
+	if len(fc.Features) != 1 {
+		t.Fatalf("Interactions.Save() wrote %d features, want 1", len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "LineString" || string(f.Geometry.Coordinates) != "[[-76,36],[-76,36.1]]" {
+		t.Errorf("geometry = %s %s", f.Geometry.Type, f.Geometry.Coordinates)
+	}
+	p := f.Properties
+	if p["mmsi1"] != "1" || p["mmsi2"] != "2" || p["timeDiff"] != 30.0 || p["time2"] != "2017-12-01T00:00:30Z" {
+		t.Errorf("properties = %v", p)
+	}
+	if d, ok := p["distance"].(float64); !ok || d < 5.9 || d > 6.1 {
+		t.Errorf("distance = %v, want about 6 nm", p["distance"])
+	}
+
+	name = filepath.Join(dir, "inter"+KMLExt)
+	if err := inter.Save(name); err != nil {
+		t.Fatalf("Interactions.Save() error = %v", err)
+	}
+	b, err = ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := decodeKML(t, b)
+	if len(doc.Placemarks) != 1 || doc.Placemarks[0].Name != "1-2" || doc.Placemarks[0].LineString != "-76,36 -76,36.1" {
+		t.Errorf("Interactions.Save() wrote KML\n%s", b)
+	}
+}
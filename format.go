@@ -0,0 +1,112 @@
+package ais
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format describes a file format that is read by OpenRecordSet and written by
+// RecordSet.Save and Interactions.Save once it is registered with
+// RegisterFormat.  Formats that depend on other packages live in subpackages
+// that register them when they are imported, so that package ais itself stays
+// free of their dependencies.  Importing the parquet subpackage for its side
+// effect adds Apache Parquet.
+//
+//	import _ "github.com/FATHOM5/ais/parquet"
+//
+// Files in a registered Format are opened by name because they may need random
+// access, so they cannot be compressed or read by NewRecordSetFromReader.
+type Format struct {
+	// Name is used in error messages.
+	Name string
+
+	// Ext is the filename extension written in the Format, such as ".parquet".
+	Ext string
+
+	// Magic is the start of every file in the Format, of at most four bytes.
+	Magic string
+
+	// Open opens the named file.
+	Open func(name string) (FormatReader, error)
+
+	// Write writes the Headers h and the Records returned by next, until next
+	// returns io.EOF, to w.
+	Write func(w io.Writer, h Headers, next func() ([]string, error)) error
+}
+
+// FormatReader reads the Records of a file opened by a Format.
+type FormatReader interface {
+	// Fields returns the header names of the file.
+	Fields() []string
+
+	// Read returns the fields of the next Record, or io.EOF after the last one.
+	Read() ([]string, error)
+
+	// Close releases the file.
+	Close() error
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+// RegisterFormat registers a Format.  It is usually called from the init
+// function of the package that implements the Format and panics if the Format
+// is incomplete or another Format is registered with the same Ext or Magic.
+func RegisterFormat(f Format) {
+	if f.Ext == "" || f.Magic == "" || len(f.Magic) > 4 || f.Open == nil || f.Write == nil {
+		panic("ais: RegisterFormat of an incomplete format " + f.Name)
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for _, g := range formats {
+		if g.Ext == f.Ext || g.Magic == f.Magic {
+			panic("ais: RegisterFormat called twice for format " + f.Name)
+		}
+	}
+	formats = append(formats, f)
+}
+
+// formatByExt returns the registered Format written to files with the extension
+// of name.
+func formatByExt(name string) (Format, bool) {
+	ext := filepath.Ext(name)
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if ext == f.Ext {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// formatByMagic returns the registered Format of data that starts with magic.
+func formatByMagic(magic string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if strings.HasPrefix(magic, f.Magic) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// saveFormat writes the Headers and the Records returned by next to the file
+// name in the Format f.
+func saveFormat(name string, f Format, h Headers, next func() ([]string, error)) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := f.Write(out, h, next); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
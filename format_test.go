@@ -0,0 +1,148 @@
+package ais
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tabExt is the extension of tabFormat, a registered Format of tab separated
+// lines after the magic "TAB\n".
+const tabExt = ".tab"
+
+func init() {
+	RegisterFormat(Format{Name: "tab", Ext: tabExt, Magic: "TAB\n", Open: openTab, Write: writeTab})
+}
+
+func writeTab(w io.Writer, h Headers, next func() ([]string, error)) error {
+	fmt.Fprintf(w, "TAB\n%s\n", strings.Join(h.Fields, "\t"))
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(rec, "\t"))
+	}
+}
+
+type tabReader struct {
+	f      *os.File
+	s      *bufio.Scanner
+	fields []string
+}
+
+func openTab(name string) (FormatReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	tr := &tabReader{f: f, s: bufio.NewScanner(f)}
+	tr.s.Scan()
+	tr.s.Scan()
+	tr.fields = strings.Split(tr.s.Text(), "\t")
+	return tr, nil
+}
+
+func (tr *tabReader) Fields() []string { return tr.fields }
+func (tr *tabReader) Close() error     { return tr.f.Close() }
+
+func (tr *tabReader) Read() ([]string, error) {
+	if !tr.s.Scan() {
+		return nil, io.EOF
+	}
+	return strings.Split(tr.s.Text(), "\t"), nil
+}
+
+func TestRegisterFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	name := filepath.Join(dir, "ten"+tabExt)
+	if err := rs.Save(name); err != nil {
+		t.Fatalf("RecordSet.Save() error = %v", err)
+	}
+
+	got, err := OpenRecordSet(name)
+	if err != nil {
+		t.Fatalf("OpenRecordSet(%s) error = %v", name, err)
+	}
+	want, _ := OpenRecordSet("testdata/ten.csv")
+	defer want.Close()
+	if !got.Headers().Equals(want.Headers()) {
+		t.Errorf("Headers = %v, want %v", got.Headers().Fields, want.Headers().Fields)
+	}
+	if gotRecs, wantRecs := readAll(t, got), readAll(t, want); !reflect.DeepEqual(gotRecs, wantRecs) {
+		t.Errorf("Records = %v, want %v", gotRecs, wantRecs)
+	}
+	if err := got.Close(); err != nil {
+		t.Errorf("RecordSet.Close() error = %v", err)
+	}
+
+	// Registered formats are opened by name, so they cannot be compressed or
+	// read from a stream.
+	if err := rs.Save(name + GzipExt); err == nil {
+		t.Errorf("RecordSet.Save(%s) error = nil, want error", name+GzipExt)
+	}
+	b, _ := ioutil.ReadFile(name)
+	if _, err := NewRecordSetFromReader(bytes.NewReader(b)); err == nil {
+		t.Errorf("NewRecordSetFromReader() error = nil, want error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterFormat() with a registered Ext did not panic")
+		}
+	}()
+	RegisterFormat(Format{Name: "tab2", Ext: tabExt, Magic: "TAB2", Open: openTab, Write: writeTab})
+}
+
+func TestInteractions_SaveFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+	inter, err := NewInteractions(h)
+	if err != nil {
+		t.Fatalf("NewInteractions() error = %v", err)
+	}
+	c := &Cluster{data: []*Record{
+		{"1", "2017-12-01T00:00:00", "36.0", "-76.0"},
+		{"2", "2017-12-01T00:00:30", "36.1", "-76.0"},
+	}}
+	if err := inter.AddCluster(c); err != nil {
+		t.Fatalf("Interactions.AddCluster() error = %v", err)
+	}
+	name := filepath.Join(dir, "inter"+tabExt)
+	if err := inter.Save(name); err != nil {
+		t.Fatalf("Interactions.Save() error = %v", err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "InteractionHash\t") || !strings.Contains(lines[2], "\t1\t2017-12-01T00:00:00\t") {
+		t.Errorf("Interactions.Save() wrote\n%s", b)
+	}
+}
@@ -0,0 +1,83 @@
+package ais
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseIMO returns the seven digit IMO ship identification number in s.  The
+// value may carry the "IMO" prefix used in MarineCadastre data, in any case and
+// with or without a separating space, so "IMO9739666", "imo 9739666" and
+// "9739666" all return 9739666.  It returns an error for blank values, values
+// that are not seven digits, the all zero placeholder sent by many transponders
+// and numbers whose check digit is wrong.
+//
+// The check digit is the last digit of the number.  It is the rightmost digit of
+// the sum of the first six digits multiplied by 7, 6, 5, 4, 3 and 2.
+func ParseIMO(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("imo: blank value")
+	}
+	digits := s
+	if len(digits) >= 3 && strings.EqualFold(digits[:3], "IMO") {
+		digits = strings.TrimSpace(digits[3:])
+	}
+	if len(digits) != 7 {
+		return 0, fmt.Errorf("imo: %q must be seven digits", s)
+	}
+
+	var n, sum int
+	for i, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("imo: %q must be seven digits", s)
+		}
+		d := int(c - '0')
+		n = n*10 + d
+		if i < 6 {
+			sum += d * (7 - i)
+		}
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("imo: %q is a placeholder and not an IMO number", s)
+	}
+	if sum%10 != n%10 {
+		return 0, fmt.Errorf("imo: %q has an invalid check digit", s)
+	}
+	return n, nil
+}
+
+// NormalizeIMO returns s in the form "IMO9739666" when it holds a valid IMO
+// number and false otherwise.
+func NormalizeIMO(s string) (string, bool) {
+	n, err := ParseIMO(s)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("IMO%07d", n), true
+}
+
+// validIMO returns true when s holds a valid IMO number.
+func validIMO(s string) bool {
+	_, err := ParseIMO(s)
+	return err == nil
+}
+
+// IMOMatcher implements the Matching interface and matches the Records with a
+// valid IMO number.  IMOIndex is the index of "IMO" in a Record.
+//
+//	m := &ais.IMOMatcher{IMOIndex: idx}
+//	rs, err = rs.Subset(m)
+type IMOMatcher struct {
+	IMOIndex int
+}
+
+// Match implements the Matching interface for an IMOMatcher.  The only error
+// returned is for an IMOIndex that is out of range for the Record.
+func (im *IMOMatcher) Match(rec *Record) (bool, error) {
+	val, ok := rec.Value(im.IMOIndex)
+	if !ok {
+		return false, fmt.Errorf("imo matcher: index %d out of range", im.IMOIndex)
+	}
+	return validIMO(val), nil
+}
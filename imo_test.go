@@ -0,0 +1,79 @@
+package ais
+
+import (
+	"testing"
+)
+
+func TestParseIMO(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"prefixed", "IMO9739666", 9739666, false},
+		{"lower case with space", "imo 7933464", 7933464, false},
+		{"digits only", "9074729", 9074729, false},
+		{"padded", " IMO9739666 ", 9739666, false},
+		{"bad check digit", "IMO9739667", 0, true},
+		{"placeholder", "IMO0000000", 0, true},
+		{"too short", "IMO973966", 0, true},
+		{"too long", "97396660", 0, true},
+		{"letters", "IMO97396x6", 0, true},
+		{"blank", "", 0, true},
+		{"prefix only", "IMO", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIMO(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIMO() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseIMO() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIMO(t *testing.T) {
+	if got, ok := NormalizeIMO("imo 9739666"); !ok || got != "IMO9739666" {
+		t.Errorf("NormalizeIMO() = %q, %v, want IMO9739666, true", got, ok)
+	}
+	if got, ok := NormalizeIMO("1234568"); ok || got != "" {
+		t.Errorf("NormalizeIMO() = %q, %v, want \"\", false", got, ok)
+	}
+}
+
+func TestIMOMatcher_Match(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	imoIndex, _ := rs.Headers().Contains("IMO")
+
+	rs2, err := rs.Subset(&IMOMatcher{IMOIndex: imoIndex})
+	if err != nil {
+		t.Fatalf("Subset() error = %v", err)
+	}
+	n := 0
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		if _, err := ParseIMO((*rec)[imoIndex]); err != nil {
+			t.Errorf("IMOMatcher matched %q", (*rec)[imoIndex])
+		}
+		n++
+	}
+	if n == 0 {
+		t.Errorf("IMOMatcher matched no records in testdata/ten.csv")
+	}
+
+	im := &IMOMatcher{IMOIndex: 3}
+	if _, err := im.Match(&Record{"IMO9739666"}); err == nil {
+		t.Errorf("IMOMatcher.Match() out of range error = nil, want error")
+	}
+}
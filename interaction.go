@@ -4,8 +4,12 @@ import (
 	"encoding/csv"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 // InteractionFields are the default column headers used to write a csv file of two vessel
@@ -73,13 +77,11 @@ func (inter *Interactions) AddCluster(c *Cluster) error {
 }
 
 // WriteInteraction appends to the set for each pair of interaction in the slice.
-// Note that calls to writeInteractions stemming from a sliding window will not hold
-// their order due to the randomization of ranging over a map.  This occurs because
-// the Window holds its data in a map and after a Slide() the order of these records
-// will be iterated differently. Therefore, this means that the PairHash for a given
-// pair of records may be recorded as the hash of {rec1, rec2} or {rec2, rec1} and
-// both must be checked for existence before a new *RecordPair is inserted into the
-// interactions map.
+// Note that the same pair of records can be found in more than one position of a
+// sliding window and in clusters that are added in any order.  Therefore, the
+// PairHash for a given pair of records may be recorded as the hash of {rec1, rec2}
+// or {rec2, rec1} and both must be checked for existence before a new *RecordPair
+// is inserted into the interactions map.
 func (inter *Interactions) writeInteractions(data []*Record) error {
 	if len(data) <= 1 { // only write two vessel interactions
 		return nil
@@ -104,8 +106,19 @@ func (inter *Interactions) writeInteractions(data []*Record) error {
 	return nil
 }
 
-// Save the interactions to a CSV file.
+// Save the interactions to a CSV file, to GeoJSON or KML when filename has the
+// GeoJSONExt or KMLExt extension, or to a registered Format, such as Apache
+// Parquet, when it has the Ext of the Format.
 func (inter *Interactions) Save(filename string) error {
+	if format, ok := formatByExt(filename); ok {
+		return inter.saveFormat(filename, format)
+	}
+	switch filepath.Ext(filename) {
+	case GeoJSONExt:
+		return inter.saveGeo(filename, inter.WriteGeoJSON)
+	case KMLExt:
+		return inter.saveGeo(filename, inter.WriteKML)
+	}
 	out, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("interactions save: %v", err)
@@ -118,18 +131,12 @@ func (inter *Interactions) Save(filename string) error {
 	}
 	w.Flush()
 
-	latIndex, _ := inter.RecordHeaders.Contains("LAT")
-	lonIndex, _ := inter.RecordHeaders.Contains("LON")
-
 	written := 1
-	for hash, pair := range inter.data {
-		d, err := pair.rec1.Distance(*(pair.rec2), latIndex, lonIndex)
+	for _, hash := range inter.sortedHashes() {
+		pairData, err := inter.pairData(hash, inter.data[hash])
 		if err != nil {
 			return fmt.Errorf("interactions save: %v", err)
 		}
-		pairData := []string{fmt.Sprintf("%0#16x", hash), fmt.Sprintf("%.1f", d)}
-		pairData = append(pairData, (*pair.rec1)...)
-		pairData = append(pairData, (*pair.rec2)...)
 		w.Write(pairData)
 		written++
 		if written%flushThreshold == 0 {
@@ -165,3 +172,65 @@ func PairHash64(rec1, rec2 *Record, indices [4]int) (uint64, error) {
 
 	return h64.Sum64(), nil
 }
+
+// saveFormat writes the interactions to filename in the Format f.
+func (inter *Interactions) saveFormat(filename string, f Format) error {
+	hashes := inter.sortedHashes()
+	next := func() ([]string, error) {
+		if len(hashes) == 0 {
+			return nil, io.EOF
+		}
+		hash := hashes[0]
+		hashes = hashes[1:]
+		return inter.pairData(hash, inter.data[hash])
+	}
+	if err := saveFormat(filename, f, inter.OutputHeaders, next); err != nil {
+		return fmt.Errorf("interactions save: %v", err)
+	}
+	return nil
+}
+
+// sortedHashes returns the hashes of the interactions in the order they are
+// saved, which is by the BaseDateTime of the first and then the second Record
+// of each pair, with the hash breaking ties.  The order does not depend on the
+// iteration of the interactions map so that saving the same Interactions twice
+// gives the same file.  Timestamps that cannot be parsed sort first.
+func (inter *Interactions) sortedHashes() []uint64 {
+	type key struct {
+		t1, t2 time.Time
+		hash   uint64
+	}
+	keys := make([]key, 0, len(inter.data))
+	for hash, pair := range inter.data {
+		t1, _ := inter.RecordHeaders.ParseTime(*pair.rec1, inter.hashIndices[1])
+		t2, _ := inter.RecordHeaders.ParseTime(*pair.rec2, inter.hashIndices[1])
+		keys = append(keys, key{t1, t2, hash})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if !a.t1.Equal(b.t1) {
+			return a.t1.Before(b.t1)
+		}
+		if !a.t2.Equal(b.t2) {
+			return a.t2.Before(b.t2)
+		}
+		return a.hash < b.hash
+	})
+	hashes := make([]uint64, len(keys))
+	for i, k := range keys {
+		hashes[i] = k.hash
+	}
+	return hashes
+}
+
+// pairData returns the output fields for the interaction with hash.
+func (inter *Interactions) pairData(hash uint64, pair *RecordPair) ([]string, error) {
+	d, err := pair.rec1.Distance(*(pair.rec2), inter.hashIndices[2], inter.hashIndices[3])
+	if err != nil {
+		return nil, err
+	}
+	pairData := []string{fmt.Sprintf("%0#16x", hash), fmt.Sprintf("%.1f", d)}
+	pairData = append(pairData, (*pair.rec1)...)
+	pairData = append(pairData, (*pair.rec2)...)
+	return pairData, nil
+}
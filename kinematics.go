@@ -0,0 +1,281 @@
+package ais
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FATHOM5/haversine"
+)
+
+// Default configuration values for a KinematicChecker returned by
+// NewKinematicChecker.
+const (
+	DefaultMaxSpeed       = 50.0             // knots
+	DefaultSpeedTolerance = 10.0             // knots
+	DefaultMinInterval    = 30 * time.Second // shortest time used to compute an implied speed
+	DefaultTrackTimeout   = 30 * time.Minute // time after which an unused track is forgotten
+)
+
+// Flags returned by a KinematicChecker for a Record.  A Record that passes all
+// of the checks is flagged with KinematicOK.
+const (
+	KinematicOK        = ""
+	KinematicTeleport  = "teleport"  // implied speed from the previous report exceeds MaxSpeed
+	KinematicSpeed     = "speed"     // implied speed exceeds the reported SOG by more than SpeedTolerance
+	KinematicDuplicate = "duplicate" // the MMSI is reporting from more than one place at once
+)
+
+// KinematicFields are the Headers used in calls to AppendField with a
+// KinematicChecker.  SOG is optional and the speed check is skipped without it.
+var KinematicFields = []string{"MMSI", "BaseDateTime", "LAT", "LON", "SOG"}
+
+// KinematicChecker implements the Generator interface to flag physically
+// implausible position reports.  For every MMSI the checker keeps the last
+// position of one or more tracks.  A report that cannot be reached from any
+// track without exceeding MaxSpeed starts a new track and is flagged as a
+// teleport.  When an MMSI alternates between two live tracks it is flagged as a
+// duplicate because two transmitters are using the same MMSI.
+//
+// The checker is stateful and must be used on a RecordSet that is sorted by
+// BaseDateTime, typically through a call to AppendField.  Layout is the layout
+// passed to Headers.ParseTime, where an empty Layout detects the format of each
+// timestamp, so data with a Schema or a layout set with SetTimeLayout needs
+// the Layout of its Headers.
+//
+//	kc := ais.NewKinematicChecker()
+//	kc.Layout = rs.Headers().TimeLayout()
+//	rs, err = rs.AppendField("Kinematics", ais.KinematicFields, kc)
+//	report := kc.Report()
+//
+// When Split is true Generate returns a track identifier of the form MMSI-n
+// instead of a flag so that the reports of each transmitter can be separated.
+type KinematicChecker struct {
+	MaxSpeed       float64       // knots
+	SpeedTolerance float64       // knots
+	MinInterval    time.Duration // floor on the time between reports used for implied speed
+	TrackTimeout   time.Duration // tracks without a report for this long are forgotten
+	Split          bool          // return track identifiers instead of flags
+	Layout         string        // layout of BaseDateTime
+
+	tracks  map[string]*vesselTracks
+	suspect map[string]*SuspectVessel
+}
+
+// vesselTracks holds the live tracks for a single MMSI.
+type vesselTracks struct {
+	tracks  []*kinTrack
+	last    int // id of the track that received the most recent report
+	created int // number of tracks ever created
+}
+
+// kinTrack is the last known state of a single transmitter.
+type kinTrack struct {
+	id       int
+	t        time.Time
+	lat, lon float64
+}
+
+// NewKinematicChecker returns a *KinematicChecker with the default settings.
+func NewKinematicChecker() *KinematicChecker {
+	return &KinematicChecker{
+		MaxSpeed:       DefaultMaxSpeed,
+		SpeedTolerance: DefaultSpeedTolerance,
+		MinInterval:    DefaultMinInterval,
+		TrackTimeout:   DefaultTrackTimeout,
+	}
+}
+
+// Generate implements the Generator interface for a KinematicChecker.  The
+// index values must be the index of MMSI, BaseDateTime, LAT, LON and optionally
+// SOG in the rec.
+func (k *KinematicChecker) Generate(rec Record, index ...int) (Field, error) {
+	if len(index) != 4 && len(index) != 5 {
+		return "", fmt.Errorf("kinematics: generate: index must hold the index of MMSI, BaseDateTime, LAT, LON and optionally SOG")
+	}
+	if k.tracks == nil {
+		k.tracks = make(map[string]*vesselTracks)
+		k.suspect = make(map[string]*SuspectVessel)
+	}
+
+	mmsi := rec[index[0]]
+	val, _ := rec.Value(index[1])
+	t, err := parseTimeLayout(k.Layout, val)
+	if err != nil {
+		return "", fmt.Errorf("kinematics: %v", err)
+	}
+	lat, err := rec.ParseFloat(index[2])
+	if err != nil {
+		return "", fmt.Errorf("kinematics: unable to parse lat")
+	}
+	lon, err := rec.ParseFloat(index[3])
+	if err != nil {
+		return "", fmt.Errorf("kinematics: unable to parse lon")
+	}
+
+	vt, ok := k.tracks[mmsi]
+	if !ok {
+		vt = new(vesselTracks)
+		k.tracks[mmsi] = vt
+	}
+	vt.expire(t, k.TrackTimeout)
+
+	// Find the live track that requires the lowest speed to reach this report.
+	var best *kinTrack
+	var bestSpeed float64
+	var bestInterval time.Duration
+	for _, tr := range vt.tracks {
+		dt := t.Sub(tr.t)
+		if dt < 0 {
+			return "", fmt.Errorf("kinematics: records for MMSI %s are not sorted by time", mmsi)
+		}
+		if dt < k.MinInterval {
+			dt = k.MinInterval
+		}
+		nm := haversine.Distance(haversine.Coord{Lat: tr.lat, Lon: tr.lon}, haversine.Coord{Lat: lat, Lon: lon})
+		speed := nm / dt.Hours()
+		if best == nil || speed < bestSpeed {
+			best, bestSpeed, bestInterval = tr, speed, t.Sub(tr.t)
+		}
+	}
+
+	flag := KinematicOK
+	switch {
+	case best == nil && vt.created == 0:
+		best = vt.newTrack()
+	case best == nil || bestSpeed > k.MaxSpeed:
+		best = vt.newTrack()
+		flag = KinematicTeleport
+	case best.id != vt.last:
+		flag = KinematicDuplicate
+	case len(index) == 5 && bestInterval >= k.MinInterval:
+		if sog, err := rec.ParseFloat(index[4]); err == nil && sog != SOGNotAvailable &&
+			bestSpeed-sog > k.SpeedTolerance {
+			flag = KinematicSpeed
+		}
+	}
+	best.t, best.lat, best.lon = t, lat, lon
+	vt.last = best.id
+
+	k.record(mmsi, flag, len(vt.tracks))
+
+	if k.Split {
+		return Field(mmsi + "-" + strconv.Itoa(best.id)), nil
+	}
+	return Field(flag), nil
+}
+
+// newTrack creates a new track for the vessel and returns it.
+func (vt *vesselTracks) newTrack() *kinTrack {
+	vt.created++
+	tr := &kinTrack{id: vt.created}
+	vt.tracks = append(vt.tracks, tr)
+	return tr
+}
+
+// expire removes tracks that have not had a report within timeout of t.  The
+// track that received the most recent report is never removed.
+func (vt *vesselTracks) expire(t time.Time, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	live := vt.tracks[:0]
+	for _, tr := range vt.tracks {
+		if tr.id == vt.last || t.Sub(tr.t) <= timeout {
+			live = append(live, tr)
+		}
+	}
+	vt.tracks = live
+}
+
+// record updates the suspect vessel statistics.
+func (k *KinematicChecker) record(mmsi, flag string, tracks int) {
+	sv, ok := k.suspect[mmsi]
+	if !ok {
+		sv = &SuspectVessel{MMSI: mmsi}
+		k.suspect[mmsi] = sv
+	}
+	sv.Reports++
+	if tracks > sv.Tracks {
+		sv.Tracks = tracks
+	}
+	switch flag {
+	case KinematicTeleport:
+		sv.Teleports++
+	case KinematicSpeed:
+		sv.SpeedMismatches++
+	case KinematicDuplicate:
+		sv.Duplicates++
+	}
+}
+
+// SuspectVessel summarizes the kinematic flags raised for one MMSI.  Tracks is
+// the largest number of simultaneously live tracks seen for the MMSI.
+type SuspectVessel struct {
+	MMSI            string
+	Reports         int
+	Teleports       int
+	SpeedMismatches int
+	Duplicates      int
+	Tracks          int
+}
+
+// Flagged returns the number of reports for the vessel that raised a flag.
+func (sv SuspectVessel) Flagged() int {
+	return sv.Teleports + sv.SpeedMismatches + sv.Duplicates
+}
+
+// SuspectReport is the list of vessels with at least one flagged report.
+type SuspectReport []SuspectVessel
+
+// SuspectReportFields are the column headers written by SuspectReport.Save.
+const SuspectReportFields = "MMSI,Reports,Teleports,SpeedMismatches,Duplicates,Tracks"
+
+// Report returns every MMSI with at least one flagged report sorted by the
+// number of flagged reports, most first.
+func (k *KinematicChecker) Report() SuspectReport {
+	var report SuspectReport
+	for _, sv := range k.suspect {
+		if sv.Flagged() > 0 {
+			report = append(report, *sv)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Flagged() != report[j].Flagged() {
+			return report[i].Flagged() > report[j].Flagged()
+		}
+		return report[i].MMSI < report[j].MMSI
+	})
+	return report
+}
+
+// Save writes the report to a csv file with SuspectReportFields as headers.
+func (sr SuspectReport) Save(filename string) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("suspect report save: %v", err)
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	w.Write(strings.Split(SuspectReportFields, ","))
+	for _, sv := range sr {
+		w.Write([]string{
+			sv.MMSI,
+			strconv.Itoa(sv.Reports),
+			strconv.Itoa(sv.Teleports),
+			strconv.Itoa(sv.SpeedMismatches),
+			strconv.Itoa(sv.Duplicates),
+			strconv.Itoa(sv.Tracks),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("suspect report save: flush error: %v", err)
+	}
+	return nil
+}
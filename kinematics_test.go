@@ -0,0 +1,142 @@
+package ais
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testKinematicString has a vessel that jumps to a second position and then
+// reports from both places, and a vessel that moves faster than its SOG.
+var testKinematicString = `MMSI,BaseDateTime,LAT,LON,SOG
+111111111,2017-12-01T00:00:00,36.0,-76.0,4.0
+222222222,2017-12-01T00:00:00,36.0,-75.0,0.0
+111111111,2017-12-01T00:10:00,36.01,-76.0,4.0
+222222222,2017-12-01T00:10:00,36.1,-75.0,0.0
+111111111,2017-12-01T00:11:00,37.0,-76.0,4.0
+111111111,2017-12-01T00:12:00,36.011,-76.0,4.0
+111111111,2017-12-01T00:13:00,37.001,-76.0,4.0
+`
+
+func TestKinematicChecker_Generate(t *testing.T) {
+	tests := []struct {
+		name  string
+		split bool
+		want  []string
+	}{
+		{
+			name:  "flags",
+			split: false,
+			want:  []string{"", "", "", KinematicSpeed, KinematicTeleport, KinematicDuplicate, KinematicDuplicate},
+		},
+		{
+			name:  "split tracks",
+			split: true,
+			want:  []string{"111111111-1", "222222222-1", "111111111-1", "222222222-1", "111111111-2", "111111111-1", "111111111-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newTestRecordSet(t, testKinematicString)
+			kc := NewKinematicChecker()
+			kc.Split = tt.split
+
+			rs2, err := rs.AppendField("Kinematics", KinematicFields, kc)
+			if err != nil {
+				t.Fatalf("AppendField() error = %v", err)
+			}
+			var got []string
+			for {
+				rec, err := rs2.Read()
+				if err != nil {
+					break
+				}
+				got = append(got, (*rec)[len(*rec)-1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KinematicChecker.Generate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKinematicChecker_Layout(t *testing.T) {
+	// The times of testKinematicString as Unix epoch seconds.
+	data := testKinematicString
+	for _, ts := range []string{"00:00:00", "00:10:00", "00:11:00", "00:12:00", "00:13:00"} {
+		tm, _ := ParseTimestamp("2017-12-01T" + ts)
+		data = strings.Replace(data, "2017-12-01T"+ts, strconv.FormatInt(tm.Unix(), 10), -1)
+	}
+	rs := newTestRecordSet(t, data)
+	rs.SetTimeLayout(TimeUnix)
+	kc := NewKinematicChecker()
+	kc.Layout = rs.Headers().TimeLayout()
+	if _, err := rs.AppendField("Kinematics", KinematicFields, kc); err != nil {
+		t.Fatalf("AppendField() error = %v", err)
+	}
+	if got := len(kc.Report()); got != 2 {
+		t.Errorf("KinematicChecker.Report() has %d vessels, want 2", got)
+	}
+}
+
+func TestKinematicChecker_Errors(t *testing.T) {
+	kc := NewKinematicChecker()
+	tests := []struct {
+		name  string
+		rec   Record
+		index []int
+	}{
+		{"too few indices", Record{"111111111", "2017-12-01T00:00:00"}, []int{0, 1}},
+		{"bad time", Record{"111111111", "2017-12-01Txx:00:00", "36.0", "-76.0"}, []int{0, 1, 2, 3}},
+		{"bad lat", Record{"111111111", "2017-12-01T00:00:00", "3x.0", "-76.0"}, []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kc.Generate(tt.rec, tt.index...); err == nil {
+				t.Errorf("KinematicChecker.Generate() error = nil, want error")
+			}
+		})
+	}
+
+	kc.Generate(Record{"111111111", "2017-12-01T00:10:00", "36.0", "-76.0"}, 0, 1, 2, 3)
+	_, err := kc.Generate(Record{"111111111", "2017-12-01T00:00:00", "36.0", "-76.0"}, 0, 1, 2, 3)
+	if err == nil {
+		t.Errorf("KinematicChecker.Generate() unsorted records error = nil, want error")
+	}
+}
+
+func TestKinematicChecker_Report(t *testing.T) {
+	rs := newTestRecordSet(t, testKinematicString)
+	kc := NewKinematicChecker()
+	if _, err := rs.AppendField("Kinematics", KinematicFields, kc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := SuspectReport{
+		{MMSI: "111111111", Reports: 5, Teleports: 1, Duplicates: 2, Tracks: 2},
+		{MMSI: "222222222", Reports: 2, SpeedMismatches: 1, Tracks: 1},
+	}
+	got := kc.Report()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KinematicChecker.Report() = %+v, want %+v", got, want)
+	}
+
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "suspects.csv")
+	if err := got.Save(filename); err != nil {
+		t.Fatalf("SuspectReport.Save() error = %v", err)
+	}
+	b, _ := ioutil.ReadFile(filename)
+	wantFile := SuspectReportFields + "\n111111111,5,1,0,2,2\n222222222,2,0,1,0,1\n"
+	if string(b) != wantFile {
+		t.Errorf("SuspectReport.Save() wrote \n%s\nwant \n%s", b, wantFile)
+	}
+}
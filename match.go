@@ -0,0 +1,69 @@
+package ais
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeRange implements the Matching interface for Records with a BaseDateTime
+// in [Start, End).  A zero Start or End leaves that side of the range open.
+// Like a Box, a TimeRange requires the index of BaseDateTime in a *Record, and
+// Layout is the layout passed to Headers.ParseTime, where an empty Layout
+// detects the format of each timestamp.  NewTimeRange sets both from the
+// Headers of the RecordSet being matched.
+//
+//	tr, err := ais.NewTimeRange(rs.Headers(), start, end)
+//	rs2, err := rs.Subset(tr)
+type TimeRange struct {
+	Start, End time.Time
+	TimeIndex  int
+	Layout     string
+}
+
+// NewTimeRange returns a *TimeRange for Records described by h.  It returns an
+// error if h does not contain BaseDateTime.
+func NewTimeRange(h Headers, start, end time.Time) (*TimeRange, error) {
+	timeIndex, ok := h.Contains("BaseDateTime")
+	if !ok {
+		return nil, fmt.Errorf("new time range: headers does not contain BaseDateTime")
+	}
+	return &TimeRange{Start: start, End: end, TimeIndex: timeIndex, Layout: h.TimeLayout()}, nil
+}
+
+// Match implements the Matching interface for a TimeRange.  Errors are caused
+// by timestamps that cannot be parsed.
+func (tr *TimeRange) Match(rec *Record) (bool, error) {
+	val, ok := rec.Value(tr.TimeIndex)
+	if !ok {
+		return false, fmt.Errorf("time range: index %d out of range", tr.TimeIndex)
+	}
+	t, err := parseTimeLayout(tr.Layout, val)
+	if err != nil {
+		return false, fmt.Errorf("time range: %v", err)
+	}
+	if !tr.Start.IsZero() && t.Before(tr.Start) {
+		return false, nil
+	}
+	if !tr.End.IsZero() && !t.Before(tr.End) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// MatchAll implements the Matching interface and matches a Record when every
+// one of its Matching values matches.  An empty MatchAll matches every Record.
+// Use it to combine a Box, a TimeRange and other filters in a single call to
+// Subset.
+type MatchAll []Matching
+
+// Match implements the Matching interface for MatchAll.  The Matching values are
+// called in order and the first error or false result is returned.
+func (all MatchAll) Match(rec *Record) (bool, error) {
+	for _, m := range all {
+		ok, err := m.Match(rec)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
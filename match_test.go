@@ -0,0 +1,82 @@
+package ais
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeRange_Match(t *testing.T) {
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime"}}
+	start := time.Date(2017, 12, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 12, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		start, end time.Time
+		ts         string
+		want       bool
+		wantErr    bool
+	}{
+		{"inside", start, end, "2017-12-01T12:00:00", true, false},
+		{"at start", start, end, "2017-12-01T00:00:00", true, false},
+		{"at end", start, end, "2017-12-02T00:00:00", false, false},
+		{"before", start, end, "2017-11-30T23:59:59", false, false},
+		{"zone", start, end, "2017-12-02T01:00:00+02:00", true, false},
+		{"open start", time.Time{}, end, "2001-01-01T00:00:00", true, false},
+		{"open end", start, time.Time{}, "2031-01-01T00:00:00", true, false},
+		{"bad time", start, end, "yesterday", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTimeRange(h, tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("NewTimeRange() error = %v", err)
+			}
+			got, err := tr.Match(&Record{"367157579", tt.ts})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TimeRange.Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TimeRange.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTimeRange(Headers{Fields: []string{"MMSI"}}, start, end); err == nil {
+		t.Errorf("NewTimeRange() without BaseDateTime error = nil, want error")
+	}
+	// A literal TimeRange matches the column given by TimeIndex.
+	tr := &TimeRange{Start: start, End: end, TimeIndex: 1}
+	if got, err := tr.Match(&Record{"367157579", "2017-12-01 12:00:00"}); !got || err != nil {
+		t.Errorf("TimeRange.Match() = %v, %v, want true, nil", got, err)
+	}
+}
+
+func TestMatchAll_Match(t *testing.T) {
+	rec := &Record{"367157579", "36.5", "-76.5", "FIRST"}
+	in := &Box{MinLat: 36, MaxLat: 37, MinLon: -77, MaxLon: -76, LatIndex: 1, LonIndex: 2}
+	out := &Box{MinLat: 0, MaxLat: 1, MinLon: 0, MaxLon: 1, LatIndex: 1, LonIndex: 2}
+	bad := &Box{LatIndex: 3, LonIndex: 2}
+	tests := []struct {
+		name    string
+		all     MatchAll
+		want    bool
+		wantErr bool
+	}{
+		{"empty", MatchAll{}, true, false},
+		{"all match", MatchAll{in, in}, true, false},
+		{"one fails", MatchAll{in, out}, false, false},
+		{"short circuit", MatchAll{out, bad}, false, false},
+		{"error", MatchAll{in, bad}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.all.Match(rec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchAll.Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MatchAll.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
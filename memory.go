@@ -0,0 +1,83 @@
+package ais
+
+import (
+	"fmt"
+	"io"
+)
+
+// MemRecordSet holds the Records of a RecordSet in a slice so that they can be
+// accessed at random and read any number of times.  Use it for data that fits
+// in memory and for test fixtures that should not touch the disk.  The
+// RecordSet method returns a *RecordSet that reads the slice, so the methods of
+// RecordSet such as Subset, AppendField and SortByTime work on a MemRecordSet.
+//
+//	m := ais.NewMemRecordSet(h, recs...)
+//	rec := m.At(3)
+//	rs2, err := m.RecordSet().Subset(box)
+type MemRecordSet struct {
+	h    Headers
+	recs []Record
+}
+
+// NewMemRecordSet returns a *MemRecordSet with the Headers h that holds recs.
+func NewMemRecordSet(h Headers, recs ...Record) *MemRecordSet {
+	return &MemRecordSet{h: h, recs: recs}
+}
+
+// Load reads the Records remaining in the RecordSet into a *MemRecordSet.
+func (rs *RecordSet) Load() (*MemRecordSet, error) {
+	recs, err := rs.loadRecords()
+	if err != nil {
+		return nil, fmt.Errorf("recordset load: %v", err)
+	}
+	return NewMemRecordSet(rs.Headers(), *recs...), nil
+}
+
+// Headers returns the Headers of the MemRecordSet.
+func (m *MemRecordSet) Headers() Headers { return m.h }
+
+// Len returns the number of Records in the MemRecordSet.
+func (m *MemRecordSet) Len() int { return len(m.recs) }
+
+// At returns the Record at index i.  It panics if i is out of range.
+func (m *MemRecordSet) At(i int) *Record { return &m.recs[i] }
+
+// Append adds recs to the end of the MemRecordSet.
+func (m *MemRecordSet) Append(recs ...Record) {
+	m.recs = append(m.recs, recs...)
+}
+
+// Slice returns a *MemRecordSet holding the Records from index i up to but not
+// including j.  The Records are shared with m.
+func (m *MemRecordSet) Slice(i, j int) *MemRecordSet {
+	return &MemRecordSet{h: m.h, recs: m.recs[i:j:j]}
+}
+
+// RecordSet returns a *RecordSet that reads the Records of the MemRecordSet from
+// the first one.  Each call returns a new *RecordSet, so the Records can be read
+// any number of times.  Writes to the returned RecordSet are not added to the
+// MemRecordSet.
+func (m *MemRecordSet) RecordSet() *RecordSet {
+	rs := NewRecordSet()
+	rs.h = m.h
+	rs.src = &sliceReader{recs: m.recs}
+	return rs
+}
+
+// sliceReader reads the Records of a slice in order.
+type sliceReader struct {
+	recs []Record
+	next int
+}
+
+// Read returns a copy of the fields of the next Record, or io.EOF after the
+// last one.  Consumers such as VesselRegistry.Join edit the Records they read
+// in place, and the copy keeps those edits out of the MemRecordSet.
+func (s *sliceReader) Read() ([]string, error) {
+	if s.next >= len(s.recs) {
+		return nil, io.EOF
+	}
+	rec := append(Record(nil), s.recs[s.next]...)
+	s.next++
+	return rec, nil
+}
@@ -0,0 +1,112 @@
+package ais
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordSet_Load(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	m, err := rs.Load()
+	if err != nil {
+		t.Fatalf("RecordSet.Load() error = %v", err)
+	}
+	if m.Len() != 10 {
+		t.Fatalf("MemRecordSet.Len() = %d, want 10", m.Len())
+	}
+	if got := (*m.At(9))[0]; got != "367180910" {
+		t.Errorf("MemRecordSet.At(9) MMSI = %s, want 367180910", got)
+	}
+	if !m.Headers().Equals(rs.Headers()) {
+		t.Errorf("MemRecordSet.Headers() = %v", m.Headers().Fields)
+	}
+
+	// Each RecordSet reads all of the Records again.
+	for i := 0; i < 2; i++ {
+		if got := countRecords(t, m.RecordSet()); got != 10 {
+			t.Errorf("pass %d read %d records, want 10", i, got)
+		}
+	}
+	if got := countRecords(t, m.Slice(2, 5).RecordSet()); got != 3 {
+		t.Errorf("MemRecordSet.Slice(2, 5) read %d records, want 3", got)
+	}
+}
+
+func TestMemRecordSet_RecordSet(t *testing.T) {
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+	recs := make([]Record, 0, 3)
+	for _, s := range []string{
+		"3,2017-12-01T00:00:03,36.5,-76.5",
+		"1,2017-12-01T00:00:01,10.0,10.0",
+		"2,2017-12-01T00:00:02,36.7,-76.1",
+	} {
+		// Leave spare capacity in each Record so that appends made by
+		// AppendField would overwrite shared memory if it were not clipped.
+		rec := make(Record, 4, 8)
+		copy(rec, strings.Split(s, ","))
+		recs = append(recs, rec)
+	}
+	m := NewMemRecordSet(h, recs...)
+
+	sorted, err := m.RecordSet().SortByTime()
+	if err != nil {
+		t.Fatalf("RecordSet.SortByTime() error = %v", err)
+	}
+	var order []string
+	for {
+		rec, err := sorted.Read()
+		if err != nil {
+			break
+		}
+		order = append(order, (*rec)[0])
+	}
+	if got := strings.Join(order, ""); got != "123" {
+		t.Errorf("RecordSet.SortByTime() order = %s, want 123", got)
+	}
+
+	box := &Box{MinLat: 36, MaxLat: 37, MinLon: -77, MaxLon: -76, LatIndex: 2, LonIndex: 3}
+	sub, err := m.RecordSet().Subset(box)
+	if err != nil {
+		t.Fatalf("RecordSet.Subset() error = %v", err)
+	}
+	if got := countRecords(t, sub); got != 2 {
+		t.Errorf("RecordSet.Subset() = %d records, want 2", got)
+	}
+
+	appended, err := m.RecordSet().AppendField("Tag", []string{"MMSI"}, tagGenerator{})
+	if err != nil {
+		t.Fatalf("RecordSet.AppendField() error = %v", err)
+	}
+	countRecords(t, appended)
+	for i, rec := range recs {
+		if len(rec) != 4 || !reflect.DeepEqual(rec[:cap(rec)][4:], make(Record, 4)) {
+			t.Errorf("AppendField() modified Record %d: %v", i, rec[:cap(rec)])
+		}
+	}
+
+	// Editing a Record read from the RecordSet does not change the MemRecordSet.
+	rs := m.RecordSet()
+	rec, err := rs.Read()
+	if err != nil {
+		t.Fatalf("RecordSet.Read() error = %v", err)
+	}
+	(*rec)[2] = "0.0"
+	if got := (*m.At(0))[2]; got != "36.5" {
+		t.Errorf("MemRecordSet.At(0) LAT = %s after editing a Record read from its RecordSet, want 36.5", got)
+	}
+
+	m.Append(Record{"4", "2017-12-01T00:00:04", "36.6", "-76.6"})
+	if m.Len() != 4 {
+		t.Errorf("MemRecordSet.Append() Len = %d, want 4", m.Len())
+	}
+}
+
+// tagGenerator returns the same Field for every Record.
+type tagGenerator struct{}
+
+func (tagGenerator) Generate(rec Record, index ...int) (Field, error) { return "tag", nil }
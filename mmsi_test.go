@@ -0,0 +1,112 @@
+package ais
+
+import (
+	"testing"
+)
+
+func TestParseMMSI(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    MMSI
+		wantErr bool
+	}{
+		{"hong kong ship", "477307901", MMSI{"477307901", 477, "Hong Kong", StationShip, true}, false},
+		{"united states ship", "367095148", MMSI{"367095148", 367, "United States", StationShip, true}, false},
+		{"panama ship", "355813007", MMSI{"355813007", 355, "Panama", StationShip, true}, false},
+		{"unallocated mid", "399123456", MMSI{"399123456", 399, "", StationShip, false}, false},
+		{"group", "036612345", MMSI{"036612345", 366, "United States", StationGroup, true}, false},
+		{"coast station", "003669999", MMSI{"003669999", 366, "United States", StationCoast, true}, false},
+		{"sar aircraft", "111232501", MMSI{"111232501", 232, "United Kingdom", StationSARAircraft, true}, false},
+		{"aton", "993672001", MMSI{"993672001", 367, "United States", StationAtoN, true}, false},
+		{"auxiliary craft", "982191234", MMSI{"982191234", 219, "Denmark", StationAuxiliary, true}, false},
+		{"handheld", "823512345", MMSI{"823512345", 235, "United Kingdom", StationHandheld, true}, false},
+		{"sart", "970010000", MMSI{"970010000", 0, "", StationSART, true}, false},
+		{"mob", "972010000", MMSI{"972010000", 0, "", StationMOB, true}, false},
+		{"epirb", "974010000", MMSI{"974010000", 0, "", StationEPIRB, true}, false},
+		{"leading one", "123456789", MMSI{"123456789", 0, "", StationUnknown, false}, false},
+		{"short", "47730790", MMSI{}, true},
+		{"not digits", "47730790x", MMSI{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMMSI(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMMSI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMMSI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationClass_String(t *testing.T) {
+	if got := StationAtoN.String(); got != "aid to navigation" {
+		t.Errorf("StationAtoN.String() = %q", got)
+	}
+	if got := StationClass(42).String(); got != "StationClass(42)" {
+		t.Errorf("StationClass(42).String() = %q", got)
+	}
+}
+
+func TestFlagGenerator_Generate(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/badMMSIData.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	rs2, err := rs.AppendField("Flag", []string{"MMSI"}, FlagGenerator{})
+	if err != nil {
+		t.Fatalf("AppendField() error = %v", err)
+	}
+	flagIndex, _ := rs2.Headers().Contains("Flag")
+	want := map[string]string{
+		"477307901": "Hong Kong",
+		"47730790x": "",
+		"338029922": "United States",
+		"538007024": "Marshall Islands",
+	}
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		if w, ok := want[(*rec)[0]]; ok && (*rec)[flagIndex] != w {
+			t.Errorf("FlagGenerator for %s = %q, want %q", (*rec)[0], (*rec)[flagIndex], w)
+		}
+	}
+
+	if _, err := (FlagGenerator{}).Generate(Record{"477307901"}); err == nil {
+		t.Errorf("Generate() with no index error = nil, want error")
+	}
+}
+
+func TestShipStationMatcher_Match(t *testing.T) {
+	tests := []struct {
+		mmsi string
+		want bool
+	}{
+		{"477307901", true},
+		{"993672001", false},
+		{"003669999", false},
+		{"111232501", false},
+		{"970010000", false},
+		{"399123456", false},
+		{"47730790x", false},
+	}
+	sm := &ShipStationMatcher{MMSIIndex: 0}
+	for _, tt := range tests {
+		got, err := sm.Match(&Record{tt.mmsi})
+		if err != nil {
+			t.Fatalf("ShipStationMatcher.Match(%s) error = %v", tt.mmsi, err)
+		}
+		if got != tt.want {
+			t.Errorf("ShipStationMatcher.Match(%s) = %v, want %v", tt.mmsi, got, tt.want)
+		}
+	}
+	if _, err := sm.Match(&Record{}); err == nil {
+		t.Errorf("ShipStationMatcher.Match() out of range error = nil, want error")
+	}
+}
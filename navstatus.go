@@ -0,0 +1,210 @@
+package ais
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NavStatus is the navigational status of a vessel as defined by the AIS
+// message 1, 2 and 3 field of the same name in Recommendation ITU-R M.1371.
+// The value of a NavStatus is its AIS code.
+type NavStatus int
+
+// The navigational status codes 0 through 15.  NavNotDefined is the AIS default
+// for transmitters that do not set a status.
+const (
+	NavUnderWayEngine NavStatus = iota
+	NavAtAnchor
+	NavNotUnderCommand
+	NavRestrictedManoeuvrability
+	NavConstrainedByDraught
+	NavMoored
+	NavAground
+	NavFishing
+	NavUnderWaySailing
+	NavReservedHSC
+	NavReservedWIG
+	NavTowingAstern
+	NavPushingAhead
+	NavReserved
+	NavAISSARTActive
+	NavNotDefined
+)
+
+// navStatusNames are the canonical text values of each NavStatus.  They match
+// the lower case descriptions used in MarineCadastre data.
+var navStatusNames = [...]string{
+	"under way using engine",
+	"at anchor",
+	"not under command",
+	"restricted manoeuvrability",
+	"constrained by her draught",
+	"moored",
+	"aground",
+	"engaged in fishing",
+	"under way sailing",
+	"reserved for future amendment hsc",
+	"reserved for future amendment wig",
+	"power-driven vessel towing astern",
+	"power-driven vessel pushing ahead or towing alongside",
+	"reserved for future use",
+	"ais-sart active",
+	"not defined",
+}
+
+// navStatusAliases maps normalized free text that is not a canonical name to a
+// NavStatus.
+var navStatusAliases = map[string]NavStatus{
+	"underway":                           NavUnderWayEngine,
+	"under way":                          NavUnderWayEngine,
+	"anchored":                           NavAtAnchor,
+	"at anchorage":                       NavAtAnchor,
+	"restricted manoeuverability":        NavRestrictedManoeuvrability,
+	"restricted maneuverability":         NavRestrictedManoeuvrability,
+	"restricted in ability to manoeuvre": NavRestrictedManoeuvrability,
+	"constrained by draught":             NavConstrainedByDraught,
+	"constrained by her draft":           NavConstrainedByDraught,
+	"constrained by draft":               NavConstrainedByDraught,
+	"fishing":                            NavFishing,
+	"sailing":                            NavUnderWaySailing,
+	"towing astern":                      NavTowingAstern,
+	"pushing ahead or towing alongside":  NavPushingAhead,
+	"sart active":                        NavAISSARTActive,
+	"ais sart active":                    NavAISSARTActive,
+	"undefined":                          NavNotDefined,
+	"not defined default":                NavNotDefined,
+	"default":                            NavNotDefined,
+}
+
+// navStatusLookup maps the normalized canonical names and aliases to a
+// NavStatus.
+var navStatusLookup = func() map[string]NavStatus {
+	m := make(map[string]NavStatus)
+	for i, name := range navStatusNames {
+		m[normalizeStatus(name)] = NavStatus(i)
+	}
+	for alias, ns := range navStatusAliases {
+		m[normalizeStatus(alias)] = ns
+	}
+	return m
+}()
+
+// String returns the canonical text of the NavStatus.
+func (ns NavStatus) String() string {
+	if ns < 0 || int(ns) >= len(navStatusNames) {
+		return fmt.Sprintf("NavStatus(%d)", int(ns))
+	}
+	return navStatusNames[ns]
+}
+
+// ParseNavStatus converts the Status value of a Record into a NavStatus.  It
+// accepts the numeric codes 0 through 15 and the free text variants found in
+// AIS data, so "underway using engines", "Under Way Using Engine" and "0" all
+// return NavUnderWayEngine.  It returns an error for blank values and text that
+// is not recognized.
+func ParseNavStatus(s string) (NavStatus, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return NavNotDefined, fmt.Errorf("nav status: blank value")
+	}
+	if code, err := strconv.Atoi(s); err == nil {
+		if code < 0 || code >= len(navStatusNames) {
+			return NavNotDefined, fmt.Errorf("nav status: code %d out of range", code)
+		}
+		return NavStatus(code), nil
+	}
+	if ns, ok := navStatusLookup[normalizeStatus(s)]; ok {
+		return ns, nil
+	}
+	return NavNotDefined, fmt.Errorf("nav status: unable to parse %q", s)
+}
+
+// ParseNavStatus returns the NavStatus from the index value of a field in the
+// AIS Record.  Useful for getting a reliable status from the Status field.
+func (r Record) ParseNavStatus(index int) (NavStatus, error) {
+	return ParseNavStatus(r[index])
+}
+
+// normalizeStatus lower cases s, removes punctuation and collapses the
+// spelling variants of common words so that the text variants of a status
+// compare equal.
+func normalizeStatus(s string) string {
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return ' '
+	}, s)
+	words := strings.Fields(s)
+	var out []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch w {
+		case "underway":
+			out = append(out, "under", "way")
+			continue
+		case "engines":
+			w = "engine"
+		case "power", "powered":
+			if i+1 < len(words) && words[i+1] == "driven" {
+				i++
+			}
+			w = "powerdriven"
+		}
+		out = append(out, w)
+	}
+	return strings.Join(out, " ")
+}
+
+// NavStatusNormalizer implements the Generator interface to append the
+// canonical text of the NavStatus of each Record to a RecordSet.  The single
+// index value must be the index of "Status" in the Record.
+//
+//	rs, err = rs.AppendField("NavStatus", []string{"Status"}, ais.NavStatusNormalizer{})
+type NavStatusNormalizer struct{}
+
+// Generate implements the Generator interface for a NavStatusNormalizer.  Blank
+// and unrecognized values generate the text of NavNotDefined rather than an
+// error so that AppendField does not stop on Records without a status.
+func (NavStatusNormalizer) Generate(rec Record, index ...int) (Field, error) {
+	if len(index) != 1 {
+		return "", fmt.Errorf("nav status normalizer: generate: len(index) must equal" +
+			" 1 where the int is the index of `Status`")
+	}
+	val, ok := rec.Value(index[0])
+	if !ok {
+		return "", fmt.Errorf("nav status normalizer: index %d out of range", index[0])
+	}
+	ns, _ := ParseNavStatus(val)
+	return Field(ns.String()), nil
+}
+
+// NavStatusMatcher implements the Matching interface to subset a RecordSet by
+// navigational status.  StatusIndex is the index of "Status" in a Record.  A
+// Record matches when its status is one of Statuses.  Blank and unrecognized
+// values are treated as NavNotDefined.
+//
+//	m := &ais.NavStatusMatcher{StatusIndex: idx, Statuses: []ais.NavStatus{ais.NavMoored, ais.NavAtAnchor}}
+//	stationary, err := rs.Subset(m)
+type NavStatusMatcher struct {
+	StatusIndex int
+	Statuses    []NavStatus
+}
+
+// Match implements the Matching interface for a NavStatusMatcher.  The only
+// error returned is for a StatusIndex that is out of range for the Record.
+func (nm *NavStatusMatcher) Match(rec *Record) (bool, error) {
+	val, ok := rec.Value(nm.StatusIndex)
+	if !ok {
+		return false, fmt.Errorf("nav status matcher: index %d out of range", nm.StatusIndex)
+	}
+	ns, _ := ParseNavStatus(val)
+	for _, s := range nm.Statuses {
+		if s == ns {
+			return true, nil
+		}
+	}
+	return false, nil
+}
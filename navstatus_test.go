@@ -0,0 +1,111 @@
+package ais
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNavStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    NavStatus
+		wantErr bool
+	}{
+		{"canonical", "under way using engine", NavUnderWayEngine, false},
+		{"underway variant", "underway using engines", NavUnderWayEngine, false},
+		{"mixed case", "Under Way Using Engine", NavUnderWayEngine, false},
+		{"code", "0", NavUnderWayEngine, false},
+		{"at anchor", "at anchor", NavAtAnchor, false},
+		{"anchored", "Anchored", NavAtAnchor, false},
+		{"moored", " moored ", NavMoored, false},
+		{"moored code", "5", NavMoored, false},
+		{"fishing", "engaged in fishing", NavFishing, false},
+		{"draft spelling", "constrained by her draft", NavConstrainedByDraught, false},
+		{"maneuverability spelling", "restricted maneuverability", NavRestrictedManoeuvrability, false},
+		{"power driven", "Power Driven Vessel Towing Astern", NavTowingAstern, false},
+		{"sart", "AIS-SART Active", NavAISSARTActive, false},
+		{"not defined code", "15", NavNotDefined, false},
+		{"code out of range", "16", NavNotDefined, true},
+		{"blank", "", NavNotDefined, true},
+		{"garbage", "steaming merrily", NavNotDefined, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNavStatus(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNavStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseNavStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNavStatus_String(t *testing.T) {
+	for i := range navStatusNames {
+		ns := NavStatus(i)
+		got, err := ParseNavStatus(ns.String())
+		if err != nil || got != ns {
+			t.Errorf("ParseNavStatus(%q) = %v, %v, want %v", ns.String(), got, err, ns)
+		}
+	}
+	if got := NavStatus(20).String(); got != "NavStatus(20)" {
+		t.Errorf("NavStatus(20).String() = %q", got)
+	}
+}
+
+func TestNavStatusNormalizer_Generate(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/track.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	rs2, err := rs.AppendField("NavStatus", []string{"Status"}, NavStatusNormalizer{})
+	if err != nil {
+		t.Fatalf("AppendField() error = %v", err)
+	}
+	statusIndex, _ := rs2.Headers().Contains("Status")
+	navIndex, _ := rs2.Headers().Contains("NavStatus")
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		want := NavNotDefined
+		if (*rec)[statusIndex] != "" {
+			want, err = rec.ParseNavStatus(statusIndex)
+			if err != nil {
+				t.Fatalf("Record.ParseNavStatus() error = %v", err)
+			}
+		}
+		if got := (*rec)[navIndex]; got != want.String() {
+			t.Errorf("NavStatusNormalizer for %q = %q, want %q", (*rec)[statusIndex], got, want)
+		}
+	}
+
+	if _, err := (NavStatusNormalizer{}).Generate(Record{"moored"}); err == nil {
+		t.Errorf("Generate() with no index error = nil, want error")
+	}
+}
+
+func TestNavStatusMatcher_Match(t *testing.T) {
+	m := &NavStatusMatcher{StatusIndex: 0, Statuses: []NavStatus{NavMoored, NavAtAnchor}}
+	var got []bool
+	for _, s := range []string{"moored", "at anchor", "1", "underway using engines", ""} {
+		rec := Record{s}
+		match, err := m.Match(&rec)
+		if err != nil {
+			t.Fatalf("NavStatusMatcher.Match() error = %v", err)
+		}
+		got = append(got, match)
+	}
+	if want := []bool{true, true, true, false, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NavStatusMatcher.Match() = %v, want %v", got, want)
+	}
+	if _, err := m.Match(&Record{}); err == nil {
+		t.Errorf("NavStatusMatcher.Match() out of range error = nil, want error")
+	}
+}
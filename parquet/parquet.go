@@ -0,0 +1,331 @@
+// Package parquet registers Apache Parquet as an ais.Format, so that
+// ais.OpenRecordSet reads Parquet files and RecordSet.Save and
+// Interactions.Save write them.  It is kept apart from package ais so that
+// programs that do not need Parquet do not depend on parquet-go.  Import it for
+// its side effect.
+//
+//	import _ "github.com/FATHOM5/ais/parquet"
+//
+// Each field is written as an optional Parquet column.  The column type is the
+// Type of the field in the data dictionary of the Headers, or else the type in
+// Types.  Other fields, including IMO, are written as UTF8 strings exactly as
+// they appear in the Record.  Blank values of typed columns are written as
+// nulls.  TypeInt fields are INT64, TypeFloat fields are DOUBLE and TypeTime
+// fields are UTC millisecond TIMESTAMPs parsed with Headers.ParseTime.  Values
+// that cannot be parsed as the column type return an error.  Typed values are
+// read back in their canonical form, so a SOG of "0.0" is read as "0",
+// timestamps are read in the ais.TimeLayout format and an INT64 MMSI is read
+// as nine digits with its leading zeros.
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FATHOM5/ais"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Ext is the filename extension that RecordSet.Save and Interactions.Save use
+// to write Apache Parquet.  ais.OpenRecordSet detects Parquet files from their
+// content, so they can be opened under any name.
+const Ext = ".parquet"
+
+const magic = "PAR1"
+
+func init() {
+	ais.RegisterFormat(ais.Format{
+		Name:  "parquet",
+		Ext:   Ext,
+		Magic: magic,
+		Open:  open,
+		Write: write,
+	})
+}
+
+// Types are the column types written to Parquet for the canonical fields.
+// Fields of a RecordSet with a Schema are matched through the Schema, and the
+// fields of Interactions through their _1 and _2 suffixes.
+var Types = map[string]ais.FieldType{
+	"MMSI":         ais.TypeInt,
+	"BaseDateTime": ais.TypeTime,
+	"LAT":          ais.TypeFloat,
+	"LON":          ais.TypeFloat,
+	"SOG":          ais.TypeFloat,
+	"COG":          ais.TypeFloat,
+	"Heading":      ais.TypeFloat,
+	"Length":       ais.TypeFloat,
+	"Width":        ais.TypeFloat,
+	"Draft":        ais.TypeFloat,
+	"Distance(nm)": ais.TypeFloat,
+}
+
+// batch is the number of Records read from each column at a time.
+const batch = 4096
+
+// fieldType returns the FieldType written to Parquet for the field at index.
+func fieldType(h ais.Headers, index int) ais.FieldType {
+	field := h.Fields[index]
+	if d, ok := h.Definition(field); ok && d.Type != "" {
+		return d.Type
+	}
+	for canonical, t := range Types {
+		if i, ok := h.Contains(canonical); ok && i == index {
+			return t
+		}
+	}
+	for _, suffix := range []string{"_1", "_2"} {
+		if t, ok := Types[strings.TrimSuffix(field, suffix)]; ok && strings.HasSuffix(field, suffix) {
+			return t
+		}
+	}
+	return ais.TypeString
+}
+
+// metadata returns the parquet-go metadata for the column at index.
+func metadata(h ais.Headers, index int) (string, error) {
+	field := h.Fields[index]
+	if strings.ContainsAny(field, ",=") || strings.TrimSpace(field) != field || field == "" {
+		return "", fmt.Errorf("parquet: field name %q cannot be written", field)
+	}
+	md := "name=" + field + ", repetitiontype=OPTIONAL, "
+	switch fieldType(h, index) {
+	case ais.TypeInt:
+		md += "type=INT64"
+	case ais.TypeFloat:
+		md += "type=DOUBLE"
+	case ais.TypeTime:
+		md += "type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"
+	default:
+		md += "type=BYTE_ARRAY, convertedtype=UTF8"
+	}
+	return md, nil
+}
+
+// write writes the Records returned by next to w until next returns io.EOF.
+func write(w io.Writer, h ais.Headers, next func() ([]string, error)) error {
+	md := make([]string, len(h.Fields))
+	types := make([]ais.FieldType, len(h.Fields))
+	for i := range h.Fields {
+		var err error
+		if md[i], err = metadata(h, i); err != nil {
+			return err
+		}
+		types[i] = fieldType(h, i)
+	}
+	pw, err := writer.NewCSVWriterFromWriter(md, w, 4)
+	if err != nil {
+		return fmt.Errorf("parquet: %v", err)
+	}
+	// parquet-go stores a mangled name for fields that are not Go identifiers,
+	// so restore the field names before the footer is written.
+	for i, field := range h.Fields {
+		pw.SchemaHandler.SchemaElements[i+1].Name = field
+	}
+
+	for line := 1; ; line++ {
+		rec, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parquet: read error: %v", err)
+		}
+		if len(rec) != len(h.Fields) {
+			return fmt.Errorf("parquet: record %d has %d fields, want %d", line, len(rec), len(h.Fields))
+		}
+		row := make([]interface{}, len(rec))
+		for i, s := range rec {
+			if types[i] != ais.TypeString && strings.TrimSpace(s) == "" {
+				continue
+			}
+			if row[i], err = value(h, ais.Record(rec), i, types[i]); err != nil {
+				return fmt.Errorf("parquet: record %d: %s: %v", line, h.Fields[i], err)
+			}
+		}
+		if err := pw.Write(row); err != nil {
+			return fmt.Errorf("parquet: %v", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("parquet: %v", err)
+	}
+	return nil
+}
+
+// value converts the value at index of rec to the Go type parquet-go writes for
+// t.
+func value(h ais.Headers, rec ais.Record, index int, t ais.FieldType) (interface{}, error) {
+	s := strings.TrimSpace(rec[index])
+	switch t {
+	case ais.TypeInt:
+		return strconv.ParseInt(s, 10, 64)
+	case ais.TypeFloat:
+		return strconv.ParseFloat(s, 64)
+	case ais.TypeTime:
+		tm, err := h.ParseTime(rec, index)
+		if err != nil {
+			return nil, err
+		}
+		return tm.UnixNano() / int64(time.Millisecond), nil
+	}
+	return rec[index], nil
+}
+
+// fileReader reads the Records of a Parquet file a batch at a time from each
+// column.  Only files with a flat schema of primitive columns can be read.
+type fileReader struct {
+	pr     *reader.ParquetReader
+	fields []string
+	mmsi   []bool // columns that hold an MMSI
+	rows   [][]string
+	left   int64
+}
+
+// open opens the Parquet file name and reads its schema.
+func open(name string) (ais.FormatReader, error) {
+	pf, err := local.NewLocalFileReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %v", err)
+	}
+	pr, err := reader.NewParquetColumnReader(pf, 1)
+	if err != nil {
+		pf.Close()
+		return nil, fmt.Errorf("parquet: %v", err)
+	}
+	p := &fileReader{pr: pr, left: pr.GetNumRows()}
+	elems := pr.SchemaHandler.SchemaElements
+	for i, se := range elems[1:] {
+		if se.GetNumChildren() > 0 || se.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+			p.Close()
+			return nil, fmt.Errorf("parquet: nested column %s is not supported", pr.SchemaHandler.Infos[i+1].ExName)
+		}
+		p.fields = append(p.fields, pr.SchemaHandler.Infos[i+1].ExName)
+	}
+	p.mmsi = mmsiColumns(p.fields)
+	return p, nil
+}
+
+// mmsiColumns reports which of fields hold an MMSI, matched through the
+// inferred Schema and the _1 and _2 suffixes of Interactions as in fieldType.
+func mmsiColumns(fields []string) []bool {
+	s, _ := ais.InferSchema(fields)
+	col := s.Column("MMSI")
+	mmsi := make([]bool, len(fields))
+	for i, field := range fields {
+		mmsi[i] = field == col || field == "MMSI_1" || field == "MMSI_2"
+	}
+	return mmsi
+}
+
+// Fields returns the column names of the file.
+func (p *fileReader) Fields() []string { return p.fields }
+
+// Read returns the fields of the next Record, or io.EOF after the last one.
+func (p *fileReader) Read() ([]string, error) {
+	if len(p.rows) == 0 {
+		if p.left <= 0 {
+			return nil, io.EOF
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	row := p.rows[0]
+	p.rows = p.rows[1:]
+	return row, nil
+}
+
+// next reads the next batch of Records.
+func (p *fileReader) next() error {
+	n := p.left
+	if n > batch {
+		n = batch
+	}
+	rows := make([][]string, n)
+	for i := range rows {
+		rows[i] = make([]string, len(p.fields))
+	}
+	elems := p.pr.SchemaHandler.SchemaElements[1:]
+	for col := range p.fields {
+		values, _, _, err := p.pr.ReadColumnByIndex(int64(col), n)
+		if err != nil {
+			return fmt.Errorf("parquet: column %s: %v", p.fields[col], err)
+		}
+		if int64(len(values)) != n {
+			return fmt.Errorf("parquet: column %s: read %d values, want %d", p.fields[col], len(values), n)
+		}
+		for i, v := range values {
+			if n, ok := v.(int64); ok && p.mmsi[col] {
+				rows[i][col] = fmt.Sprintf("%09d", n)
+				continue
+			}
+			rows[i][col] = format(elems[col], v)
+		}
+	}
+	p.left -= n
+	p.rows = rows
+	return nil
+}
+
+// format formats a value read from the column described by se.
+func format(se *parquet.SchemaElement, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		unit, ok := timeUnit(se)
+		if !ok {
+			return strconv.FormatInt(v, 10)
+		}
+		t := time.Unix(0, v*int64(unit)).UTC()
+		if t.Nanosecond() != 0 {
+			return t.Format("2006-01-02T15:04:05.999999999")
+		}
+		return t.Format(ais.TimeLayout)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// timeUnit returns the unit of an INT64 timestamp column and false for other
+// columns.
+func timeUnit(se *parquet.SchemaElement) (time.Duration, bool) {
+	if lt := se.GetLogicalType(); lt != nil && lt.IsSetTIMESTAMP() {
+		switch unit := lt.TIMESTAMP.GetUnit(); {
+		case unit.IsSetMILLIS():
+			return time.Millisecond, true
+		case unit.IsSetMICROS():
+			return time.Microsecond, true
+		}
+		return time.Nanosecond, true
+	}
+	switch se.GetConvertedType() {
+	case parquet.ConvertedType_TIMESTAMP_MILLIS:
+		return time.Millisecond, se.IsSetConvertedType()
+	case parquet.ConvertedType_TIMESTAMP_MICROS:
+		return time.Microsecond, se.IsSetConvertedType()
+	}
+	return 0, false
+}
+
+// Close releases the files held by the parquet-go reader.
+func (p *fileReader) Close() error {
+	p.pr.ReadStop()
+	return p.pr.PFile.Close()
+}
@@ -0,0 +1,320 @@
+package parquet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FATHOM5/ais"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// readAll returns the Records remaining in rs.
+func readAll(t *testing.T, rs *ais.RecordSet) []ais.Record {
+	t.Helper()
+	var recs []ais.Record
+	for {
+		rec, err := rs.Read()
+		if err != nil {
+			break
+		}
+		recs = append(recs, *rec)
+	}
+	return recs
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"ten", "../testdata/ten.csv"},
+		{"track", "../testdata/track.csv"},
+		{"dma", "../testdata/dma.csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := ais.OpenRecordSet(tt.file)
+			if err != nil {
+				t.Fatalf("ais.OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			name := filepath.Join(dir, tt.name+Ext)
+			if err := rs.Save(name); err != nil {
+				t.Fatalf("RecordSet.Save() error = %v", err)
+			}
+
+			want, _ := ais.OpenRecordSet(tt.file)
+			defer want.Close()
+			got, err := ais.OpenRecordSet(name)
+			if err != nil {
+				t.Fatalf("ais.OpenRecordSet(parquet) error = %v", err)
+			}
+			defer got.Close()
+
+			h := want.Headers()
+			if !got.Headers().Equals(h) {
+				t.Fatalf("ais.Headers = %v, want %v", got.Headers().Fields, h.Fields)
+			}
+			gotRecs, wantRecs := readAll(t, got), readAll(t, want)
+			if len(gotRecs) != len(wantRecs) {
+				t.Fatalf("read %d records, want %d", len(gotRecs), len(wantRecs))
+			}
+			for i := range wantRecs {
+				for j := range h.Fields {
+					if !sameValue(t, h, j, gotRecs[i], wantRecs[i]) {
+						t.Errorf("record %d %s = %q, want %q", i, h.Fields[j], gotRecs[i][j], wantRecs[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+// sameValue compares the values at index of got and want as the parquet type
+// of the field.
+func sameValue(t *testing.T, h ais.Headers, index int, got, want ais.Record) bool {
+	t.Helper()
+	typ := fieldType(h, index)
+	if typ != ais.TypeString && strings.TrimSpace(want[index]) == "" {
+		return got[index] == ""
+	}
+	switch typ {
+	case ais.TypeInt:
+		g, err1 := strconv.ParseInt(got[index], 10, 64)
+		w, err2 := strconv.ParseInt(strings.TrimSpace(want[index]), 10, 64)
+		return err1 == nil && err2 == nil && g == w
+	case ais.TypeFloat:
+		g, err1 := strconv.ParseFloat(got[index], 64)
+		w, err2 := strconv.ParseFloat(strings.TrimSpace(want[index]), 64)
+		return err1 == nil && err2 == nil && g == w
+	case ais.TypeTime:
+		g, err1 := got.ParseTime(index)
+		w, err2 := h.ParseTime(want, index)
+		return err1 == nil && err2 == nil && g.Equal(w)
+	}
+	return got[index] == want[index]
+}
+
+func TestSaveTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs, err := ais.OpenRecordSet("../testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("ais.OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	name := filepath.Join(dir, "ten"+Ext)
+	if err := rs.Save(name); err != nil {
+		t.Fatalf("RecordSet.Save() error = %v", err)
+	}
+
+	pf, err := local.NewLocalFileReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(pf, 1)
+	if err != nil {
+		t.Fatalf("NewParquetColumnReader() error = %v", err)
+	}
+	defer pf.Close()
+	defer pr.ReadStop()
+
+	want := map[string]parquet.Type{
+		"MMSI":         parquet.Type_INT64,
+		"BaseDateTime": parquet.Type_INT64,
+		"LAT":          parquet.Type_DOUBLE,
+		"LON":          parquet.Type_DOUBLE,
+		"VesselName":   parquet.Type_BYTE_ARRAY,
+	}
+	for i, se := range pr.SchemaHandler.SchemaElements[1:] {
+		field := pr.SchemaHandler.Infos[i+1].ExName
+		if w, ok := want[field]; ok && se.GetType() != w {
+			t.Errorf("column %s type = %v, want %v", field, se.GetType(), w)
+		}
+		if _, ok := timeUnit(se); ok != (field == "BaseDateTime") {
+			t.Errorf("column %s timestamp = %v", field, ok)
+		}
+	}
+	if n := pr.GetNumRows(); n != 10 {
+		t.Errorf("GetNumRows() = %d, want 10", n)
+	}
+}
+
+func TestSaveStrings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs := ais.NewRecordSet()
+	rs.SetHeaders(ais.Headers{Fields: []string{"MMSI", "BaseDateTime", "SOG", "VesselName", "IMO"}})
+	want := []ais.Record{
+		{"003669999", "2017-12-01T00:00:01", "", "", ""},
+		{"036699999", "2017-12-01T00:00:02", "1.5", " ", "IMO9074729"},
+	}
+	for _, rec := range want {
+		rs.Write(rec)
+	}
+	rs.Flush()
+	name := filepath.Join(dir, "strings"+Ext)
+	if err := rs.Save(name); err != nil {
+		t.Fatalf("RecordSet.Save() error = %v", err)
+	}
+
+	got, err := ais.OpenRecordSet(name)
+	if err != nil {
+		t.Fatalf("ais.OpenRecordSet(parquet) error = %v", err)
+	}
+	defer got.Close()
+	recs := readAll(t, got)
+	if !reflect.DeepEqual(recs, want) {
+		t.Errorf("read %q, want %q", recs, want)
+	}
+	classes := []ais.StationClass{ais.StationCoast, ais.StationGroup}
+	for i, rec := range recs {
+		if m, err := ais.ParseMMSI(rec[0]); err != nil || m.Class != classes[i] {
+			t.Errorf("ais.ParseMMSI(%s) = %v, %v, want class %v", rec[0], m.Class, err, classes[i])
+		}
+	}
+
+	// MMSI is written as an INT64, blank strings as empty strings and blank
+	// typed values as nulls.
+	pf, err := local.NewLocalFileReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(pf, 1)
+	if err != nil {
+		t.Fatalf("NewParquetColumnReader() error = %v", err)
+	}
+	defer pf.Close()
+	defer pr.ReadStop()
+	for col, want := range map[int64]interface{}{0: int64(3669999), 2: nil, 3: "", 4: ""} {
+		values, _, _, err := pr.ReadColumnByIndex(col, 1)
+		if err != nil || len(values) != 1 || values[0] != want {
+			t.Errorf("column %d values = %#v, %v, want %#v", col, values, err, want)
+		}
+	}
+}
+
+func TestSaveBadValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs := ais.NewRecordSet()
+	rs.SetHeaders(ais.NewHeaders([]string{"MMSI", "BaseDateTime", "VesselName"},
+		[]ais.Definition{{Fieldname: "VesselName", Type: ais.TypeInt}}))
+	rs.Write(ais.Record{"367157579", "2017-12-01T00:00:01", "FIRST"})
+	rs.Flush()
+	if err := rs.Save(filepath.Join(dir, "bad"+Ext)); err == nil {
+		t.Errorf("RecordSet.Save() with a bad value error = nil, want error")
+	}
+}
+
+func TestSaveCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs, err := ais.OpenRecordSet("../testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	if err := rs.Save(filepath.Join(dir, "ten.parquet.gz")); err == nil {
+		t.Errorf("RecordSet.Save(ten.parquet.gz) error = nil, want error")
+	}
+}
+
+func TestFieldType(t *testing.T) {
+	inter, _ := ais.NewInteractions(ais.Headers{Fields: strings.Split("MMSI,BaseDateTime,LAT,LON,SOG,COG,Heading,VesselName,IMO,CallSign,VesselType,Status,Length,Width,Draft,Cargo", ",")})
+	dma := ais.NewRecordSet()
+	dma.SetHeaders(ais.Headers{Fields: []string{"# Timestamp", "MMSI", "Latitude", "Longitude", "Name"}})
+	if err := dma.SetSchema(ais.DMASchema); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		h     ais.Headers
+		field string
+		want  ais.FieldType
+	}{
+		{"mmsi", ais.Headers{Fields: []string{"MMSI"}}, "MMSI", ais.TypeInt},
+		{"interaction mmsi", inter.OutputHeaders, "MMSI_2", ais.TypeInt},
+		{"imo", ais.Headers{Fields: []string{"IMO"}}, "IMO", ais.TypeString},
+		{"time", ais.Headers{Fields: []string{"BaseDateTime"}}, "BaseDateTime", ais.TypeTime},
+		{"string", ais.Headers{Fields: []string{"VesselType"}}, "VesselType", ais.TypeString},
+		{"schema", dma.Headers(), "Latitude", ais.TypeFloat},
+		{"schema time", dma.Headers(), "# Timestamp", ais.TypeTime},
+		{"interaction", inter.OutputHeaders, "LAT_2", ais.TypeFloat},
+		{"interaction distance", inter.OutputHeaders, "Distance(nm)", ais.TypeFloat},
+		{"interaction hash", inter.OutputHeaders, "InteractionHash", ais.TypeString},
+		{"dictionary", ais.NewHeaders([]string{"SOG"}, []ais.Definition{{Fieldname: "SOG", Type: ais.TypeString}}), "SOG", ais.TypeString},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := -1
+			for i, f := range tt.h.Fields {
+				if f == tt.field {
+					index = i
+				}
+			}
+			if got := fieldType(tt.h, index); got != tt.want {
+				t.Errorf("ais.fieldType(%s) = %v, want %v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	millis := parquet.ConvertedType_TIMESTAMP_MILLIS
+	ts := &parquet.SchemaElement{ConvertedType: &millis}
+	plain := &parquet.SchemaElement{}
+	tests := []struct {
+		name string
+		se   *parquet.SchemaElement
+		v    interface{}
+		want string
+	}{
+		{"null", plain, nil, ""},
+		{"int64", plain, int64(367157579), "367157579"},
+		{"int32", plain, int32(-5), "-5"},
+		{"double", plain, 31.905, "31.905"},
+		{"float", plain, float32(0.5), "0.5"},
+		{"bool", plain, true, "true"},
+		{"string", plain, "FIRST", "FIRST"},
+		{"timestamp", ts, time.Date(2017, 12, 1, 0, 0, 1, 0, time.UTC).UnixNano() / 1e6, "2017-12-01T00:00:01"},
+		{"timestamp millis", ts, time.Date(2017, 12, 1, 0, 0, 1, 0, time.UTC).UnixNano()/1e6 + 250, "2017-12-01T00:00:01.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(tt.se, tt.v); got != tt.want {
+				t.Errorf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
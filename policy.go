@@ -0,0 +1,245 @@
+package ais
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrorPolicy determines what the operations of a RecordSet do with a
+// malformed Record, such as a line of the csv file with the wrong number of
+// fields, a position that Box.Match cannot parse, a timestamp that SortByTime
+// cannot parse or a Record that a Generator returns an error for in
+// AppendField.  The policy is set with RecordSet.SetErrorPolicy and applies to
+// the operations that read the RecordSet.  RecordSets returned by those
+// operations use FailFast until their own policy is set.
+type ErrorPolicy int
+
+// The ErrorPolicy values.
+const (
+	// FailFast stops the operation and returns the error of the first
+	// malformed Record.  It is the default.
+	FailFast ErrorPolicy = iota
+
+	// SkipInvalid leaves malformed Records out of the result and counts
+	// them.  The count is returned by RecordSet.Skipped.
+	SkipInvalid
+
+	// RejectInvalid leaves malformed Records out of the result, counts them
+	// and passes each one to the RejectSink of the RecordSet as a
+	// *RecordError.
+	RejectInvalid
+)
+
+// String implements the Stringer interface for ErrorPolicy.
+func (p ErrorPolicy) String() string {
+	switch p {
+	case FailFast:
+		return "fail"
+	case SkipInvalid:
+		return "skip"
+	case RejectInvalid:
+		return "reject"
+	}
+	return "ErrorPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// RecordError describes a malformed Record.  Line is the line of the Record in
+// the csv data, which counts the header as line 1, or the position of the
+// Record in other sources counted the same way.  Line is 0 when it is not
+// known.  Column is the index of the field that caused the error, or -1 when
+// the error is not in a single field, and Field is its header when known.
+type RecordError struct {
+	Line   int
+	Column int
+	Field  string
+	Record Record
+	Err    error
+}
+
+// Error implements the error interface for RecordError.
+func (e *RecordError) Error() string {
+	var parts []string
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", e.Line))
+	}
+	switch {
+	case e.Field != "":
+		parts = append(parts, e.Field)
+	case e.Column >= 0:
+		parts = append(parts, fmt.Sprintf("column %d", e.Column))
+	}
+	parts = append(parts, e.Err.Error())
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns the underlying error.
+func (e *RecordError) Unwrap() error { return e.Err }
+
+// RejectSink receives the Records rejected under the RejectInvalid policy.  A
+// non-nil error from Reject stops the operation.
+type RejectSink interface {
+	Reject(e *RecordError) error
+}
+
+// RejectList is a RejectSink that collects the rejected Records in memory.
+type RejectList []*RecordError
+
+// Reject implements the RejectSink interface for RejectList.
+func (l *RejectList) Reject(e *RecordError) error {
+	*l = append(*l, e)
+	return nil
+}
+
+// RejectWriter is a RejectSink that writes the rejected Records as csv with
+// the fields Line, Column and Error appended to each one.  Flush must be
+// called after the operation to write any buffered data.
+//
+//	f, _ := os.Create("rejects.csv")
+//	rej := ais.NewRejectWriter(f, rs.Headers())
+//	rs.SetErrorPolicy(ais.RejectInvalid, rej)
+//	rs2, err := rs.Subset(box)
+//	err = rej.Flush()
+type RejectWriter struct {
+	w      *csv.Writer
+	h      Headers
+	header bool
+}
+
+// NewRejectWriter returns a *RejectWriter that writes the rejected Records of
+// a RecordSet with Headers h to w.
+func NewRejectWriter(w io.Writer, h Headers) *RejectWriter {
+	return &RejectWriter{w: csv.NewWriter(w), h: h}
+}
+
+// Reject implements the RejectSink interface for RejectWriter.  The header
+// line is written before the first rejected Record.  Records with fewer fields
+// than the Headers are padded so that the appended fields line up.
+func (rw *RejectWriter) Reject(e *RecordError) error {
+	if !rw.header {
+		rw.header = true
+		fields := append(rw.h.Fields[:len(rw.h.Fields):len(rw.h.Fields)], "Line", "Column", "Error")
+		if err := rw.w.Write(fields); err != nil {
+			return err
+		}
+	}
+	rec := make([]string, len(rw.h.Fields), len(rw.h.Fields)+3)
+	copy(rec, e.Record)
+	column := ""
+	if e.Column >= 0 {
+		column = e.Field
+		if column == "" {
+			column = strconv.Itoa(e.Column)
+		}
+	}
+	rec = append(rec, strconv.Itoa(e.Line), column, e.Err.Error())
+	return rw.w.Write(rec)
+}
+
+// Flush writes any buffered data to the underlying io.Writer and returns any
+// error from a previous Reject or the flush.
+func (rw *RejectWriter) Flush() error {
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+// SetErrorPolicy sets the ErrorPolicy of the RecordSet and the RejectSink that
+// receives the rejected Records under RejectInvalid.  The sink may be nil for
+// the other policies.
+func (rs *RecordSet) SetErrorPolicy(policy ErrorPolicy, sink RejectSink) {
+	rs.policy = policy
+	rs.sink = sink
+}
+
+// Skipped returns the number of malformed Records that have been skipped or
+// rejected by the operations on the RecordSet.
+func (rs *RecordSet) Skipped() int { return rs.skipped }
+
+// lineReader counts the lines of the csv data of a RecordSet.  Each Read
+// returns at most one line, so the csv.Reader reading from it never buffers
+// past the line it is parsing and n is the number of the line being parsed.
+type lineReader struct {
+	r       *bufio.Reader
+	pending []byte // the rest of the current line
+	started bool   // the current line has been counted
+	n       int
+}
+
+// newCSVReader returns a csv.Reader of r whose lines are counted by the
+// RecordSet.
+func (rs *RecordSet) newCSVReader(r io.Reader) *csv.Reader {
+	rs.lines = &lineReader{r: bufio.NewReader(r)}
+	return csv.NewReader(rs.lines)
+}
+
+func (lr *lineReader) Read(p []byte) (int, error) {
+	if len(lr.pending) == 0 {
+		line, err := lr.r.ReadSlice('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+		lr.pending = line
+	}
+	if !lr.started {
+		lr.n++
+		lr.started = true
+	}
+	n := copy(p, lr.pending)
+	lr.pending = lr.pending[n:]
+	if n > 0 && p[n-1] == '\n' {
+		lr.started = false
+	}
+	return n, nil
+}
+
+// recordLine returns the line that the csv Record rec, just read from the
+// RecordSet, starts on.
+func (rs *RecordSet) recordLine(rec []string) int {
+	var newlines int
+	for _, f := range rec {
+		newlines += strings.Count(f, "\n")
+	}
+	if rs.lines == nil {
+		return rs.line + 1 + newlines
+	}
+	return rs.lines.n - newlines
+}
+
+// reject applies the ErrorPolicy of the RecordSet to err for the last Record
+// read, rec.  It returns nil when rec should be skipped and the operation
+// continue, and otherwise the error that stops the operation.
+func (rs *RecordSet) reject(rec Record, column int, err error) error {
+	re, ok := err.(*RecordError)
+	if !ok {
+		re = &RecordError{Column: column, Err: err}
+	}
+	re.Line = rs.line
+	re.Record = rec
+	if re.Field == "" && re.Column >= 0 && re.Column < len(rs.h.Fields) {
+		re.Field = rs.h.Fields[re.Column]
+	}
+	return handleReject(rs.policy, rs.sink, &rs.skipped, re)
+}
+
+// handleReject applies policy to re, counting it in skipped when it is skipped
+// or rejected.
+func handleReject(policy ErrorPolicy, sink RejectSink, skipped *int, re *RecordError) error {
+	switch policy {
+	case SkipInvalid:
+		*skipped++
+		return nil
+	case RejectInvalid:
+		*skipped++
+		if sink == nil {
+			return nil
+		}
+		if err := sink.Reject(re); err != nil {
+			return fmt.Errorf("reject: %v", err)
+		}
+		return nil
+	}
+	return re
+}
@@ -0,0 +1,231 @@
+package ais
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// malformedData has a bad LAT on line 3, the wrong number of fields on line 4
+// and a bad timestamp on line 5.
+const malformedData = `MMSI,BaseDateTime,LAT,LON
+1,2017-12-01T00:00:03,36.5,-76.5
+2,2017-12-01T00:00:02,bad,-76.1
+3,2017-12-01T00:00:01,36.7
+4,notatime,36.8,-76.2
+5,2017-12-01T00:00:04,36.9,-76.3
+`
+
+func TestRecordSet_SetErrorPolicy(t *testing.T) {
+	box := &Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180, LatIndex: 2, LonIndex: 3}
+	ops := map[string]func(rs *RecordSet) (*RecordSet, error){
+		"subset": func(rs *RecordSet) (*RecordSet, error) { return rs.Subset(box) },
+		"sort":   func(rs *RecordSet) (*RecordSet, error) { return rs.SortByTime() },
+		"append": func(rs *RecordSet) (*RecordSet, error) {
+			return rs.AppendField("Geohash", []string{"LAT", "LON"}, PrecisionGeohasher(DefaultGeohashPrecision))
+		},
+		"appendfields": func(rs *RecordSet) (*RecordSet, error) {
+			return rs.AppendFields(4, NewField{Name: "Geohash", Required: []string{"LAT", "LON"}, Gen: PrecisionGeohasher(DefaultGeohashPrecision)})
+		},
+		"pipeline": func(rs *RecordSet) (*RecordSet, error) {
+			return NewPipeline(context.Background(), rs).Subset(box).RecordSet(), nil
+		},
+	}
+	tests := []struct {
+		op        string
+		policy    ErrorPolicy
+		wantErr   string
+		wantMMSI  []string
+		wantLines []int
+	}{
+		{"subset", FailFast, "line 3: LAT: unable to parse bad", nil, nil},
+		{"subset", SkipInvalid, "", []string{"1", "4", "5"}, nil},
+		{"subset", RejectInvalid, "", []string{"1", "4", "5"}, []int{3, 4}},
+		{"sort", FailFast, "wrong number of fields", nil, nil},
+		{"sort", SkipInvalid, "", []string{"2", "1", "5"}, nil},
+		{"sort", RejectInvalid, "", []string{"2", "1", "5"}, []int{4, 5}},
+		{"append", FailFast, "line 3: generate: geohash: unable to parse lat", nil, nil},
+		{"append", RejectInvalid, "", []string{"1", "4", "5"}, []int{3, 4}},
+		// The stages of a Pipeline do not know the line of a Record, and
+		// reject it concurrently with the source.
+		{"appendfields", SkipInvalid, "", []string{"1", "4", "5"}, nil},
+		{"appendfields", RejectInvalid, "", []string{"1", "4", "5"}, []int{0, 4}},
+		{"pipeline", SkipInvalid, "", []string{"1", "4", "5"}, nil},
+		{"pipeline", RejectInvalid, "", []string{"1", "4", "5"}, []int{0, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op+" "+tt.policy.String(), func(t *testing.T) {
+			rs, err := NewRecordSetFromReader(strings.NewReader(malformedData))
+			if err != nil {
+				t.Fatalf("NewRecordSetFromReader() error = %v", err)
+			}
+			var rejects RejectList
+			rs.SetErrorPolicy(tt.policy, &rejects)
+			rs2, err := ops[tt.op](rs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			var mmsi []string
+			for _, rec := range readAll(t, rs2) {
+				mmsi = append(mmsi, rec[0])
+			}
+			if !reflect.DeepEqual(mmsi, tt.wantMMSI) {
+				t.Errorf("MMSI = %v, want %v", mmsi, tt.wantMMSI)
+			}
+			if rs.Skipped() != 2 {
+				t.Errorf("RecordSet.Skipped() = %d, want 2", rs.Skipped())
+			}
+			var lines []int
+			for _, re := range rejects {
+				lines = append(lines, re.Line)
+			}
+			sort.Ints(lines)
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("rejected lines = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(malformedData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	box := &Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180, LatIndex: 2, LonIndex: 3}
+	_, err = rs.Subset(box)
+	re, ok := err.(*RecordError)
+	if !ok {
+		t.Fatalf("Subset() error = %v, want a *RecordError", err)
+	}
+	want := &RecordError{
+		Line:   3,
+		Column: 2,
+		Field:  "LAT",
+		Record: Record{"2", "2017-12-01T00:00:02", "bad", "-76.1"},
+		Err:    re.Err,
+	}
+	if !reflect.DeepEqual(re, want) {
+		t.Errorf("RecordError = %+v, want %+v", re, want)
+	}
+
+	tests := []struct {
+		name string
+		e    *RecordError
+		want string
+	}{
+		{"all", &RecordError{Line: 7, Column: 2, Field: "LAT", Err: errors.New("bad")}, "line 7: LAT: bad"},
+		{"column", &RecordError{Column: 2, Err: errors.New("bad")}, "column 2: bad"},
+		{"record", &RecordError{Line: 7, Column: -1, Err: errors.New("bad")}, "line 7: bad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.Error(); got != tt.want {
+				t.Errorf("RecordError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectWriter(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(malformedData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	var buf bytes.Buffer
+	rej := NewRejectWriter(&buf, rs.Headers())
+	rs.SetErrorPolicy(RejectInvalid, rej)
+	if _, err := rs.SortByTime(); err != nil {
+		t.Fatalf("RecordSet.SortByTime() error = %v", err)
+	}
+	if err := rej.Flush(); err != nil {
+		t.Fatalf("RejectWriter.Flush() error = %v", err)
+	}
+	want := "MMSI,BaseDateTime,LAT,LON,Line,Column,Error\n" +
+		"3,2017-12-01T00:00:01,36.7,,4,,wrong number of fields\n" +
+		"4,notatime,36.8,-76.2,5,BaseDateTime,"
+	if got := buf.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("RejectWriter wrote\n%s\nwant prefix\n%s", got, want)
+	}
+}
+
+func TestRecordSet_Lines(t *testing.T) {
+	data := "# exported 2017-12-01\r\n" +
+		"MMSI,BaseDateTime,LAT,VesselName\r\n" +
+		"1,2017-12-01T00:00:01,36.5,FIRST\r\n" +
+		"\r\n" +
+		"2,2017-12-01T00:00:02,36.6,\"TWO\r\nLINES\"\r\n" +
+		"# a comment\n" +
+		"3,2017-12-01T00:00:03,bad,THIRD\n" +
+		"4,2017-12-01T00:00:04,bad,\"LAST\nLINE\""
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	var buf bytes.Buffer
+	rej := NewRejectWriter(&buf, rs.Headers())
+	rs.SetErrorPolicy(RejectInvalid, rej)
+	box := &Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180, LatIndex: 2, LonIndex: 2}
+	if _, err := rs.Subset(box); err != nil {
+		t.Fatalf("RecordSet.Subset() error = %v", err)
+	}
+	if err := rej.Flush(); err != nil {
+		t.Fatalf("RejectWriter.Flush() error = %v", err)
+	}
+	r := csv.NewReader(&buf)
+	recs, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("reading rejects error = %v", err)
+	}
+	var lines []string
+	for _, rec := range recs[1:] {
+		lines = append(lines, rec[4])
+	}
+	if want := []string{"8", "9"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("rejected lines = %v, want %v", lines, want)
+	}
+}
+
+func TestBox_MatchOutOfRange(t *testing.T) {
+	box := &Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180, LatIndex: 2, LonIndex: 7}
+	rec := Record{"1", "2017-12-01T00:00:01", "36.5", "-76.5"}
+	_, err := box.Match(&rec)
+	if re, ok := err.(*RecordError); !ok || re.Column != 7 {
+		t.Errorf("Box.Match() error = %v, want a *RecordError for column 7", err)
+	}
+}
+
+func TestWindow_Clusters(t *testing.T) {
+	win := &Window{width: time.Hour}
+	for _, s := range []string{"1,0x1", "2,0x1", "3,notahash", "4,0x2"} {
+		win.AddRecord(Record(strings.Split(s, ",")))
+	}
+
+	if cm := win.FindClusters(1); len(cm) != 2 || cm[1].Size() != 2 {
+		t.Errorf("Window.FindClusters() = %v, want 2 clusters", cm)
+	}
+	if _, err := win.Clusters(1); err == nil {
+		t.Errorf("Window.Clusters() with FailFast error = nil, want error")
+	}
+	var rejects RejectList
+	win.SetErrorPolicy(RejectInvalid, &rejects)
+	cm, err := win.Clusters(1)
+	if err != nil {
+		t.Fatalf("Window.Clusters() error = %v", err)
+	}
+	if len(cm) != 2 || win.Skipped() != 1 || len(rejects) != 1 || rejects[0].Record[0] != "3" {
+		t.Errorf("Window.Clusters() = %v with %d skipped and rejects %v", cm, win.Skipped(), rejects)
+	}
+}
@@ -0,0 +1,162 @@
+package ais
+
+import (
+	"context"
+	"os"
+)
+
+// Progress reports how far a long running operation has read through a
+// RecordSet.  It is passed to the ProgressFunc of the Context variants of the
+// RecordSet methods.
+type Progress struct {
+	Records int64 // Records read so far
+	Bytes   int64 // bytes read from the file or io.Reader, 0 when unknown
+	Size    int64 // size of the file, 0 when unknown
+}
+
+// ProgressFunc is called with the Progress of an operation after every
+// ProgressInterval Records and once more when the operation completes.  It is
+// called from the goroutine running the operation, so it should return
+// quickly.  A nil ProgressFunc is not called.
+//
+// Bytes counts the data read from a file opened with OpenRecordSet or from the
+// io.Reader of NewRecordSetFromReader before it is decompressed, so Bytes and
+// Size can be compared to estimate the time remaining.  Bytes are read ahead
+// of the Records in large blocks.  Zip archives, files in a registered Format and RecordSets
+// created in memory report 0 Bytes.
+type ProgressFunc func(Progress)
+
+// ProgressInterval is the number of Records read between calls to a
+// ProgressFunc.
+var ProgressInterval int64 = 100000
+
+// watch checks the context of a Context method for cancellation before each
+// Record is read and counts the Records to report progress.
+type watch struct {
+	ctx     context.Context
+	fn      ProgressFunc
+	records int64
+}
+
+// read returns the fields of the next line of rs, or the error of the context
+// when it is done, and calls the ProgressFunc every ProgressInterval Records.
+func (w *watch) read(rs *RecordSet) ([]string, error) {
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
+	rs.watch = nil
+	rec, err := rs.readRaw()
+	rs.watch = w
+	if err != nil {
+		return nil, err
+	}
+	w.records++
+	if w.fn != nil && w.records%ProgressInterval == 0 {
+		w.fn(rs.progress(w.records))
+	}
+	return rec, nil
+}
+
+// progress returns the Progress of rs after n Records.
+func (rs *RecordSet) progress(n int64) Progress {
+	p := Progress{Records: n, Size: rs.size}
+	if rs.pos != nil {
+		p.Bytes = rs.pos()
+	}
+	return p
+}
+
+// withContext runs op with the cancellation of ctx and the progress reporting
+// of fn.  When op fails because ctx is done the error of ctx is returned so
+// that clients can test for context.Canceled or context.DeadlineExceeded.
+func (rs *RecordSet) withContext(ctx context.Context, fn ProgressFunc, op func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w := &watch{ctx: ctx, fn: fn}
+	rs.watch = w
+	err := op()
+	rs.watch = nil
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if fn != nil && err == nil {
+		fn(rs.progress(w.records))
+	}
+	return err
+}
+
+// SubsetContext is Subset with cancellation by ctx and progress reported to fn.
+// It returns the error of ctx when ctx is done before the Subset completes.
+func (rs *RecordSet) SubsetContext(ctx context.Context, m Matching, fn ProgressFunc) (*RecordSet, error) {
+	var rs2 *RecordSet
+	err := rs.withContext(ctx, fn, func() error {
+		var err error
+		rs2, err = rs.Subset(m)
+		return err
+	})
+	if err != nil && err != ErrEmptySet {
+		return nil, err
+	}
+	return rs2, err
+}
+
+// SortByTimeContext is SortByTime with cancellation by ctx and progress
+// reported to fn while the Records are read.  The in-memory sort that follows
+// is not interrupted.
+func (rs *RecordSet) SortByTimeContext(ctx context.Context, fn ProgressFunc) (*RecordSet, error) {
+	var rs2 *RecordSet
+	err := rs.withContext(ctx, fn, func() error {
+		var err error
+		rs2, err = rs.SortByTime()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs2, nil
+}
+
+// UniqueVesselsContext is UniqueVessels with cancellation by ctx and progress
+// reported to fn.
+func (rs *RecordSet) UniqueVesselsContext(ctx context.Context, fn ProgressFunc) (VesselSet, error) {
+	var vs VesselSet
+	err := rs.withContext(ctx, fn, func() error {
+		var err error
+		vs, err = rs.UniqueVessels()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// AppendFieldContext is AppendField with cancellation by ctx and progress
+// reported to fn.
+func (rs *RecordSet) AppendFieldContext(ctx context.Context, newField string, requiredHeaders []string, gen Generator, fn ProgressFunc) (*RecordSet, error) {
+	var rs2 *RecordSet
+	err := rs.withContext(ctx, fn, func() error {
+		var err error
+		rs2, err = rs.AppendField(newField, requiredHeaders, gen)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs2, nil
+}
+
+// SaveContext is Save with cancellation by ctx and progress reported to fn.
+// When ctx is done before the file is complete the partial file is removed.
+func (rs *RecordSet) SaveContext(ctx context.Context, name string, fn ProgressFunc) error {
+	created := false
+	err := rs.withContext(ctx, fn, func() error {
+		created = true
+		return rs.Save(name)
+	})
+	if err != nil && created && ctx.Err() != nil {
+		os.Remove(name)
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package ais
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordSet_Context(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	box := &Box{MinLat: 34, MaxLat: 38, MinLon: -77, MaxLon: -75, LatIndex: 2, LonIndex: 3}
+	tests := []struct {
+		name string
+		op   func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error
+	}{
+		{"subset", func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error {
+			_, err := rs.SubsetContext(ctx, box, fn)
+			return err
+		}},
+		{"sort", func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error {
+			_, err := rs.SortByTimeContext(ctx, fn)
+			return err
+		}},
+		{"vessels", func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error {
+			_, err := rs.UniqueVesselsContext(ctx, fn)
+			return err
+		}},
+		{"append", func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error {
+			_, err := rs.AppendFieldContext(ctx, "Geohash", []string{"LAT", "LON"}, PrecisionGeohasher(DefaultGeohashPrecision), fn)
+			return err
+		}},
+		{"save", func(ctx context.Context, rs *RecordSet, fn ProgressFunc) error {
+			return rs.SaveContext(ctx, filepath.Join(dir, "ten.csv"), fn)
+		}},
+	}
+
+	defer func(n int64) { ProgressInterval = n }(ProgressInterval)
+	ProgressInterval = 4
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := OpenRecordSet("testdata/ten.csv")
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			var reports []Progress
+			err = tt.op(context.Background(), rs, func(p Progress) {
+				reports = append(reports, p)
+			})
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			// Reports after 4 and 8 Records and at the end.
+			if len(reports) != 3 {
+				t.Fatalf("reported %d times, want 3: %v", len(reports), reports)
+			}
+			last := reports[len(reports)-1]
+			if reports[0].Records != 4 || reports[1].Records != 8 || last.Records != 10 {
+				t.Errorf("Progress.Records = %v, want 4, 8 and 10", reports)
+			}
+			info, _ := os.Stat("testdata/ten.csv")
+			if last.Size != info.Size() || last.Bytes != info.Size() {
+				t.Errorf("last Progress = %+v, want Bytes and Size %d", last, info.Size())
+			}
+		})
+	}
+
+	// Canceling the context stops each operation with the error of the context.
+	for _, tt := range tests {
+		t.Run(tt.name+" canceled", func(t *testing.T) {
+			rs, err := OpenRecordSet("testdata/ten.csv")
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err = tt.op(ctx, rs, func(p Progress) {
+				if p.Records == 4 {
+					cancel()
+				}
+			})
+			if err != context.Canceled {
+				t.Errorf("error = %v, want %v", err, context.Canceled)
+			}
+			if rec, err := rs.Read(); err != nil || (*rec)[0] != "367605855" {
+				t.Errorf("Read() after cancel = %v, %v, want the record after the fourth", rec, err)
+			}
+		})
+	}
+}
+
+func TestRecordSet_SaveContextRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(n int64) { ProgressInterval = n }(ProgressInterval)
+	ProgressInterval = 2
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	name := filepath.Join(dir, "partial.csv")
+	if err := rs.SaveContext(ctx, name, func(Progress) { cancel() }); err != context.Canceled {
+		t.Errorf("RecordSet.SaveContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("RecordSet.SaveContext() left the partial file: %v", err)
+	}
+
+	// A context that is done before the save starts leaves an existing file.
+	if err := ioutil.WriteFile(name, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.SaveContext(ctx, name, nil); err != context.Canceled {
+		t.Errorf("RecordSet.SaveContext() error = %v, want %v", err, context.Canceled)
+	}
+	if b, err := ioutil.ReadFile(name); err != nil || string(b) != "keep" {
+		t.Errorf("RecordSet.SaveContext() changed an existing file: %q, %v", b, err)
+	}
+}
+
+func TestNewRecordSetFromReader_Progress(t *testing.T) {
+	data := "MMSI,BaseDateTime\n1,2017-12-01T00:00:01\n2,2017-12-01T00:00:02\n"
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	var last Progress
+	if _, err := rs.UniqueVesselsContext(context.Background(), func(p Progress) { last = p }); err != nil {
+		t.Fatalf("RecordSet.UniqueVesselsContext() error = %v", err)
+	}
+	want := Progress{Records: 2, Bytes: int64(len(data))}
+	if last != want {
+		t.Errorf("Progress = %+v, want %+v", last, want)
+	}
+}
@@ -0,0 +1,344 @@
+package ais
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// StaticFields are the Headers of the static and voyage related data that
+// identify a vessel rather than describe its position.
+var StaticFields = []string{"VesselName", "IMO", "CallSign", "VesselType", "Length", "Width", "Draft"}
+
+// ResolvePolicy selects the value a VesselRegistry reports for a static field
+// when the reports for an MMSI disagree.
+type ResolvePolicy int
+
+// ResolveMostFrequent picks the value seen in the most reports and breaks ties
+// with the most recent report.  ResolveMostRecent picks the value in the most
+// recent report.  Blank values are never chosen over a non-blank value.
+const (
+	ResolveMostFrequent ResolvePolicy = iota
+	ResolveMostRecent
+)
+
+// VesselIdentity is the full set of static data for a vessel.  The embedded
+// Vessel can be used as a key into a VesselSet.
+type VesselIdentity struct {
+	Vessel
+	IMO        string
+	CallSign   string
+	VesselType string
+	Length     string
+	Width      string
+	Draft      string
+}
+
+// IMONumber returns the IMO number of the vessel and true, or false when the
+// vessel never reported a valid IMO number.
+func (vi VesselIdentity) IMONumber() (int, bool) {
+	n, err := ParseIMO(vi.IMO)
+	return n, err == nil
+}
+
+// VesselChange records a change in a static field for a vessel such as a
+// rename or a new draft at the start of a voyage.
+type VesselChange struct {
+	Field    string
+	From, To string
+	Time     time.Time
+}
+
+// fieldValue counts the reports of a single value for a static field.
+type fieldValue struct {
+	count int
+	last  time.Time
+	order int // order of the most recent report, used when times are equal
+}
+
+// VesselHistory holds every value reported for the static fields of one MMSI
+// and the changes between them in the order the Records were read.
+type VesselHistory struct {
+	MMSI    string
+	Reports int
+	Changes []VesselChange
+
+	values  map[string]map[string]*fieldValue // field -> value -> count
+	current map[string]string                 // field -> most recent non-blank value
+}
+
+// Values returns the number of reports for each distinct non-blank value of the
+// field.
+func (vh *VesselHistory) Values(field string) map[string]int {
+	m := make(map[string]int)
+	for val, fv := range vh.values[field] {
+		m[val] = fv.count
+	}
+	return m
+}
+
+// resolve returns the value of field chosen by policy.
+func (vh *VesselHistory) resolve(field string, policy ResolvePolicy) string {
+	var best string
+	var bestFV *fieldValue
+	for val, fv := range vh.values[field] {
+		if bestFV == nil || better(fv, bestFV, policy) {
+			best, bestFV = val, fv
+		}
+	}
+	return best
+}
+
+// better reports whether a should be chosen over b.
+func better(a, b *fieldValue, policy ResolvePolicy) bool {
+	if policy == ResolveMostFrequent && a.count != b.count {
+		return a.count > b.count
+	}
+	if !a.last.Equal(b.last) {
+		return a.last.After(b.last)
+	}
+	return a.order > b.order
+}
+
+// VesselRegistry holds the static data of every vessel in a RecordSet keyed by
+// MMSI.  Create a VesselRegistry with NewVesselRegistry.
+type VesselRegistry struct {
+	Policy  ResolvePolicy
+	vessels map[string]*VesselHistory
+	reports int
+}
+
+// NewVesselRegistry reads every Record in rs and returns a *VesselRegistry of the
+// static fields found in the Headers.  The Headers must contain MMSI.  When they
+// contain BaseDateTime the times of the reports are used to order changes and
+// resolve conflicts by recency.  IMO values are normalized to the form
+// IMO9739666 and values that fail ParseIMO are ignored.
+func NewVesselRegistry(rs *RecordSet) (*VesselRegistry, error) {
+	h := rs.Headers()
+	mmsiIndex, ok := h.Contains("MMSI")
+	if !ok {
+		return nil, fmt.Errorf("vessel registry: recordset does not contain MMSI header")
+	}
+	timeIndex, okTime := h.Contains("BaseDateTime")
+
+	fields := make(map[string]int)
+	for _, f := range StaticFields {
+		if i, ok := h.Contains(f); ok {
+			fields[f] = i
+		}
+	}
+
+	vr := &VesselRegistry{vessels: make(map[string]*VesselHistory)}
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("vessel registry: read error on csv file: %v", err)
+		}
+
+		mmsi, ok := rec.Value(mmsiIndex)
+		if !ok {
+			continue
+		}
+		var t time.Time
+		if okTime {
+			t, _ = h.ParseTime(*rec, timeIndex)
+		}
+		vr.add(mmsi, t, rec, fields)
+	}
+	return vr, nil
+}
+
+// add records the static fields of a single report.
+func (vr *VesselRegistry) add(mmsi string, t time.Time, rec *Record, fields map[string]int) {
+	vr.reports++
+	vh, ok := vr.vessels[mmsi]
+	if !ok {
+		vh = &VesselHistory{
+			MMSI:    mmsi,
+			values:  make(map[string]map[string]*fieldValue),
+			current: make(map[string]string),
+		}
+		vr.vessels[mmsi] = vh
+	}
+	vh.Reports++
+
+	for _, f := range StaticFields {
+		i, ok := fields[f]
+		if !ok {
+			continue
+		}
+		val, ok := rec.Value(i)
+		if !ok || isBlank(val) {
+			continue
+		}
+		if f == "IMO" {
+			// Invalid IMO numbers are treated as blank so they are never joined
+			// onto other Records.
+			if val, ok = NormalizeIMO(val); !ok {
+				continue
+			}
+		}
+		if vh.values[f] == nil {
+			vh.values[f] = make(map[string]*fieldValue)
+		}
+		fv, ok := vh.values[f][val]
+		if !ok {
+			fv = new(fieldValue)
+			vh.values[f][val] = fv
+		}
+		fv.count++
+		if !t.Before(fv.last) {
+			fv.last = t
+		}
+		fv.order = vr.reports
+
+		if prev, ok := vh.current[f]; ok && prev != val {
+			vh.Changes = append(vh.Changes, VesselChange{Field: f, From: prev, To: val, Time: t})
+		}
+		vh.current[f] = val
+	}
+}
+
+// Len returns the number of vessels in the registry.
+func (vr *VesselRegistry) Len() int { return len(vr.vessels) }
+
+// History returns the full history of static data for the MMSI.
+func (vr *VesselRegistry) History(mmsi string) (*VesselHistory, bool) {
+	vh, ok := vr.vessels[mmsi]
+	return vh, ok
+}
+
+// Vessel returns the VesselIdentity for the MMSI with each static field
+// resolved according to the registry Policy.
+func (vr *VesselRegistry) Vessel(mmsi string) (VesselIdentity, bool) {
+	vh, ok := vr.vessels[mmsi]
+	if !ok {
+		return VesselIdentity{}, false
+	}
+	return VesselIdentity{
+		Vessel: Vessel{
+			MMSI:       mmsi,
+			VesselName: vh.resolve("VesselName", vr.Policy),
+		},
+		IMO:        vh.resolve("IMO", vr.Policy),
+		CallSign:   vh.resolve("CallSign", vr.Policy),
+		VesselType: vh.resolve("VesselType", vr.Policy),
+		Length:     vh.resolve("Length", vr.Policy),
+		Width:      vh.resolve("Width", vr.Policy),
+		Draft:      vh.resolve("Draft", vr.Policy),
+	}, true
+}
+
+// Vessels returns every resolved VesselIdentity in the registry sorted by MMSI.
+func (vr *VesselRegistry) Vessels() []VesselIdentity {
+	vessels := make([]VesselIdentity, 0, len(vr.vessels))
+	for mmsi := range vr.vessels {
+		v, _ := vr.Vessel(mmsi)
+		vessels = append(vessels, v)
+	}
+	sort.Slice(vessels, func(i, j int) bool { return vessels[i].MMSI < vessels[j].MMSI })
+	return vessels
+}
+
+// Join returns a pointer to a new RecordSet where every blank static field in a
+// Record of rs is filled with the value resolved for its MMSI.  An IMO that fails
+// ParseIMO is treated as blank.  A field is left unchanged when the registry has
+// no value for it, and fields that are not blank are never changed.  The Headers of rs must contain MMSI.
+func (vr *VesselRegistry) Join(rs *RecordSet) (*RecordSet, error) {
+	h := rs.Headers()
+	mmsiIndex, ok := h.Contains("MMSI")
+	if !ok {
+		return nil, fmt.Errorf("vessel registry join: recordset does not contain MMSI header")
+	}
+	fields := make(map[string]int)
+	for _, f := range StaticFields {
+		if i, ok := h.Contains(f); ok {
+			fields[f] = i
+		}
+	}
+
+	rs2 := NewRecordSet()
+	rs2.SetHeaders(h)
+
+	written := 0
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("vessel registry join: read error on csv file: %v", err)
+		}
+
+		if mmsi, ok := rec.Value(mmsiIndex); ok {
+			if vh, ok := vr.vessels[mmsi]; ok {
+				for f, i := range fields {
+					val, ok := rec.Value(i)
+					if !ok {
+						continue
+					}
+					if isBlank(val) || f == "IMO" && !validIMO(val) {
+						if v := vh.resolve(f, vr.Policy); v != "" {
+							(*rec)[i] = v
+						}
+					}
+				}
+			}
+		}
+
+		if err := rs2.Write(*rec); err != nil {
+			return nil, fmt.Errorf("vessel registry join: csv write error: %v", err)
+		}
+		written++
+		if written%flushThreshold == 0 {
+			if err := rs2.Flush(); err != nil {
+				return nil, fmt.Errorf("vessel registry join: csv flush error: %v", err)
+			}
+		}
+	}
+	if err := rs2.Flush(); err != nil {
+		return nil, fmt.Errorf("vessel registry join: csv flush error: %v", err)
+	}
+	return rs2, nil
+}
+
+// Save writes the resolved VesselIdentities to a csv file with the headers MMSI, the
+// StaticFields, Reports and Changes.
+func (vr *VesselRegistry) Save(filename string) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("vessel registry save: %v", err)
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	w.Write(append(append([]string{"MMSI"}, StaticFields...), "Reports", "Changes"))
+	for _, v := range vr.Vessels() {
+		vh := vr.vessels[v.MMSI]
+		w.Write([]string{v.MMSI, v.VesselName, v.IMO, v.CallSign, v.VesselType,
+			v.Length, v.Width, v.Draft, strconv.Itoa(vh.Reports), strconv.Itoa(len(vh.Changes))})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("vessel registry save: flush error: %v", err)
+	}
+	return nil
+}
+
+// isBlank returns true for field values that carry no data.  MarineCadastre
+// files sometimes pad empty fields with spaces.
+func isBlank(s string) bool {
+	for _, c := range s {
+		if c != ' ' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package ais
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRegistryString has a vessel that is renamed, changes draft and sometimes
+// omits its static data, and a vessel whose IMO is only reported once.
+var testRegistryString = `MMSI,BaseDateTime,LAT,LON,VesselName,IMO,CallSign,VesselType,Length,Width,Draft
+111111111,2017-12-01T00:00:00,36.0,-76.0,OLD NAME,IMO9739666,VRPJ6,1004,337,48,10.1
+111111111,2017-12-01T00:01:00,36.0,-76.0,OLD NAME,,,,,,
+111111111,2017-12-01T00:02:00,36.0,-76.0,NEW NAME,IMO9739666,VRPJ6,1004,337,48,12.5
+111111111,2017-12-01T00:03:00,36.0,-76.0,,,,,,,
+222222222,2017-12-01T00:00:30,37.0,-75.0,SECOND,,WDC8925,1001,23.69,6.76,
+222222222,2017-12-01T00:01:30,37.0,-75.0,SECOND,IMO7933464,,,,,
+`
+
+func TestNewVesselRegistry(t *testing.T) {
+	rs := newTestRecordSet(t, testRegistryString)
+	vr, err := NewVesselRegistry(rs)
+	if err != nil {
+		t.Fatalf("NewVesselRegistry() error = %v", err)
+	}
+	if vr.Len() != 2 {
+		t.Errorf("VesselRegistry.Len() = %d, want 2", vr.Len())
+	}
+
+	vh, ok := vr.History("111111111")
+	if !ok {
+		t.Fatal("VesselRegistry.History() ok = false, want true")
+	}
+	wantChanges := []VesselChange{
+		{Field: "VesselName", From: "OLD NAME", To: "NEW NAME", Time: getTime("2017-12-01T00:02:00")},
+		{Field: "Draft", From: "10.1", To: "12.5", Time: getTime("2017-12-01T00:02:00")},
+	}
+	if !reflect.DeepEqual(vh.Changes, wantChanges) {
+		t.Errorf("VesselHistory.Changes = %+v, want %+v", vh.Changes, wantChanges)
+	}
+	if got := vh.Values("VesselName"); !reflect.DeepEqual(got, map[string]int{"OLD NAME": 2, "NEW NAME": 1}) {
+		t.Errorf("VesselHistory.Values() = %v", got)
+	}
+
+	_, err = NewVesselRegistry(&RecordSet{h: badHeaders2})
+	if err == nil {
+		t.Errorf("NewVesselRegistry() without MMSI error = nil, want error")
+	}
+}
+
+func TestVesselRegistry_Vessel(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ResolvePolicy
+		mmsi   string
+		want   VesselIdentity
+		wantOk bool
+	}{
+		{
+			name:   "most frequent",
+			policy: ResolveMostFrequent,
+			mmsi:   "111111111",
+			// Draft is a tie and is resolved by the most recent report.
+			want:   VesselIdentity{Vessel{"111111111", "OLD NAME"}, "IMO9739666", "VRPJ6", "1004", "337", "48", "12.5"},
+			wantOk: true,
+		},
+		{
+			name:   "most recent",
+			policy: ResolveMostRecent,
+			mmsi:   "111111111",
+			want:   VesselIdentity{Vessel{"111111111", "NEW NAME"}, "IMO9739666", "VRPJ6", "1004", "337", "48", "12.5"},
+			wantOk: true,
+		},
+		{
+			name:   "fields from different reports",
+			policy: ResolveMostFrequent,
+			mmsi:   "222222222",
+			want:   VesselIdentity{Vessel{"222222222", "SECOND"}, "IMO7933464", "WDC8925", "1001", "23.69", "6.76", ""},
+			wantOk: true,
+		},
+		{
+			name:   "unknown vessel",
+			mmsi:   "333333333",
+			want:   VesselIdentity{},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vr, _ := NewVesselRegistry(newTestRecordSet(t, testRegistryString))
+			vr.Policy = tt.policy
+			got, ok := vr.Vessel(tt.mmsi)
+			if ok != tt.wantOk {
+				t.Errorf("VesselRegistry.Vessel() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("VesselRegistry.Vessel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVesselRegistry_Join(t *testing.T) {
+	vr, _ := NewVesselRegistry(newTestRecordSet(t, testRegistryString))
+	vr.Policy = ResolveMostRecent
+
+	rs2, err := vr.Join(newTestRecordSet(t, testRegistryString))
+	if err != nil {
+		t.Fatalf("VesselRegistry.Join() error = %v", err)
+	}
+	want := []string{
+		"111111111,2017-12-01T00:00:00,36.0,-76.0,OLD NAME,IMO9739666,VRPJ6,1004,337,48,10.1",
+		"111111111,2017-12-01T00:01:00,36.0,-76.0,OLD NAME,IMO9739666,VRPJ6,1004,337,48,12.5",
+		"111111111,2017-12-01T00:02:00,36.0,-76.0,NEW NAME,IMO9739666,VRPJ6,1004,337,48,12.5",
+		"111111111,2017-12-01T00:03:00,36.0,-76.0,NEW NAME,IMO9739666,VRPJ6,1004,337,48,12.5",
+		"222222222,2017-12-01T00:00:30,37.0,-75.0,SECOND,IMO7933464,WDC8925,1001,23.69,6.76,",
+		"222222222,2017-12-01T00:01:30,37.0,-75.0,SECOND,IMO7933464,WDC8925,1001,23.69,6.76,",
+	}
+	var got []string
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		got = append(got, strings.Join(*rec, ","))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VesselRegistry.Join() = \n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := vr.Join(&RecordSet{h: badHeaders2}); err == nil {
+		t.Errorf("VesselRegistry.Join() without MMSI error = nil, want error")
+	}
+}
+
+func TestVesselRegistry_Save(t *testing.T) {
+	vr, _ := NewVesselRegistry(newTestRecordSet(t, testRegistryString))
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "vessels.csv")
+
+	if err := vr.Save(filename); err != nil {
+		t.Fatalf("VesselRegistry.Save() error = %v", err)
+	}
+	b, _ := ioutil.ReadFile(filename)
+	want := `MMSI,VesselName,IMO,CallSign,VesselType,Length,Width,Draft,Reports,Changes
+111111111,OLD NAME,IMO9739666,VRPJ6,1004,337,48,12.5,4,2
+222222222,SECOND,IMO7933464,WDC8925,1001,23.69,6.76,,2,0
+`
+	if string(b) != want {
+		t.Errorf("VesselRegistry.Save() wrote \n%s\nwant\n%s", b, want)
+	}
+}
+
+func TestVesselRegistry_IMO(t *testing.T) {
+	data := `MMSI,IMO
+111111111,IMO9739667
+111111111,9739666
+111111111,IMO0000000
+222222222,IMO1234568
+333333333,  
+`
+	vr, _ := NewVesselRegistry(newTestRecordSet(t, data))
+
+	v, _ := vr.Vessel("111111111")
+	if n, ok := v.IMONumber(); !ok || n != 9739666 || v.IMO != "IMO9739666" {
+		t.Errorf("VesselRegistry.Vessel() IMO = %q, IMONumber() = %d, %v", v.IMO, n, ok)
+	}
+	v, _ = vr.Vessel("222222222")
+	if n, ok := v.IMONumber(); ok || v.IMO != "" {
+		t.Errorf("VesselRegistry.Vessel() IMO = %q, IMONumber() = %d, %v", v.IMO, n, ok)
+	}
+
+	rs2, err := vr.Join(newTestRecordSet(t, data))
+	if err != nil {
+		t.Fatalf("VesselRegistry.Join() error = %v", err)
+	}
+	var got []string
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		got = append(got, (*rec)[1])
+	}
+	// Vessels without a valid IMO in the registry keep the value they had.
+	want := []string{"IMO9739666", "9739666", "IMO9739666", "IMO1234568", "  "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VesselRegistry.Join() IMO = %v, want %v", got, want)
+	}
+}
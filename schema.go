@@ -0,0 +1,154 @@
+package ais
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schema maps the canonical MarineCadastre field names used throughout the
+// package, such as MMSI, BaseDateTime, LAT and LON, to the column names of a
+// different data source.  When a RecordSet has a Schema every call to
+// Headers.Contains with a canonical name finds the source column, so functions
+// like SortByTime, NewWindow and Dedup work on the source data without renaming
+// its columns.  TimeLayout is the time.Parse layout of the column mapped to
+// BaseDateTime.  An empty TimeLayout detects the format of each timestamp with
+// ParseTimestamp.
+type Schema struct {
+	Name       string
+	Columns    map[string]string // canonical field -> source column
+	TimeLayout string
+}
+
+// MarineCadastreSchema describes the csv files published by MarineCadastre.gov
+// from 2015 onward.  These files use the canonical names.
+var MarineCadastreSchema = Schema{
+	Name:       "MarineCadastre",
+	Columns:    map[string]string{},
+	TimeLayout: TimeLayout,
+}
+
+// NOAALegacySchema describes point exports of the 2009 through 2014
+// MarineCadastre.gov geodatabases where position is held in X and Y columns and
+// timestamps use slashes and a space.
+var NOAALegacySchema = Schema{
+	Name: "NOAALegacy",
+	Columns: map[string]string{
+		"LAT": "Y",
+		"LON": "X",
+	},
+	TimeLayout: "2006/01/02 15:04:05",
+}
+
+// DMASchema describes the csv files published by the Danish Maritime Authority.
+// The first column of the header line of these files is "# Timestamp" and
+// OpenRecordSet reads it as the header line rather than a comment.
+var DMASchema = Schema{
+	Name: "DMA",
+	Columns: map[string]string{
+		"BaseDateTime": "# Timestamp",
+		"LAT":          "Latitude",
+		"LON":          "Longitude",
+		"VesselName":   "Name",
+		"CallSign":     "Callsign",
+		"VesselType":   "Ship type",
+		"Status":       "Navigational status",
+		"Draft":        "Draught",
+		"Cargo":        "Cargo type",
+	},
+	TimeLayout: "02/01/2006 15:04:05",
+}
+
+// SpireSchema describes the lower case snake_case exports common to commercial
+// satellite AIS providers.
+var SpireSchema = Schema{
+	Name: "Spire",
+	Columns: map[string]string{
+		"MMSI":         "mmsi",
+		"BaseDateTime": "timestamp",
+		"LAT":          "latitude",
+		"LON":          "longitude",
+		"SOG":          "speed",
+		"COG":          "course",
+		"Heading":      "heading",
+		"VesselName":   "name",
+		"IMO":          "imo",
+		"CallSign":     "call_sign",
+		"VesselType":   "ship_type",
+		"Status":       "nav_status",
+		"Length":       "length",
+		"Width":        "width",
+		"Draft":        "draught",
+	},
+	TimeLayout: time.RFC3339,
+}
+
+// Schemas are the known Schemas tried in order by InferSchema.
+var Schemas = []Schema{MarineCadastreSchema, DMASchema, SpireSchema, NOAALegacySchema}
+
+// schemaRequired are the canonical fields a Schema must resolve for InferSchema
+// to select it.
+var schemaRequired = []string{"MMSI", "BaseDateTime", "LAT", "LON"}
+
+// Column returns the source column name for the canonical field.  Fields that
+// are not mapped use the canonical name.
+func (s Schema) Column(field string) string {
+	if col, ok := s.Columns[field]; ok {
+		return col
+	}
+	return field
+}
+
+// resolves returns true when every required canonical field can be found in
+// fields with the Schema.
+func (s Schema) resolves(fields []string) bool {
+	for _, f := range schemaRequired {
+		col := s.Column(f)
+		found := false
+		for _, field := range fields {
+			if field == col {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// InferSchema returns the first of the known Schemas that resolves MMSI,
+// BaseDateTime, LAT and LON in fields.  The bool is false when none of them do.
+func InferSchema(fields []string) (Schema, bool) {
+	for _, s := range Schemas {
+		if s.resolves(fields) {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+// SetSchema assigns the Schema to the Headers of the RecordSet.  It returns an
+// error if the Headers do not contain the columns the Schema maps MMSI,
+// BaseDateTime, LAT and LON to.
+func (rs *RecordSet) SetSchema(s Schema) error {
+	if !s.resolves(rs.h.Fields) {
+		var cols []string
+		for _, f := range schemaRequired {
+			cols = append(cols, s.Column(f))
+		}
+		return fmt.Errorf("set schema: %s schema requires headers %s", s.Name, strings.Join(cols, ", "))
+	}
+	rs.h.schema = &s
+	return nil
+}
+
+// Schema returns the Schema of the Headers and true, or false when no Schema
+// has been assigned or inferred.
+func (h Headers) Schema() (Schema, bool) {
+	if h.schema == nil {
+		return Schema{}, false
+	}
+	return *h.schema, true
+}
@@ -0,0 +1,128 @@
+package ais
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInferSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+		wantOk bool
+	}{
+		{"marinecadastre", defaultHeadersString, "MarineCadastre", true},
+		{"dma", "# Timestamp,Type of mobile,MMSI,Latitude,Longitude,SOG", "DMA", true},
+		{"spire", "mmsi,timestamp,latitude,longitude,speed", "Spire", true},
+		{"noaa legacy", "OBJECTID,MMSI,BaseDateTime,Y,X,SOG", "NOAALegacy", true},
+		{"unknown", "ID,Time,Lat,Long", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := InferSchema(strings.Split(tt.fields, ","))
+			if ok != tt.wantOk {
+				t.Fatalf("InferSchema() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got.Name != tt.want {
+				t.Errorf("InferSchema() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenRecordSet_DMA(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/dma.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	h := rs.Headers()
+	if s, ok := h.Schema(); !ok || s.Name != "DMA" {
+		t.Fatalf("Headers.Schema() = %v, %v, want DMA", s.Name, ok)
+	}
+	idxMap, ok := h.ContainsMulti("MMSI", "BaseDateTime", "LAT", "LON", "VesselName")
+	if !ok {
+		t.Fatalf("Headers.ContainsMulti() ok = false")
+	}
+	if idxMap["BaseDateTime"].Idx != 0 || idxMap["LAT"].Idx != 3 || idxMap["VesselName"].Idx != 12 {
+		t.Errorf("Headers.ContainsMulti() = %v", idxMap)
+	}
+
+	rs2, err := rs.SortByTime()
+	if err != nil {
+		t.Fatalf("RecordSet.SortByTime() error = %v", err)
+	}
+	win, err := NewWindow(rs2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewWindow() error = %v", err)
+	}
+	if want := time.Date(2017, 12, 1, 0, 0, 1, 0, time.UTC); !win.Left().Equal(want) {
+		t.Errorf("Window.Left() = %v, want %v", win.Left(), want)
+	}
+	var mmsi []string
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		if in, err := win.RecordInWindow(rec); err != nil || !in {
+			t.Errorf("Window.RecordInWindow(%v) = %v, %v", (*rec)[0], in, err)
+		}
+		mmsi = append(mmsi, (*rec)[idxMap["MMSI"].Idx])
+	}
+	if got := strings.Join(mmsi, ","); got != "211234560,2190068,219000606" {
+		t.Errorf("SortByTime() order = %s", got)
+	}
+}
+
+func TestOpenRecordSet_Spire(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/spire.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	h := rs.Headers()
+	timeIndex, ok := h.Contains("BaseDateTime")
+	if !ok {
+		t.Fatalf("Headers.Contains(BaseDateTime) ok = false")
+	}
+	want := []time.Time{
+		time.Date(2017, 12, 1, 0, 0, 1, 0, time.UTC),
+		time.Date(2017, 12, 1, 0, 0, 2, 0, time.UTC),
+	}
+	for i := 0; ; i++ {
+		rec, err := rs.Read()
+		if err != nil {
+			break
+		}
+		got, err := h.ParseTime(*rec, timeIndex)
+		if err != nil {
+			t.Fatalf("Headers.ParseTime() error = %v", err)
+		}
+		if !got.Equal(want[i]) {
+			t.Errorf("Headers.ParseTime() = %v, want %v", got, want[i])
+		}
+	}
+}
+
+func TestRecordSet_SetSchema(t *testing.T) {
+	rs := NewRecordSet()
+	rs.SetHeaders(Headers{Fields: strings.Split("OBJECTID,MMSI,BaseDateTime,Y,X", ",")})
+	if err := rs.SetSchema(DMASchema); err == nil {
+		t.Errorf("RecordSet.SetSchema(DMASchema) error = nil, want error")
+	}
+	if err := rs.SetSchema(NOAALegacySchema); err != nil {
+		t.Fatalf("RecordSet.SetSchema(NOAALegacySchema) error = %v", err)
+	}
+	if i, ok := rs.Headers().Contains("LON"); !ok || i != 4 {
+		t.Errorf("Headers.Contains(LON) = %d, %v, want 4, true", i, ok)
+	}
+	got, err := rs.Headers().ParseTime(Record{"1", "2", "2014/01/02 03:04:05"}, 2)
+	if err != nil || !got.Equal(time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Headers.ParseTime() = %v, %v", got, err)
+	}
+}
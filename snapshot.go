@@ -0,0 +1,206 @@
+package ais
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SnapshotMode selects the Records of a Window that FindClusters and Clusters
+// group into Clusters.  A vessel may report many times within a Window, and
+// every pair of its reports with those of a nearby vessel becomes an
+// interaction.  The snapshot modes reduce the Window to one report per MMSI so
+// that each pair of vessels is compared once.
+type SnapshotMode int
+
+// The SnapshotMode values.
+const (
+	// AllReports uses every Record in the Window.  It is the default.
+	AllReports SnapshotMode = iota
+
+	// LatestReports keeps only the latest Record of each MMSI in the Window.
+	// A Record that is older than the one held for its MMSI is not added.
+	LatestReports
+
+	// InterpolatedReports keeps every Record and uses the Snapshot of the
+	// Window at the middle of the Window.
+	InterpolatedReports
+)
+
+// String implements the Stringer interface for SnapshotMode.
+func (m SnapshotMode) String() string {
+	switch m {
+	case AllReports:
+		return "all"
+	case LatestReports:
+		return "latest"
+	case InterpolatedReports:
+		return "interpolated"
+	}
+	return "SnapshotMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// SetSnapshot sets the SnapshotMode of the Window.  LatestReports requires an
+// MMSI field and InterpolatedReports also requires LAT and LON in the Headers
+// of the RecordSet passed to NewWindow.  Records already in the Window are
+// reduced to the latest of each MMSI when the mode is set to LatestReports.
+func (win *Window) SetSnapshot(mode SnapshotMode) error {
+	switch mode {
+	case AllReports:
+	case LatestReports:
+		if win.mmsiIndex < 0 {
+			return fmt.Errorf("window: snapshot %v: headers does not contain MMSI", mode)
+		}
+	case InterpolatedReports:
+		if win.mmsiIndex < 0 || win.latIndex < 0 || win.lonIndex < 0 {
+			return fmt.Errorf("window: snapshot %v: headers must contain MMSI, LAT and LON", mode)
+		}
+	default:
+		return fmt.Errorf("window: unknown %v", mode)
+	}
+	win.mode = mode
+	entries := win.entries
+	win.clear()
+	for _, e := range entries {
+		win.AddRecordAt(*e.rec, e.t)
+	}
+	return nil
+}
+
+// Snapshot returns one Record for each vessel in the Window that gives its
+// position at time t.  For a vessel with a report at or before t and another
+// after t, LAT and LON are interpolated linearly in time between the last
+// report at or before t and the first report after it, BaseDateTime is set to
+// t and the other fields, including any geohash, are copied from the nearer of
+// the two reports.  A vessel with no report after t is given by its last
+// report, and a vessel with no report at or before t is given by its first
+// report, so every vessel in the Window is in the Snapshot.  A position that
+// cannot be parsed is not interpolated.
+//
+// The Records are in the order of the first report of each vessel in the
+// Window.  Records that are not interpolated are held by the Window and must
+// not be modified.
+func (win *Window) Snapshot(t time.Time) []*Record {
+	type bracket struct{ before, after *windowEntry }
+	vessels := make(map[string]*bracket)
+	var order []string
+	for i := range win.entries {
+		e := &win.entries[i]
+		mmsi, _ := e.rec.Value(win.mmsiIndex)
+		b, ok := vessels[mmsi]
+		if !ok {
+			b = new(bracket)
+			vessels[mmsi] = b
+			order = append(order, mmsi)
+		}
+		switch {
+		case !e.t.After(t):
+			b.before = e
+		case b.after == nil:
+			b.after = e
+		}
+	}
+
+	recs := make([]*Record, 0, len(order))
+	for _, mmsi := range order {
+		b := vessels[mmsi]
+		switch {
+		case b.before == nil:
+			recs = append(recs, b.after.rec)
+		case b.after == nil || b.before.t.Equal(t):
+			recs = append(recs, b.before.rec)
+		default:
+			recs = append(recs, win.interpolate(t, b.before, b.after))
+		}
+	}
+	return recs
+}
+
+// interpolate returns a new Record for the position at t between the reports
+// of a vessel in before and after, or the Record of before when a position
+// cannot be parsed.
+func (win *Window) interpolate(t time.Time, before, after *windowEntry) *Record {
+	var pos [4]float64
+	for i, v := range []struct {
+		rec   *Record
+		index int
+	}{
+		{before.rec, win.latIndex}, {before.rec, win.lonIndex},
+		{after.rec, win.latIndex}, {after.rec, win.lonIndex},
+	} {
+		s, ok := v.rec.Value(v.index)
+		f, err := strconv.ParseFloat(s, 64)
+		if !ok || err != nil {
+			return before.rec
+		}
+		pos[i] = f
+	}
+	lat1, lon1, lat2, lon2 := pos[0], pos[1], pos[2], pos[3]
+
+	frac := float64(t.Sub(before.t)) / float64(after.t.Sub(before.t))
+	// Take the short way around when the track crosses the antimeridian.
+	dlon := lon2 - lon1
+	switch {
+	case dlon > 180:
+		dlon -= 360
+	case dlon < -180:
+		dlon += 360
+	}
+	lat := lat1 + frac*(lat2-lat1)
+	lon := lon1 + frac*dlon
+	switch {
+	case lon >= 180:
+		lon -= 360
+	case lon < -180:
+		lon += 360
+	}
+
+	src := before.rec
+	if frac > 0.5 {
+		src = after.rec
+	}
+	rec := make(Record, len(*src))
+	copy(rec, *src)
+	rec[win.latIndex] = strconv.FormatFloat(lat, 'f', 5, 64)
+	rec[win.lonIndex] = strconv.FormatFloat(lon, 'f', 5, 64)
+	if win.timeIndex < len(rec) {
+		rec[win.timeIndex] = formatTimeLayout(win.layout, t)
+	}
+	return &rec
+}
+
+// snapshotRecords returns the Records of the Window selected by its
+// SnapshotMode.
+func (win *Window) snapshotRecords() []*Record {
+	if win.mode == InterpolatedReports {
+		return win.Snapshot(win.Left().Add(win.Width() / 2))
+	}
+	return win.Records()
+}
+
+// keepLatest applies LatestReports to a Record of the Window at time t.  It
+// returns false when the Window holds a later Record of the same MMSI, and
+// otherwise removes the earlier Record of the MMSI, if any.
+func (win *Window) keepLatest(rec Record, t time.Time) bool {
+	mmsi, _ := rec.Value(win.mmsiIndex)
+	prev, ok := win.latest[mmsi]
+	if !ok {
+		return true
+	}
+	if t.Before(prev.t) {
+		return false
+	}
+	n := len(win.entries)
+	for i := sort.Search(n, func(i int) bool { return !win.entries[i].t.Before(prev.t) }); i < n && win.entries[i].t.Equal(prev.t); i++ {
+		if win.entries[i].hash == prev.hash {
+			copy(win.entries[i:], win.entries[i+1:])
+			win.entries[n-1] = windowEntry{}
+			win.entries = win.entries[:n-1]
+			delete(win.hashes, prev.hash)
+			delete(win.Data, prev.hash)
+			break
+		}
+	}
+	return true
+}
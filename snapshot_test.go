@@ -0,0 +1,192 @@
+package ais
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// snapshotData has three reports from each of two vessels in the same geohash
+// cell and one report from a third vessel in another cell.
+const snapshotData = `MMSI,BaseDateTime,LAT,LON,Geohash
+1,2017-12-01T00:00:00,36.00000,-76.00000,0x1
+2,2017-12-01T00:00:30,36.50000,-76.50000,0x1
+1,2017-12-01T00:01:00,36.10000,-76.20000,0x1
+2,2017-12-01T00:01:30,36.50000,-76.50000,0x1
+1,2017-12-01T00:02:00,36.20000,-76.40000,0x1
+2,2017-12-01T00:02:30,36.60000,-76.50000,0x1
+3,2017-12-01T00:03:00,179.90000,179.90000,0x2
+`
+
+// snapshotWindow returns a ten minute Window holding the Records of data.
+func snapshotWindow(t *testing.T, data string, mode SnapshotMode) *Window {
+	rs, err := NewRecordSetFromReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	win, err := NewWindow(rs, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("NewWindow() error = %v", err)
+	}
+	if err := win.SetSnapshot(mode); err != nil {
+		t.Fatalf("Window.SetSnapshot() error = %v", err)
+	}
+	for {
+		rec, err := rs.Read()
+		if err != nil {
+			break
+		}
+		win.AddRecord(*rec)
+	}
+	return win
+}
+
+func TestWindow_SetSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    SnapshotMode
+		want    []string // MMSI and time of the Records in the Window
+		inter   int      // interactions found in the clusters
+		wantErr bool
+	}{
+		{"all", AllReports, []string{"1 00:00", "2 00:00", "1 00:01", "2 00:01", "1 00:02", "2 00:02", "3 00:03"}, 9, false},
+		{"latest", LatestReports, []string{"1 00:02", "2 00:02", "3 00:03"}, 1, false},
+		{"interpolated", InterpolatedReports, []string{"1 00:00", "2 00:00", "1 00:01", "2 00:01", "1 00:02", "2 00:02", "3 00:03"}, 1, false},
+		{"unknown", SnapshotMode(7), nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			win := snapshotWindow(t, snapshotData, AllReports)
+			if err := win.SetSnapshot(tt.mode); (err != nil) != tt.wantErr {
+				t.Fatalf("Window.SetSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, rec := range win.Records() {
+				got = append(got, (*rec)[0]+" "+(*rec)[1][11:16])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Window.Records() = %v, want %v", got, tt.want)
+			}
+
+			h := Headers{Fields: strings.Split("MMSI,BaseDateTime,LAT,LON,Geohash", ",")}
+			inter, _ := NewInteractions(h)
+			for _, c := range win.FindClusters(4) {
+				if err := inter.AddCluster(c); err != nil {
+					t.Fatalf("Interactions.AddCluster() error = %v", err)
+				}
+			}
+			if inter.Len() != tt.inter {
+				t.Errorf("Interactions.Len() = %d, want %d", inter.Len(), tt.inter)
+			}
+		})
+	}
+}
+
+func TestWindow_LatestReports(t *testing.T) {
+	win := snapshotWindow(t, snapshotData, LatestReports)
+
+	// An older report of a vessel in the Window is not added.
+	win.AddRecord(Record{"1", "2017-12-01T00:01:45", "36.1", "-76.3", "0x1"})
+	if win.Len() != 3 {
+		t.Errorf("Window.Len() = %d after an older report, want 3", win.Len())
+	}
+
+	// Once the latest report of a vessel leaves the Window the next report of
+	// the vessel is added.
+	win.Slide(2*time.Minute + 15*time.Second)
+	if win.Len() != 2 {
+		t.Fatalf("Window.Len() = %d after Slide, want 2", win.Len())
+	}
+	win.Slide(30 * time.Second)
+	win.AddRecord(Record{"2", "2017-12-01T00:02:50", "36.6", "-76.5", "0x1"})
+	if win.Len() != 2 {
+		t.Errorf("Window.Len() = %d, want 2: %v", win.Len(), win)
+	}
+}
+
+func TestWindow_Snapshot(t *testing.T) {
+	data := snapshotData + "3,2017-12-01T00:05:00,179.90000,-179.90000,0x2\n"
+	win := snapshotWindow(t, data, AllReports)
+	tests := []struct {
+		at   string
+		want []Record
+	}{
+		{"00:00:15", []Record{
+			{"1", "2017-12-01T00:00:15", "36.02500", "-76.05000", "0x1"},
+			{"2", "2017-12-01T00:00:30", "36.50000", "-76.50000", "0x1"},
+			{"3", "2017-12-01T00:03:00", "179.90000", "179.90000", "0x2"},
+		}},
+		{"00:01:00", []Record{
+			{"1", "2017-12-01T00:01:00", "36.10000", "-76.20000", "0x1"},
+			{"2", "2017-12-01T00:01:00", "36.50000", "-76.50000", "0x1"},
+			{"3", "2017-12-01T00:03:00", "179.90000", "179.90000", "0x2"},
+		}},
+		{"00:04:00", []Record{
+			{"1", "2017-12-01T00:02:00", "36.20000", "-76.40000", "0x1"},
+			{"2", "2017-12-01T00:02:30", "36.60000", "-76.50000", "0x1"},
+			{"3", "2017-12-01T00:04:00", "179.90000", "-180.00000", "0x2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			at, _ := ParseTimestamp("2017-12-01T" + tt.at)
+			var got []Record
+			for _, rec := range win.Snapshot(at) {
+				got = append(got, *rec)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Window.Snapshot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if win.Len() != 8 {
+		t.Errorf("Window.Len() = %d after Snapshot, want 8", win.Len())
+	}
+}
+
+func TestWindow_InterpolatedReportsAfterMiddle(t *testing.T) {
+	// Vessel 4 first reports after the middle of the Window, near vessel 3.
+	data := snapshotData + "4,2017-12-01T00:07:00,179.90100,179.90000,0x2\n"
+	win := snapshotWindow(t, data, InterpolatedReports)
+
+	var got []string
+	for _, rec := range win.Snapshot(win.Left().Add(win.Width() / 2)) {
+		got = append(got, (*rec)[0]+" "+(*rec)[1][11:19])
+	}
+	want := []string{"1 00:02:00", "2 00:02:30", "3 00:03:00", "4 00:07:00"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Window.Snapshot() = %v, want %v", got, want)
+	}
+
+	h := Headers{Fields: strings.Split("MMSI,BaseDateTime,LAT,LON,Geohash", ",")}
+	inter, _ := NewInteractions(h)
+	for _, c := range win.FindClusters(4) {
+		if err := inter.AddCluster(c); err != nil {
+			t.Fatalf("Interactions.AddCluster() error = %v", err)
+		}
+	}
+	if inter.Len() != 2 {
+		t.Errorf("Interactions.Len() = %d, want 2", inter.Len())
+	}
+}
+
+func TestWindow_SetSnapshotHeaders(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(windowData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	win, err := NewWindow(rs, time.Minute)
+	if err != nil {
+		t.Fatalf("NewWindow() error = %v", err)
+	}
+	if err := win.SetSnapshot(LatestReports); err != nil {
+		t.Errorf("Window.SetSnapshot(LatestReports) error = %v", err)
+	}
+	if err := win.SetSnapshot(InterpolatedReports); err == nil {
+		t.Errorf("Window.SetSnapshot(InterpolatedReports) without LAT and LON error = nil")
+	}
+}
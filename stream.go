@@ -0,0 +1,400 @@
+package ais
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Scanner provides a convenient interface for reading the Records of a
+// RecordSet in the style of bufio.Scanner.  Successive calls to Scan step
+// through the Records and Record returns the current one.  Scan returns false
+// at the end of the data or on the first error, which is then available from
+// Err.
+//
+//	s := ais.NewScanner(rs)
+//	for s.Scan() {
+//		rec := s.Record()
+//		// process rec
+//	}
+//	if err := s.Err(); err != nil {
+//		panic(err)
+//	}
+type Scanner struct {
+	rs  *RecordSet
+	rec *Record
+	err error
+}
+
+// NewScanner returns a *Scanner that reads the Records remaining in rs.
+func NewScanner(rs *RecordSet) *Scanner {
+	return &Scanner{rs: rs}
+}
+
+// Scan advances the Scanner to the next Record.  It returns false when there
+// are no more Records or an error occurred.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	rec, err := s.rs.Read()
+	if err != nil {
+		s.rec = nil
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+	s.rec = rec
+	return true
+}
+
+// Record returns the Record read by the most recent call to Scan.
+func (s *Scanner) Record() *Record { return s.rec }
+
+// Err returns the first error encountered by the Scanner.  It returns nil when
+// Scan stopped at the end of the data.
+func (s *Scanner) Err() error { return s.err }
+
+// Unscan ensures that the next call to Scan returns the current Record again.
+// It uses RecordSet.Stash to return the Record to the underlying RecordSet.
+func (s *Scanner) Unscan() {
+	if s.rec != nil {
+		s.rs.Stash(s.rec)
+	}
+}
+
+// streamBuffer is the number of Records buffered between the stages of a
+// Pipeline.
+const streamBuffer = 256
+
+// StageFunc is the signature of a custom Pipeline stage added with Then.  It
+// receives Records from in and sends its results to out until in is closed
+// and returns a non-nil error to stop the Pipeline.  A StageFunc must not
+// close out and should return when ctx is done.  Records received from in
+// belong to the stage and may be modified.
+type StageFunc func(ctx context.Context, in <-chan Record, out chan<- Record) error
+
+// Pipeline streams the Records of a RecordSet through a chain of stages that
+// run concurrently, each in its own goroutine and connected by channels.
+// Records flow from one stage to the next without intermediate RecordSets,
+// so a Pipeline over a large file holds only the few Records in flight in
+// memory.  A Pipeline is created with NewPipeline, extended with Subset,
+// AppendField and Then, and consumed with one of Each, Records or RecordSet.
+//
+//	p := ais.NewPipeline(ctx, rs).
+//		Subset(box).
+//		AppendField("Geohash", []string{"LAT", "LON"}, ais.NewGeohasher(rs))
+//	err := p.Each(func(rec ais.Record) error {
+//		// process rec
+//		return nil
+//	})
+//
+// The first error returned by any stage cancels the Pipeline and is returned
+// by the consuming method.  Canceling ctx stops the Pipeline with the error of
+// the context.  Errors in building the Pipeline, such as a missing header, are
+// held and returned by the consuming method in the same way.  Malformed
+// Records are handled by the ErrorPolicy of the source RecordSet, and the
+// Records skipped by the stages are counted by its Skipped method once the
+// Pipeline is done.  The RecordSet
+// that feeds the Pipeline must not be read by the client until the Pipeline
+// is done.
+type Pipeline struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	h      Headers
+	out    <-chan Record
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    error
+
+	src      *RecordSet  // source whose Skipped counts the stages
+	policy   ErrorPolicy // of the source, applied by the stages
+	sink     RejectSink
+	rejectMu sync.Mutex // serializes the rejects of the source and the stages
+	skipped  int        // Records skipped by the stages, guarded by rejectMu
+	counted  bool       // skipped has been added to src, guarded by mu
+}
+
+// NewPipeline returns a *Pipeline whose source reads the Records remaining in
+// rs.  The source starts reading as soon as the Pipeline is created.
+func NewPipeline(ctx context.Context, rs *RecordSet) *Pipeline {
+	p := new(Pipeline)
+	p.parent = ctx
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.h = rs.Headers()
+	p.src, p.policy, p.sink = rs, rs.policy, rs.sink
+
+	out := make(chan Record, streamBuffer)
+	p.out = out
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(out)
+		// The source rejects Records under the same lock as the stages.
+		if sink := rs.sink; sink != nil {
+			rs.sink = lockedSink{&p.rejectMu, sink}
+			defer func() { rs.sink = sink }()
+		}
+		for {
+			rec, err := rs.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				p.fail(fmt.Errorf("pipeline: %v", err))
+				return
+			}
+			if !send(p.ctx, out, *rec) {
+				p.canceled()
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Headers returns the Headers of the Records leaving the last stage of the
+// Pipeline.
+func (p *Pipeline) Headers() Headers { return p.h }
+
+// Subset adds a stage to the Pipeline that passes on the Records that return
+// true from m.Match and drops the rest.  Records that m.Match returns an error
+// for are handled by the ErrorPolicy of the source RecordSet as in
+// RecordSet.Subset.
+func (p *Pipeline) Subset(m Matching) *Pipeline {
+	h := p.h
+	return p.Then(func(ctx context.Context, in <-chan Record, out chan<- Record) error {
+		for rec := range in {
+			match, err := m.Match(&rec)
+			if err != nil {
+				if err := p.reject(h, rec, err); err != nil {
+					return fmt.Errorf("subset: %v", err)
+				}
+				continue
+			}
+			if match && !send(ctx, out, rec) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// AppendField adds a stage to the Pipeline that appends the Field returned by
+// gen to each Record under the header newField in the same way as
+// RecordSet.AppendField.
+func (p *Pipeline) AppendField(newField string, requiredHeaders []string, gen Generator) *Pipeline {
+	return p.AppendFields(1, NewField{Name: newField, Required: requiredHeaders, Gen: gen})
+}
+
+// AppendFields adds a stage to the Pipeline that appends the Field of each
+// NewField to every Record in a single pass.  With workers greater than one
+// the Generators run on batches of Records in that many goroutines and the
+// Records leave the stage in their original order.  Records that a Generator
+// returns an error for are handled by the ErrorPolicy of the source RecordSet
+// as in RecordSet.AppendField.
+func (p *Pipeline) AppendFields(workers int, fields ...NewField) *Pipeline {
+	h, gen, err := appendGenerator(p.h, fields)
+	if err != nil {
+		p.fail(fmt.Errorf("pipeline: %v", err))
+		return p
+	}
+	inHeaders := p.h
+	reject := func(rec Record, err error) error {
+		return p.reject(inHeaders, rec, err)
+	}
+	if workers > 1 {
+		p.Then(parallelStage(workers, gen, reject))
+	} else {
+		p.Then(func(ctx context.Context, in <-chan Record, out chan<- Record) error {
+			for rec := range in {
+				next, err := gen(rec)
+				if err != nil {
+					if err := reject(rec, err); err != nil {
+						return err
+					}
+					continue
+				}
+				if !send(ctx, out, next) {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+	p.h = h
+	return p
+}
+
+// Then adds the custom stage fn to the end of the Pipeline.  The Headers of
+// the Records are unchanged by the stage.
+func (p *Pipeline) Then(fn StageFunc) *Pipeline {
+	in := p.out
+	out := make(chan Record, streamBuffer)
+	p.out = out
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(out)
+		// Drain in when the stage returns early so that the stage before it
+		// is not left blocked on a send.
+		defer func() {
+			for range in {
+			}
+		}()
+		if err := fn(p.ctx, in, out); err != nil {
+			p.fail(fmt.Errorf("pipeline: %v", err))
+			return
+		}
+		p.canceled()
+	}()
+	return p
+}
+
+// Records returns the channel of Records leaving the last stage of the
+// Pipeline.  The channel is closed when the Pipeline is done and Err should
+// then be called to check for an error.  Clients that stop receiving before the
+// channel is closed must call Stop.
+func (p *Pipeline) Records() <-chan Record { return p.out }
+
+// Err waits for all of the stages of the Pipeline to return and reports the
+// first error encountered.  It returns the error of the context when the
+// context was canceled before the Pipeline finished.
+func (p *Pipeline) Err() error {
+	p.wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Stop cancels the Pipeline and waits for its stages to return.
+func (p *Pipeline) Stop() {
+	p.cancel()
+	for range p.out {
+	}
+	p.wait()
+}
+
+// wait waits for the stages of the Pipeline to return and then counts the
+// Records they skipped in RecordSet.Skipped of the source.
+func (p *Pipeline) wait() {
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.counted {
+		p.src.skipped += p.skipped
+		p.counted = true
+	}
+}
+
+// reject applies the ErrorPolicy of the source RecordSet to rec, which a stage
+// whose input has the Headers h returned err for.  It returns nil when rec
+// should be dropped and the error that stops the Pipeline otherwise.
+func (p *Pipeline) reject(h Headers, rec Record, err error) error {
+	re, ok := err.(*RecordError)
+	if !ok {
+		re = &RecordError{Column: -1, Err: err}
+	}
+	re.Record = rec
+	if re.Field == "" && re.Column >= 0 && re.Column < len(h.Fields) {
+		re.Field = h.Fields[re.Column]
+	}
+	p.rejectMu.Lock()
+	defer p.rejectMu.Unlock()
+	return handleReject(p.policy, p.sink, &p.skipped, re)
+}
+
+// lockedSink is a RejectSink that holds mu while it calls sink, so that the
+// stages of a Pipeline and its source can share a sink.
+type lockedSink struct {
+	mu   *sync.Mutex
+	sink RejectSink
+}
+
+// Reject implements the RejectSink interface for lockedSink.
+func (l lockedSink) Reject(e *RecordError) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sink.Reject(e)
+}
+
+// Each calls fn on every Record leaving the Pipeline.  A non-nil error from fn
+// stops the Pipeline and is returned by Each.
+func (p *Pipeline) Each(fn func(rec Record) error) error {
+	for rec := range p.out {
+		if err := fn(rec); err != nil {
+			p.fail(err)
+			p.Stop()
+			break
+		}
+	}
+	return p.Err()
+}
+
+// RecordSet returns a *RecordSet that reads the Records leaving the Pipeline,
+// so the methods of RecordSet such as Save, SortByTime and UniqueVessels can
+// consume a Pipeline.  A read of the RecordSet returns the error of the
+// Pipeline, if any, in place of io.EOF.  Closing the RecordSet stops the
+// Pipeline.
+func (p *Pipeline) RecordSet() *RecordSet {
+	rs := NewRecordSet()
+	rs.h = p.h
+	rs.src = &pipelineReader{p}
+	return rs
+}
+
+// fail records err as the error of the Pipeline when it is the first one and
+// cancels the Pipeline.
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// canceled records the error of the context passed to NewPipeline when it was
+// canceled before a stage finished.
+func (p *Pipeline) canceled() {
+	if err := p.parent.Err(); err != nil {
+		p.fail(err)
+	}
+}
+
+// pipelineReader reads the Records leaving a Pipeline as the source of a
+// RecordSet.
+type pipelineReader struct {
+	p *Pipeline
+}
+
+// Read returns the fields of the next Record, or io.EOF after the last one.
+func (pr *pipelineReader) Read() ([]string, error) {
+	rec, ok := <-pr.p.out
+	if ok {
+		return rec, nil
+	}
+	if err := pr.p.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close stops the Pipeline.
+func (pr *pipelineReader) Close() error {
+	pr.p.Stop()
+	return nil
+}
+
+// send sends rec on out and reports whether it was sent before ctx was done.
+func send(ctx context.Context, out chan<- Record, rec Record) bool {
+	select {
+	case out <- rec:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
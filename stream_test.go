@@ -0,0 +1,173 @@
+package ais
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	s := NewScanner(rs)
+	n := 0
+	var last Record
+	for s.Scan() {
+		n++
+		if n == 1 {
+			// The first Record is returned again after Unscan.
+			first := *s.Record()
+			s.Unscan()
+			if !s.Scan() || !reflect.DeepEqual(*s.Record(), first) {
+				t.Fatalf("Scanner.Unscan() did not return the first Record")
+			}
+		}
+		last = *s.Record()
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Scanner.Err() = %v", err)
+	}
+	if n != 10 {
+		t.Errorf("Scanner read %d records, want 10", n)
+	}
+	if last[0] != "367180910" {
+		t.Errorf("last MMSI = %s, want 367180910", last[0])
+	}
+	if s.Scan() {
+		t.Errorf("Scanner.Scan() after the end = true, want false")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	box := &Box{MinLat: 34, MaxLat: 38, MinLon: -77, MaxLon: -75, LatIndex: 2, LonIndex: 3}
+	tests := []struct {
+		name    string
+		build   func(p *Pipeline) *Pipeline
+		want    int
+		wantErr bool
+	}{
+		{"source only", func(p *Pipeline) *Pipeline { return p }, 10, false},
+		{"subset", func(p *Pipeline) *Pipeline { return p.Subset(box) }, -1, false},
+		{"subset geohash", func(p *Pipeline) *Pipeline {
+			return p.Subset(box).AppendField("Geohash", []string{"LAT", "LON"}, PrecisionGeohasher(DefaultGeohashPrecision))
+		}, -1, false},
+		{"missing header", func(p *Pipeline) *Pipeline {
+			return p.AppendField("Geohash", []string{"Latitude", "LON"}, PrecisionGeohasher(DefaultGeohashPrecision))
+		}, 0, true},
+		{"stage error", func(p *Pipeline) *Pipeline {
+			return p.Then(func(ctx context.Context, in <-chan Record, out chan<- Record) error {
+				for range in {
+					return errors.New("stage failed")
+				}
+				return nil
+			})
+		}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want < 0 {
+				// Compare with the Records matched by RecordSet.Subset.
+				rs, _ := OpenRecordSet("testdata/ten.csv")
+				sub, err := rs.Subset(box)
+				if err != nil {
+					t.Fatalf("RecordSet.Subset() error = %v", err)
+				}
+				want = countRecords(t, sub)
+				rs.Close()
+			}
+
+			rs, err := OpenRecordSet("testdata/ten.csv")
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			p := tt.build(NewPipeline(context.Background(), rs))
+			n := 0
+			err = p.Each(func(rec Record) error {
+				if len(rec) != len(p.Headers().Fields) {
+					return fmt.Errorf("record has %d fields, want %d", len(rec), len(p.Headers().Fields))
+				}
+				n++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Pipeline.Each() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && n != want {
+				t.Errorf("Pipeline.Each() read %d records, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestPipeline_RecordSet(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	p := NewPipeline(context.Background(), rs).
+		AppendField("Geohash", []string{"LAT", "LON"}, PrecisionGeohasher(DefaultGeohashPrecision))
+	out := p.RecordSet()
+	defer out.Close()
+	sorted, err := out.SortByTime()
+	if err != nil {
+		t.Fatalf("RecordSet.SortByTime() error = %v", err)
+	}
+	if _, ok := sorted.Headers().Contains("Geohash"); !ok {
+		t.Errorf("Headers = %v, want Geohash", sorted.Headers().Fields)
+	}
+	if got := countRecords(t, sorted); got != 10 {
+		t.Errorf("read %d records, want 10", got)
+	}
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	p := NewPipeline(ctx, rs).Then(func(ctx context.Context, in <-chan Record, out chan<- Record) error {
+		<-block
+		for rec := range in {
+			if !send(ctx, out, rec) {
+				return nil
+			}
+		}
+		return nil
+	})
+	cancel()
+	close(block)
+	for range p.Records() {
+	}
+	if err := p.Err(); err != context.Canceled {
+		t.Errorf("Pipeline.Err() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPipeline_Stop(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer rs.Close()
+
+	p := NewPipeline(context.Background(), rs)
+	<-p.Records()
+	p.Stop()
+	if err := p.Err(); err != nil {
+		t.Errorf("Pipeline.Err() after Stop = %v, want nil", err)
+	}
+}
@@ -0,0 +1,146 @@
+package ais
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Special layouts for RecordSet.SetTimeLayout that parse Unix epoch
+// timestamps instead of calling time.Parse.
+const (
+	TimeUnix      = "unix"   // seconds since 1970-01-01T00:00:00Z
+	TimeUnixMilli = "unixms" // milliseconds since 1970-01-01T00:00:00Z
+)
+
+// TimeLayouts are the layouts tried in order by ParseTimestamp.  Layouts
+// without a zone are interpreted as UTC.  The dd/mm/yyyy layout used by the
+// Danish Maritime Authority is tried before yyyy/mm/dd, and the ambiguous
+// mm/dd/yyyy order is not tried at all.  Set the layout of a RecordSet with
+// SetTimeLayout for data in that order.
+var TimeLayouts = []string{
+	TimeLayout,
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z0700",
+	"02/01/2006 15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+// ParseTimestamp converts s into a UTC time.Time.  It detects the ISO-8601
+// forms with or without the 'T' separator, fractional seconds and zone offsets,
+// DMA's dd/mm/yyyy hh:mm:ss form and Unix epoch timestamps.  Only epoch
+// timestamps with ten digits of seconds or thirteen digits of milliseconds,
+// which covers the years 2001 through 2286, are detected so that other numbers
+// such as an MMSI are not mistaken for a time.  Use SetTimeLayout with TimeUnix
+// or TimeUnixMilli for other epoch values.
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if isEpoch(s) {
+		switch len(strings.SplitN(s, ".", 2)[0]) {
+		case 10:
+			return parseTimeLayout(TimeUnix, s)
+		case 13:
+			return parseTimeLayout(TimeUnixMilli, s)
+		}
+	}
+	for _, layout := range TimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parse timestamp: unrecognized format %q", s)
+}
+
+// parseTimeLayout parses s with layout and returns the UTC instant.  An empty
+// layout detects the format with ParseTimestamp.
+func parseTimeLayout(layout, s string) (time.Time, error) {
+	switch layout {
+	case "":
+		return ParseTimestamp(s)
+	case TimeUnix, TimeUnixMilli:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || !isEpoch(strings.TrimSpace(s)) {
+			return time.Time{}, fmt.Errorf("parse timestamp: %q is not an epoch timestamp", s)
+		}
+		if layout == TimeUnix {
+			f *= 1000
+		}
+		ms := int64(f)
+		frac := time.Duration((f - float64(ms)) * float64(time.Millisecond))
+		return time.Unix(0, ms*int64(time.Millisecond)).Add(frac).UTC(), nil
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// formatTimeLayout formats t with a layout accepted by parseTimeLayout.  The
+// empty layout formats t with TimeLayout.
+func formatTimeLayout(layout string, t time.Time) string {
+	switch layout {
+	case "":
+		return t.UTC().Format(TimeLayout)
+	case TimeUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeUnixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.UTC().Format(layout)
+}
+
+// isEpoch returns true when s is a non-negative decimal number.
+func isEpoch(s string) bool {
+	if s == "" {
+		return false
+	}
+	dot := false
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !dot && i > 0:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SetTimeLayout sets the layout used to parse the BaseDateTime of every Record in
+// the RecordSet with Headers.ParseTime.  The layout is a time.Parse layout,
+// TimeUnix or TimeUnixMilli.  It overrides the TimeLayout of the Schema.  An
+// empty layout removes the override, so the TimeLayout of the Schema is used
+// again and the format of each timestamp is detected with ParseTimestamp only
+// when the RecordSet has no Schema or its Schema has no TimeLayout.
+func (rs *RecordSet) SetTimeLayout(layout string) {
+	rs.h.timeLayout = layout
+}
+
+// TimeLayout returns the layout used by ParseTime.  It is the layout set with
+// RecordSet.SetTimeLayout, or else the TimeLayout of the Schema.  An empty
+// string means the format of each timestamp is detected.
+func (h Headers) TimeLayout() string {
+	if h.timeLayout != "" {
+		return h.timeLayout
+	}
+	if h.schema != nil {
+		return h.schema.TimeLayout
+	}
+	return ""
+}
+
+// ParseTime parses the timestamp at index of rec with the layout returned by
+// TimeLayout and returns the UTC instant.  Use it in place of Record.ParseTime
+// for Records read from a RecordSet whose timestamps may not be in the
+// MarineCadastre format.
+func (h Headers) ParseTime(rec Record, index int) (time.Time, error) {
+	return parseTimeLayout(h.TimeLayout(), rec[index])
+}
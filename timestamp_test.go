@@ -0,0 +1,140 @@
+package ais
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2017, 12, 1, 0, 0, 3, 0, time.UTC)
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"marinecadastre", "2017-12-01T00:00:03", want, false},
+		{"space separator", "2017-12-01 00:00:03", want, false},
+		{"zulu", "2017-12-01T00:00:03Z", want, false},
+		{"offset", "2017-12-01T02:00:03+02:00", want, false},
+		{"offset with space", "2017-11-30 19:00:03-05:00", want, false},
+		{"offset without colon", "2017-12-01T01:00:03+0100", want, false},
+		{"fractional seconds", "2017-12-01T00:00:03.250", want.Add(250 * time.Millisecond), false},
+		{"dma", "01/12/2017 00:00:03", want, false},
+		{"slashes", "2017/12/01 00:00:03", want, false},
+		{"epoch seconds", "1512086403", want, false},
+		{"epoch fractional seconds", "1512086403.5", want.Add(500 * time.Millisecond), false},
+		{"epoch millis", "1512086403250", want.Add(250 * time.Millisecond), false},
+		{"padded", " 2017-12-01T00:00:03 ", want, false},
+		{"mmsi is not a time", "376494000", time.Time{}, true},
+		{"month first", "12-01-2017T00:00:03", time.Time{}, true},
+		{"blank", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("ParseTimestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordSet_SetTimeLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"detect", "", "2017-12-01 00:00:03", time.Date(2017, 12, 1, 0, 0, 3, 0, time.UTC), false},
+		{"month first", "01/02/2006 15:04:05", "12/01/2017 00:00:03", time.Date(2017, 12, 1, 0, 0, 3, 0, time.UTC), false},
+		{"unix", TimeUnix, "86400", time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"unix millis", TimeUnixMilli, "1500", time.Date(1970, 1, 1, 0, 0, 1, 5e8, time.UTC), false},
+		{"unix not a number", TimeUnix, "yesterday", time.Time{}, true},
+		{"layout mismatch", TimeLayout, "2017-12-01 00:00:03", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRecordSet()
+			rs.SetHeaders(Headers{Fields: []string{"MMSI", "BaseDateTime"}})
+			rs.SetTimeLayout(tt.layout)
+			if got := rs.Headers().TimeLayout(); got != tt.layout {
+				t.Errorf("Headers.TimeLayout() = %q, want %q", got, tt.layout)
+			}
+			got, err := rs.Headers().ParseTime(Record{"376494000", tt.s}, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Headers.ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Headers.ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordSet_SetTimeLayoutSchema(t *testing.T) {
+	rs := NewRecordSet()
+	rs.SetHeaders(Headers{Fields: []string{"MMSI", "BaseDateTime", "Y", "X"}})
+	if err := rs.SetSchema(NOAALegacySchema); err != nil {
+		t.Fatalf("RecordSet.SetSchema() error = %v", err)
+	}
+	rec := Record{"376494000", "2017/12/01 00:00:03", "36.0", "-76.0"}
+	want := time.Date(2017, 12, 1, 0, 0, 3, 0, time.UTC)
+
+	rs.SetTimeLayout(TimeUnix)
+	if _, err := rs.Headers().ParseTime(rec, 1); err == nil {
+		t.Errorf("Headers.ParseTime() with TimeUnix error = nil, want error")
+	}
+
+	// An empty layout removes the override and the Schema layout is used again.
+	rs.SetTimeLayout("")
+	if got := rs.Headers().TimeLayout(); got != NOAALegacySchema.TimeLayout {
+		t.Errorf("Headers.TimeLayout() = %q, want %q", got, NOAALegacySchema.TimeLayout)
+	}
+	got, err := rs.Headers().ParseTime(rec, 1)
+	if err != nil || !got.Equal(want) {
+		t.Errorf("Headers.ParseTime() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestSortByTime_Zones(t *testing.T) {
+	rs := NewRecordSet()
+	rs.SetHeaders(Headers{Fields: []string{"MMSI", "BaseDateTime"}})
+	for _, rec := range []Record{
+		{"1", "2017-12-01T02:00:00+02:00"}, // 00:00Z
+		{"2", "2017-11-30T23:30:00-01:00"}, // 00:30Z
+		{"3", "2017-11-30T23:45:00Z"},      // 23:45Z the day before
+	} {
+		rs.Write(rec)
+	}
+	rs.Flush()
+
+	rs2, err := rs.SortByTime()
+	if err != nil {
+		t.Fatalf("RecordSet.SortByTime() error = %v", err)
+	}
+	var got string
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		got += (*rec)[0]
+	}
+	if got != "312" {
+		t.Errorf("RecordSet.SortByTime() order = %s, want 312", got)
+	}
+
+	bad := NewRecordSet()
+	bad.SetHeaders(Headers{Fields: []string{"MMSI", "BaseDateTime"}})
+	bad.Write(Record{"1", "not a time"})
+	bad.Flush()
+	if _, err := bad.SortByTime(); err == nil {
+		t.Errorf("RecordSet.SortByTime() with a bad time error = nil, want error")
+	}
+}
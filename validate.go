@@ -0,0 +1,318 @@
+package ais
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Values defined by ITU-R M.1371 to indicate that a kinematic field of an AIS
+// position report is not available.
+const (
+	SOGNotAvailable     = 102.3
+	COGNotAvailable     = 360.0
+	HeadingNotAvailable = 511
+)
+
+// Limits of the valid ranges for the kinematic fields of a position report.
+const (
+	MaxSOG     = 102.2
+	MaxHeading = 359
+)
+
+// ReasonField is the name of the field appended to rejected Records by
+// RecordSet.Clean to explain why each Record failed validation.
+const ReasonField = "Reason"
+
+// ValidationError describes a single field of a Record that failed one of the
+// AIS rules checked by a Validator.
+type ValidationError struct {
+	Field  string // header name of the field that failed
+	Value  string // the offending value
+	Reason string // human readable explanation
+}
+
+// Error implements the error interface for ValidationError.
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ve.Field, ve.Value, ve.Reason)
+}
+
+// Validator checks Records against the rules for a well formed AIS position
+// report.  MMSI, BaseDateTime, LAT and LON are required Headers.  SOG, COG and
+// Heading are checked when they are present in the Headers.  When the Headers
+// have a data dictionary every other field with a Type in its Definition is
+// checked to hold a value of that type.  Validator implements the Matching
+// interface so that rs.Subset(v) returns only the valid Records.
+type Validator struct {
+	h     Headers
+	idx   map[string]HeaderMap
+	typed []string // fields type checked with the data dictionary
+}
+
+// NewValidator returns a *Validator for Records described by h.  It returns an
+// error if h does not contain the required fields MMSI, BaseDateTime, LAT and LON.
+func NewValidator(h Headers) (*Validator, error) {
+	idxMap, ok := h.ContainsMulti("MMSI", "BaseDateTime", "LAT", "LON")
+	if !ok {
+		return nil, fmt.Errorf("new validator: headers must contain MMSI, BaseDateTime, LAT and LON")
+	}
+	for _, f := range []string{"SOG", "COG", "Heading"} {
+		if i, ok := h.Contains(f); ok {
+			idxMap[f] = HeaderMap{Present: true, Idx: i}
+		}
+	}
+	v := &Validator{h: h, idx: idxMap}
+	for i, f := range h.Fields {
+		if _, ok := idxMap[f]; ok {
+			continue
+		}
+		if d, ok := h.Definition(f); ok && d.Type != "" && d.Type != TypeString {
+			idxMap[f] = HeaderMap{Present: true, Idx: i}
+			v.typed = append(v.typed, f)
+		}
+	}
+	return v, nil
+}
+
+// Validate returns every rule the Record breaks.  A nil return value means the
+// Record is valid.
+func (v *Validator) Validate(rec *Record) []ValidationError {
+	var errs []ValidationError
+	check := func(field string, fn func(string) string) {
+		if !v.idx[field].Present {
+			return
+		}
+		val, ok := rec.ValueFrom(v.idx[field])
+		if !ok {
+			errs = append(errs, ValidationError{field, "", "field is missing from the record"})
+			return
+		}
+		if reason := fn(val); reason != "" {
+			errs = append(errs, ValidationError{field, val, reason})
+		}
+	}
+
+	check("MMSI", checkMMSI)
+	check("BaseDateTime", func(s string) string {
+		if _, err := v.h.ParseTime(Record{s}, 0); err != nil {
+			return "unparsable timestamp"
+		}
+		return ""
+	})
+	check("LAT", checkRange(-90, 90))
+	check("LON", checkRange(-180, 180))
+	check("SOG", checkRangeOr(0, MaxSOG, SOGNotAvailable))
+	check("COG", checkRange(0, COGNotAvailable))
+	check("Heading", func(s string) string {
+		h, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "not a number"
+		}
+		if h == HeadingNotAvailable {
+			return ""
+		}
+		if h < 0 || h > MaxHeading || h != float64(int(h)) {
+			return fmt.Sprintf("must be an integer between 0 and %d or %d", MaxHeading, HeadingNotAvailable)
+		}
+		return ""
+	})
+	for _, f := range v.typed {
+		d, _ := v.h.Definition(f)
+		check(f, checkType(d.Type))
+	}
+	return errs
+}
+
+// Match implements the Matching interface and returns true for valid Records.
+// The error value is always nil so that invalid Records are skipped by Subset
+// instead of stopping it.
+func (v *Validator) Match(rec *Record) (bool, error) {
+	return len(v.Validate(rec)) == 0, nil
+}
+
+// Repair returns a copy of rec with the repairable fields replaced and the rule
+// violations that could not be repaired.  Out of range or unparsable SOG and
+// Heading values are set to their not available values, and COG is normalized into
+// [0,360) when it is a number or set to not available when it is not.  Errors in
+// MMSI, BaseDateTime, LAT and LON cannot be repaired.  The returned Record is
+// valid when the returned slice is empty.
+func (v *Validator) Repair(rec *Record) (Record, []ValidationError) {
+	fixed := make(Record, len(*rec))
+	copy(fixed, *rec)
+
+	var unrepaired []ValidationError
+	for _, ve := range v.Validate(rec) {
+		hm := v.idx[ve.Field]
+		if hm.Idx >= len(fixed) {
+			unrepaired = append(unrepaired, ve)
+			continue
+		}
+		switch ve.Field {
+		case "SOG":
+			fixed[hm.Idx] = strconv.FormatFloat(SOGNotAvailable, 'f', 1, 64)
+		case "Heading":
+			fixed[hm.Idx] = strconv.FormatFloat(HeadingNotAvailable, 'f', 1, 64)
+		case "COG":
+			fixed[hm.Idx] = repairCOG(ve.Value)
+		default:
+			unrepaired = append(unrepaired, ve)
+		}
+	}
+	return fixed, unrepaired
+}
+
+// CleanAction controls what RecordSet.Clean does with Records that fail
+// validation.  The actions can be combined with a bitwise or.
+type CleanAction int
+
+// The available CleanActions.  DropInvalid is the zero value and removes every
+// invalid Record.  RepairInvalid keeps Records whose violations can all be
+// repaired by Validator.Repair.  QuarantineInvalid writes the Records that are
+// removed to a rejects RecordSet with an additional Reason field.
+const (
+	DropInvalid       CleanAction = 0
+	RepairInvalid     CleanAction = 1 << 0
+	QuarantineInvalid CleanAction = 1 << 1
+)
+
+// Clean reads every Record in the RecordSet and applies the Validator.  Valid
+// Records, and repaired Records when action includes RepairInvalid, are written
+// to the first returned *RecordSet.  When action includes QuarantineInvalid the
+// Records that were removed are written to the second returned *RecordSet with
+// the Headers of rs plus ReasonField.  Otherwise the second return value is nil.
+// Both returned RecordSets are nil for any non-nil error.
+func (rs *RecordSet) Clean(v *Validator, action CleanAction) (*RecordSet, *RecordSet, error) {
+	clean := NewRecordSet()
+	clean.SetHeaders(rs.Headers())
+
+	var rejects *RecordSet
+	if action&QuarantineInvalid != 0 {
+		rejects = NewRecordSet()
+		h := rs.Headers()
+		h.Fields = append(append([]string{}, h.Fields...), ReasonField)
+		rejects.SetHeaders(h)
+	}
+
+	written := 0
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("clean: %v", err)
+		}
+
+		out, violations := *rec, v.Validate(rec)
+		if len(violations) > 0 && action&RepairInvalid != 0 {
+			out, violations = v.Repair(rec)
+		}
+
+		if len(violations) == 0 {
+			err = clean.Write(out)
+		} else if rejects != nil {
+			err = rejects.Write(append(append(Record{}, *rec...), joinReasons(violations)))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("clean: csv write error: %v", err)
+		}
+
+		written++
+		if written%flushThreshold == 0 {
+			if err := clean.Flush(); err != nil {
+				return nil, nil, fmt.Errorf("clean: csv flush error: %v", err)
+			}
+		}
+	}
+
+	if err := clean.Flush(); err != nil {
+		return nil, nil, fmt.Errorf("clean: csv flush error: %v", err)
+	}
+	if rejects != nil {
+		if err := rejects.Flush(); err != nil {
+			return nil, nil, fmt.Errorf("clean: csv flush error: %v", err)
+		}
+	}
+	return clean, rejects, nil
+}
+
+// checkMMSI returns a non-empty reason when s is not a nine digit MMSI.
+func checkMMSI(s string) string {
+	if len(s) != 9 {
+		return "must be nine digits"
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return "must be nine digits"
+		}
+	}
+	return ""
+}
+
+// checkRange returns a function that validates a float in [min, max].
+func checkRange(min, max float64) func(string) string {
+	return func(s string) string {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			return "not a number"
+		}
+		if f < min || f > max {
+			return fmt.Sprintf("must be between %g and %g", min, max)
+		}
+		return ""
+	}
+}
+
+// checkRangeOr is checkRange that also accepts the not available value na.
+func checkRangeOr(min, max, na float64) func(string) string {
+	inRange := checkRange(min, max)
+	return func(s string) string {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == na {
+			return ""
+		}
+		if reason := inRange(s); reason != "" {
+			return fmt.Sprintf("%s or %g", reason, na)
+		}
+		return ""
+	}
+}
+
+// repairCOG normalizes a numeric course into [0,360).  MarineCadastre data
+// contains courses such as -97.8 that are valid bearings reported in the
+// negative direction.  A course that is not a finite number is repaired to
+// COGNotAvailable.
+func repairCOG(s string) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(COGNotAvailable, 'f', 1, 64)
+	}
+	f = math.Mod(f, 360)
+	switch {
+	case f < 0:
+		f += 360
+	case f == 0:
+		f = 0 // math.Mod keeps the sign of a negative zero
+	}
+	return strconv.FormatFloat(f, 'f', decimals(s), 64)
+}
+
+// decimals returns the number of digits after the decimal point in s, and at
+// least one so that repaired values keep the MarineCadastre formatting.
+func decimals(s string) int {
+	i := strings.IndexByte(s, '.')
+	if i < 0 || len(s)-i-1 < 1 {
+		return 1
+	}
+	return len(s) - i - 1
+}
+
+// joinReasons creates the value written to ReasonField for a rejected Record.
+func joinReasons(errs []ValidationError) string {
+	reasons := make([]string, len(errs))
+	for i, ve := range errs {
+		reasons[i] = ve.Error()
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,187 @@
+package ais
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		wantErr bool
+	}{
+		{"good headers", goodHeaders, false},
+		{"basic headers without kinematics", Headers{Fields: strings.Split(basicHeadersString, ",")}, false},
+		{"missing BaseDateTime", badHeaders, true},
+		{"missing MMSI", badHeaders2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator(tt.h)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewValidator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v, _ := NewValidator(goodHeaders)
+	base := "477307901,2017-12-01T00:00:01,31.90512,-76.32652,0.0,131.0,352.0,FIRST,IMO9739666,VRPJ6,1004,moored,337,,,"
+
+	// replace returns base with the field at index i replaced by val.
+	replace := func(i int, val string) *Record {
+		rec := Record(strings.Split(base, ","))
+		rec[i] = val
+		return &rec
+	}
+
+	tests := []struct {
+		name       string
+		rec        *Record
+		wantFields []string
+	}{
+		{"valid", replace(0, "477307901"), nil},
+		{"short mmsi", replace(0, "47730790"), []string{"MMSI"}},
+		{"letter in mmsi", replace(0, "47730790x"), []string{"MMSI"}},
+		{"bad time", replace(1, "2017-12-01Txx:00:01"), []string{"BaseDateTime"}},
+		{"lat too large", replace(2, "91"), []string{"LAT"}},
+		{"lon too small", replace(3, "-181"), []string{"LON"}},
+		{"sog too fast", replace(4, "102.25"), []string{"SOG"}},
+		{"sog not available", replace(4, "102.3"), nil},
+		{"negative cog", replace(5, "-97.8"), []string{"COG"}},
+		{"nan lat", replace(2, "NaN"), []string{"LAT"}},
+		{"nan sog", replace(4, "NaN"), []string{"SOG"}},
+		{"nan cog", replace(5, "NaN"), []string{"COG"}},
+		{"infinite cog", replace(5, "Inf"), []string{"COG"}},
+		{"huge cog", replace(5, "1e300"), []string{"COG"}},
+		{"cog not available", replace(5, "360.0"), nil},
+		{"heading not available", replace(6, "511.0"), nil},
+		{"heading out of range", replace(6, "360"), []string{"Heading"}},
+		{"blank heading", replace(6, ""), []string{"Heading"}},
+		{"short record", &Record{"477307901", "2017-12-01T00:00:01", "31.90512", "-76.32652"}, []string{"SOG", "COG", "Heading"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFields []string
+			for _, ve := range v.Validate(tt.rec) {
+				gotFields = append(gotFields, ve.Field)
+			}
+			if !reflect.DeepEqual(gotFields, tt.wantFields) {
+				t.Errorf("Validator.Validate() failed fields = %v, want %v", gotFields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidator_Repair(t *testing.T) {
+	v, _ := NewValidator(goodHeaders)
+	tests := []struct {
+		name           string
+		rec            Record
+		want           Record
+		wantUnrepaired int
+	}{
+		{
+			name:           "negative cog and bad heading",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "-97.8", "400"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "262.2", "511.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "fast sog and unparsable cog",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "300", "north", "5.0"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "102.3", "360.0", "5.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "nan cog",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "NaN", "5.0"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "360.0", "5.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "infinite cog",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "-Inf", "5.0"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "360.0", "5.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "huge cog",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "1e300", "5.0"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "0.0", "5.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "negative full turns",
+			rec:            Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "-720.0", "5.0"},
+			want:           Record{"367605855", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "0.0", "5.0"},
+			wantUnrepaired: 0,
+		},
+		{
+			name:           "bad mmsi cannot be repaired",
+			rec:            Record{"36760585x", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "-97.8", "5.0"},
+			want:           Record{"36760585x", "2017-12-01T00:00:05", "45.68222", "-74.04572", "0.0", "262.2", "5.0"},
+			wantUnrepaired: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unrepaired := v.Repair(&tt.rec)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validator.Repair() = %v, want %v", got, tt.want)
+			}
+			if len(unrepaired) != tt.wantUnrepaired {
+				t.Errorf("Validator.Repair() unrepaired = %v, want %d", unrepaired, tt.wantUnrepaired)
+			}
+		})
+	}
+}
+
+func TestRecordSet_Clean(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		action      CleanAction
+		wantClean   int
+		wantRejects int // -1 when the rejects RecordSet should be nil
+	}{
+		{"drop", "testdata/ten.csv", DropInvalid, 7, -1},
+		{"repair", "testdata/ten.csv", RepairInvalid, 10, -1},
+		{"quarantine", "testdata/ten.csv", QuarantineInvalid, 7, 3},
+		{"repair and quarantine bad mmsi", "testdata/badMMSIData.csv", RepairInvalid | QuarantineInvalid, 9, 1},
+		{"quarantine bad time", "testdata/badTimeData.csv", QuarantineInvalid, 6, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := OpenRecordSet(tt.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Close()
+			v, _ := NewValidator(rs.Headers())
+
+			clean, rejects, err := rs.Clean(v, tt.action)
+			if err != nil {
+				t.Fatalf("RecordSet.Clean() error = %v", err)
+			}
+			if got := countRecords(t, clean); got != tt.wantClean {
+				t.Errorf("RecordSet.Clean() clean records = %d, want %d", got, tt.wantClean)
+			}
+			if tt.wantRejects < 0 {
+				if rejects != nil {
+					t.Errorf("RecordSet.Clean() rejects = %v, want nil", rejects)
+				}
+				return
+			}
+			if _, ok := rejects.Headers().Contains(ReasonField); !ok {
+				t.Errorf("RecordSet.Clean() rejects headers do not contain %s", ReasonField)
+			}
+			if got := countRecords(t, rejects); got != tt.wantRejects {
+				t.Errorf("RecordSet.Clean() rejected records = %d, want %d", got, tt.wantRejects)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package ais
 
 import (
 	"bytes"
+	"encoding/csv"
 	"fmt"
+	"io"
 	"strings"
+	"testing"
 	"time"
 )
 
@@ -61,7 +64,9 @@ var testWindow = Window{
 	width:       5 * time.Second,
 	rightMarker: time.Date(2017, time.December, 1, 00, 00, 01, 0, time.UTC).Add(5 * time.Second),
 	timeIndex:   1,
-	Data:        nil,
+	mmsiIndex:   0,
+	latIndex:    2,
+	lonIndex:    3,
 }
 
 var badHeaders = Headers{ // Missing canonical name BaseDateTime
@@ -124,3 +129,47 @@ type trueMatcher struct{}
 func (*trueMatcher) Match(*Record) (bool, error) {
 	return true, nil
 }
+
+// newTestRecordSet returns a RecordSet that reads the csv data, whose first
+// line holds the headers.
+func newTestRecordSet(t *testing.T, data string) *RecordSet {
+	t.Helper()
+	rs := NewRecordSet()
+	rs.r = csv.NewReader(strings.NewReader(data))
+	fields, err := rs.r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.SetHeaders(Headers{Fields: fields})
+	return rs
+}
+
+// readAll returns the Records remaining in rs.
+func readAll(t *testing.T, rs *RecordSet) []Record {
+	t.Helper()
+	var recs []Record
+	for {
+		rec, err := rs.Read()
+		if err != nil {
+			break
+		}
+		recs = append(recs, *rec)
+	}
+	return recs
+}
+
+// countRecords reads rs to io.EOF and returns the number of Records read.
+func countRecords(t *testing.T, rs *RecordSet) int {
+	t.Helper()
+	n := 0
+	for {
+		_, err := rs.Read()
+		if err == io.EOF {
+			return n
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,200 @@
+package ais
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VesselCategory is a broad grouping of the many vessel type codes used in AIS
+// data.  A VesselCategory is what most analysis needs when deciding if two ships
+// are alike, e.g. comparing the traffic of tankers and cargo ships.
+type VesselCategory string
+
+// The categories returned by a VesselType lookup.  CategoryUnknown is used for
+// blank, unparsable, reserved and unrecognized codes.
+const (
+	CategoryUnknown   VesselCategory = "Unknown"
+	CategoryCargo     VesselCategory = "Cargo"
+	CategoryTanker    VesselCategory = "Tanker"
+	CategoryFishing   VesselCategory = "Fishing"
+	CategoryPassenger VesselCategory = "Passenger"
+	CategoryTug       VesselCategory = "Tug"
+	CategoryPleasure  VesselCategory = "Pleasure"
+	CategoryOther     VesselCategory = "Other"
+)
+
+// VesselType is the description and category of a single vessel type code.
+type VesselType struct {
+	Code        int
+	Description string
+	Category    VesselCategory
+}
+
+// ITUShipTypes maps the ship and cargo type codes 20 through 99 of
+// Recommendation ITU-R M.1371 to a VesselType.  The second digit of the codes in
+// the twenties and in the forties through nineties describes the hazardous cargo
+// carried and does not change the category.
+var ITUShipTypes = ituShipTypes()
+
+// MarineCadastreTypes maps the vessel type codes 1001 through 1025 used in the
+// MarineCadastre.gov data prior to 2018 to a VesselType.
+var MarineCadastreTypes = map[int]VesselType{
+	1001: {1001, "Commercial Fishing Vessel", CategoryFishing},
+	1002: {1002, "Fish Processing Vessel", CategoryFishing},
+	1003: {1003, "Freight Barge", CategoryCargo},
+	1004: {1004, "Freight Ship", CategoryCargo},
+	1005: {1005, "Industrial Vessel", CategoryOther},
+	1006: {1006, "Miscellaneous Vessel", CategoryOther},
+	1007: {1007, "Mobile Offshore Drilling Unit", CategoryOther},
+	1008: {1008, "Non-vessel", CategoryOther},
+	1009: {1009, "Non-self Propelled Vessel", CategoryOther},
+	1010: {1010, "Offshore Supply Vessel", CategoryOther},
+	1011: {1011, "Oil Recovery", CategoryOther},
+	1012: {1012, "Passenger (Inspected)", CategoryPassenger},
+	1013: {1013, "Passenger (Uninspected)", CategoryPassenger},
+	1014: {1014, "Passenger (Sailing)", CategoryPassenger},
+	1015: {1015, "Public Freight", CategoryCargo},
+	1016: {1016, "Public Tank Ship", CategoryTanker},
+	1017: {1017, "Public Vessel, Unclassified", CategoryOther},
+	1018: {1018, "Research Vessel", CategoryOther},
+	1019: {1019, "Recreational", CategoryPleasure},
+	1020: {1020, "School Ship", CategoryOther},
+	1021: {1021, "Tank Barge", CategoryTanker},
+	1022: {1022, "Tank Ship", CategoryTanker},
+	1023: {1023, "Towing Vessel", CategoryTug},
+	1024: {1024, "Unspecified", CategoryUnknown},
+	1025: {1025, "Tug", CategoryTug},
+}
+
+// ituShipTypes builds the ITUShipTypes table.
+func ituShipTypes() map[int]VesselType {
+	m := make(map[int]VesselType)
+	decade := func(first int, desc string, cat VesselCategory) {
+		for code := first; code < first+10; code++ {
+			m[code] = VesselType{code, desc, cat}
+		}
+	}
+	decade(20, "Wing in ground", CategoryOther)
+	decade(40, "High speed craft", CategoryOther)
+	decade(60, "Passenger", CategoryPassenger)
+	decade(70, "Cargo", CategoryCargo)
+	decade(80, "Tanker", CategoryTanker)
+	decade(90, "Other type", CategoryOther)
+
+	for code, vt := range map[int]VesselType{
+		30: {Description: "Fishing", Category: CategoryFishing},
+		31: {Description: "Towing", Category: CategoryTug},
+		32: {Description: "Towing, length exceeds 200m or breadth exceeds 25m", Category: CategoryTug},
+		33: {Description: "Dredging or underwater operations", Category: CategoryOther},
+		34: {Description: "Diving operations", Category: CategoryOther},
+		35: {Description: "Military operations", Category: CategoryOther},
+		36: {Description: "Sailing", Category: CategoryPleasure},
+		37: {Description: "Pleasure craft", Category: CategoryPleasure},
+		38: {Description: "Reserved", Category: CategoryUnknown},
+		39: {Description: "Reserved", Category: CategoryUnknown},
+		50: {Description: "Pilot vessel", Category: CategoryOther},
+		51: {Description: "Search and rescue vessel", Category: CategoryOther},
+		52: {Description: "Tug", Category: CategoryTug},
+		53: {Description: "Port tender", Category: CategoryOther},
+		54: {Description: "Anti-pollution equipment", Category: CategoryOther},
+		55: {Description: "Law enforcement", Category: CategoryOther},
+		56: {Description: "Spare, local vessel", Category: CategoryOther},
+		57: {Description: "Spare, local vessel", Category: CategoryOther},
+		58: {Description: "Medical transport", Category: CategoryOther},
+		59: {Description: "Noncombatant ship", Category: CategoryOther},
+	} {
+		vt.Code = code
+		m[code] = vt
+	}
+	return m
+}
+
+// LookupVesselType returns the VesselType for an ITU-R M.1371 or a
+// MarineCadastre code.  The bool is false for codes that are in neither table.
+func LookupVesselType(code int) (VesselType, bool) {
+	if vt, ok := ITUShipTypes[code]; ok {
+		return vt, true
+	}
+	vt, ok := MarineCadastreTypes[code]
+	return vt, ok
+}
+
+// ParseVesselType converts the VesselType value of a Record into a VesselType.
+// Values such as "70.0" that are written by some tools as floats are accepted.
+// It returns an error for blank values, values that are not numbers and codes
+// that are in neither lookup table.
+func ParseVesselType(s string) (VesselType, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return VesselType{}, fmt.Errorf("vessel type: blank value")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != float64(int(f)) {
+		return VesselType{}, fmt.Errorf("vessel type: unable to parse %q", s)
+	}
+	vt, ok := LookupVesselType(int(f))
+	if !ok {
+		return VesselType{}, fmt.Errorf("vessel type: unknown code %d", int(f))
+	}
+	return vt, nil
+}
+
+// Category returns the VesselCategory of the VesselType value s, or
+// CategoryUnknown when s cannot be parsed.
+func Category(s string) VesselCategory {
+	vt, err := ParseVesselType(s)
+	if err != nil {
+		return CategoryUnknown
+	}
+	return vt.Category
+}
+
+// VesselCategorizer implements the Generator interface to append the
+// VesselCategory of each Record to a RecordSet.  The single index value must be
+// the index of "VesselType" in the Record.
+//
+//	rs, err = rs.AppendField("Category", []string{"VesselType"}, ais.VesselCategorizer{})
+type VesselCategorizer struct{}
+
+// Generate implements the Generator interface for a VesselCategorizer.  Blank
+// and unrecognized codes generate CategoryUnknown rather than an error so that
+// AppendField does not stop on the many Records without static data.
+func (VesselCategorizer) Generate(rec Record, index ...int) (Field, error) {
+	if len(index) != 1 {
+		return "", fmt.Errorf("vessel categorizer: generate: len(index) must equal" +
+			" 1 where the int is the index of `VesselType`")
+	}
+	val, ok := rec.Value(index[0])
+	if !ok {
+		return "", fmt.Errorf("vessel categorizer: index %d out of range", index[0])
+	}
+	return Field(Category(val)), nil
+}
+
+// CategoryMatcher implements the Matching interface to subset a RecordSet by
+// VesselCategory.  TypeIndex is the index of "VesselType" in a Record.  A Record
+// matches when its category is one of Categories.
+//
+//	m := &ais.CategoryMatcher{TypeIndex: idx, Categories: []ais.VesselCategory{ais.CategoryTanker}}
+//	tankers, err := rs.Subset(m)
+type CategoryMatcher struct {
+	TypeIndex  int
+	Categories []VesselCategory
+}
+
+// Match implements the Matching interface for a CategoryMatcher.  The only
+// error returned is for a TypeIndex that is out of range for the Record.
+func (cm *CategoryMatcher) Match(rec *Record) (bool, error) {
+	val, ok := rec.Value(cm.TypeIndex)
+	if !ok {
+		return false, fmt.Errorf("category matcher: index %d out of range", cm.TypeIndex)
+	}
+	cat := Category(val)
+	for _, c := range cm.Categories {
+		if c == cat {
+			return true, nil
+		}
+	}
+	return false, nil
+}
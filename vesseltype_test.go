@@ -0,0 +1,127 @@
+package ais
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVesselType(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    VesselCategory
+		wantErr bool
+	}{
+		{"marinecadastre freight ship", "1004", CategoryCargo, false},
+		{"marinecadastre tug", "1025", CategoryTug, false},
+		{"marinecadastre recreational", "1019", CategoryPleasure, false},
+		{"itu fishing", "30", CategoryFishing, false},
+		{"itu towing", "31", CategoryTug, false},
+		{"itu tug", "52", CategoryTug, false},
+		{"itu passenger hazard a", "61", CategoryPassenger, false},
+		{"itu cargo", "70", CategoryCargo, false},
+		{"itu tanker as float", "84.0", CategoryTanker, false},
+		{"itu sailing", "36", CategoryPleasure, false},
+		{"itu other", "99", CategoryOther, false},
+		{"not available", "0", CategoryUnknown, true},
+		{"reserved", "15", CategoryUnknown, true},
+		{"blank", "", CategoryUnknown, true},
+		{"not a number", "cargo", CategoryUnknown, true},
+		{"fraction", "70.5", CategoryUnknown, true},
+		{"out of range", "1026", CategoryUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt, err := ParseVesselType(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVesselType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := Category(tt.s); got != tt.want {
+				t.Errorf("Category() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && vt.Category != tt.want {
+				t.Errorf("ParseVesselType().Category = %v, want %v", vt.Category, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupVesselType(t *testing.T) {
+	for code := 20; code <= 99; code++ {
+		vt, ok := LookupVesselType(code)
+		if !ok || vt.Code != code || vt.Description == "" {
+			t.Errorf("LookupVesselType(%d) = %+v, %v", code, vt, ok)
+		}
+	}
+	for code := 1001; code <= 1025; code++ {
+		vt, ok := LookupVesselType(code)
+		if !ok || vt.Code != code || vt.Description == "" {
+			t.Errorf("LookupVesselType(%d) = %+v, %v", code, vt, ok)
+		}
+	}
+}
+
+func TestVesselCategorizer_Generate(t *testing.T) {
+	rs, err := OpenRecordSet("testdata/ten.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	rs2, err := rs.AppendField("Category", []string{"VesselType"}, VesselCategorizer{})
+	if err != nil {
+		t.Fatalf("AppendField() error = %v", err)
+	}
+	catIndex, ok := rs2.Headers().Contains("Category")
+	if !ok {
+		t.Fatal("AppendField() did not add Category header")
+	}
+	var got []string
+	for {
+		rec, err := rs2.Read()
+		if err != nil {
+			break
+		}
+		got = append(got, (*rec)[catIndex])
+	}
+	want := []string{"Cargo", "Unknown", "Tug", "Cargo", "Tug", "Unknown",
+		"Cargo", "Fishing", "Tug", "Pleasure"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VesselCategorizer categories = %v, want %v", got, want)
+	}
+
+	if _, err := (VesselCategorizer{}).Generate(Record{"1004"}); err == nil {
+		t.Errorf("Generate() with no index error = nil, want error")
+	}
+	if _, err := (VesselCategorizer{}).Generate(Record{"1004"}, 3); err == nil {
+		t.Errorf("Generate() with out of range index error = nil, want error")
+	}
+}
+
+func TestCategoryMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		rec     Record
+		cats    []VesselCategory
+		want    bool
+		wantErr bool
+	}{
+		{"tug", Record{"1025"}, []VesselCategory{CategoryTug}, true, false},
+		{"one of many", Record{"80"}, []VesselCategory{CategoryCargo, CategoryTanker}, true, false},
+		{"no match", Record{"1004"}, []VesselCategory{CategoryTanker}, false, false},
+		{"unknown", Record{""}, []VesselCategory{CategoryUnknown}, true, false},
+		{"out of range", Record{}, []VesselCategory{CategoryUnknown}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &CategoryMatcher{TypeIndex: 0, Categories: tt.cats}
+			got, err := cm.Match(&tt.rec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CategoryMatcher.Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CategoryMatcher.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,17 +2,41 @@ package ais
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"time"
 )
 
 // Window is used to create a convolution algorithm that slides down a RecordSet
-// and performs analysis on Records that are within the a time window.
+// and performs analysis on Records that are within the a time window.  The
+// Records are held in order of their BaseDateTime, which is parsed once when
+// each Record is added, so a Slide only removes the Records that have left the
+// Window and Records, FindClusters and String return the Records in the same
+// order every time.
 type Window struct {
 	leftMarker, rightMarker time.Time
 	timeIndex               int
 	width                   time.Duration
-	Data                    map[uint64]*Record
+	layout                  string        // layout used to parse BaseDateTime
+	entries                 []windowEntry // ordered by time, then by insertion
+	hashes                  map[uint64]bool
+
+	mmsiIndex, latIndex, lonIndex int // -1 when not in the Headers
+	mode                          SnapshotMode
+	latest                        map[string]windowEntry // by MMSI for LatestReports
+
+	policy  ErrorPolicy // handling of malformed Records by Clusters
+	sink    RejectSink
+	skipped int
+
+	// Data holds the Records in the Window by their hash.  It is kept for
+	// clients written before the Window held its Records in time order.
+	//
+	// Deprecated: use Records, which returns the Records in time order.
+	// Changes made to Data are not seen by the Window.
+	Data map[uint64]*Record
 }
 
 // NewWindow returns a *Window with the left marker set to the time in
@@ -29,11 +53,23 @@ func NewWindow(rs *RecordSet, width time.Duration) (*Window, error) {
 		return nil, fmt.Errorf("newwindow: headers does not contain BaseDateTime")
 	}
 	win.SetIndex(timeIndex)
+	win.layout = rs.Headers().TimeLayout()
+	win.mmsiIndex, win.latIndex, win.lonIndex = -1, -1, -1
+	if i, ok := rs.Headers().Contains("MMSI"); ok {
+		win.mmsiIndex = i
+	}
+	if i, ok := rs.Headers().Contains("LAT"); ok {
+		win.latIndex = i
+	}
+	if i, ok := rs.Headers().Contains("LON"); ok {
+		win.lonIndex = i
+	}
+	win.SetErrorPolicy(rs.policy, rs.sink)
 	rec, err := rs.readFirst()
 	if err != nil {
 		return nil, fmt.Errorf("newwindow: %v", err)
 	}
-	t, err := time.Parse(TimeLayout, (*rec)[timeIndex])
+	t, err := parseTimeLayout(win.layout, (*rec)[timeIndex])
 	if err != nil {
 		return nil, fmt.Errorf("newwindow: %v", err)
 	}
@@ -73,14 +109,81 @@ func (win *Window) SetIndex(index int) {
 	win.timeIndex = index
 }
 
-// AddRecord appends a new Record to the data in the Window.
+// SetErrorPolicy sets the ErrorPolicy that Clusters applies to Records with a
+// malformed geohash and the RejectSink for RejectInvalid.  NewWindow sets the
+// policy of the RecordSet.
+func (win *Window) SetErrorPolicy(policy ErrorPolicy, sink RejectSink) {
+	win.policy = policy
+	win.sink = sink
+}
+
+// Skipped returns the number of Records skipped or rejected by Clusters.
+func (win *Window) Skipped() int { return win.skipped }
+
+// windowEntry is a Record held by a Window with its parsed BaseDateTime and
+// its hash.
+type windowEntry struct {
+	t    time.Time
+	hash uint64
+	rec  *Record
+}
+
+// AddRecord appends a new Record to the data in the Window.  A Record that is
+// identical to one already in the Window is not added again.  The BaseDateTime
+// of the Record is parsed to order it in the Window.  A Record whose time
+// cannot be parsed is ordered first and removed by the next Slide.  Use
+// AddRecordAt when the time has already been parsed.
 func (win *Window) AddRecord(rec Record) {
-	if win.Data == nil {
+	val, _ := rec.Value(win.timeIndex)
+	t, _ := parseTimeLayout(win.layout, val)
+	win.AddRecordAt(rec, t)
+}
+
+// AddRecordAt adds a Record whose BaseDateTime is t to the Window.  Records
+// added in time order are appended in constant time.  A Record that is older
+// than the newest one in the Window is inserted at its place in time order.
+// Under LatestReports the Record replaces the one held for its MMSI.
+func (win *Window) AddRecordAt(rec Record, t time.Time) {
+	if win.hashes == nil {
+		win.hashes = make(map[uint64]bool)
 		win.Data = make(map[uint64]*Record)
 	}
 	h := rec.Hash()
-	// fmt.Println("hash is: ", h)
+	if win.hashes[h] {
+		return
+	}
+	if win.mode == LatestReports {
+		if !win.keepLatest(rec, t) {
+			return
+		}
+		if win.latest == nil {
+			win.latest = make(map[string]windowEntry)
+		}
+		mmsi, _ := rec.Value(win.mmsiIndex)
+		win.latest[mmsi] = windowEntry{t: t, hash: h}
+	}
+	win.hashes[h] = true
 	win.Data[h] = &rec
+	e := windowEntry{t: t, hash: h, rec: &rec}
+	n := len(win.entries)
+	if n == 0 || !t.Before(win.entries[n-1].t) {
+		win.entries = append(win.entries, e)
+		return
+	}
+	i := sort.Search(n, func(i int) bool { return t.Before(win.entries[i].t) })
+	win.entries = append(win.entries, windowEntry{})
+	copy(win.entries[i+1:], win.entries[i:])
+	win.entries[i] = e
+}
+
+// Records returns the Records in the Window in order of their BaseDateTime.
+// Records with the same time are in the order they were added.
+func (win *Window) Records() []*Record {
+	recs := make([]*Record, len(win.entries))
+	for i, e := range win.entries {
+		recs[i] = e.rec
+	}
+	return recs
 }
 
 // InWindow tests if a time is in the Window.
@@ -95,7 +198,7 @@ func (win *Window) InWindow(t time.Time) bool {
 // Errors are possible from parsing the BaseDateTime field of the
 // Record.
 func (win *Window) RecordInWindow(rec *Record) (bool, error) {
-	t, err := rec.ParseTime(win.timeIndex)
+	t, err := parseTimeLayout(win.layout, (*rec)[win.timeIndex])
 	if err != nil {
 		return false, fmt.Errorf("recordinwindow: %v", err)
 	}
@@ -109,34 +212,201 @@ func (win *Window) Slide(dur time.Duration) {
 	win.SetLeft(win.leftMarker.Add(dur))
 	win.SetRight(win.leftMarker.Add(win.Width()))
 
-	win.validate()
+	win.evict()
 }
 
-// Len returns the lenght of the slice holding the Records in the Window
-func (win *Window) Len() int {
-	return len(win.Data)
+// ErrStopRun can be returned by the function passed to Window.Run or
+// Window.RunSessions to stop the run early without an error.
+var ErrStopRun = errors.New("stop window run")
+
+// Run slides the Window down the chronologically sorted rs and calls fn with
+// each position of the Window that holds Records.  It replaces the loop of
+// Read, RecordInWindow, AddRecord, Stash and Slide that convolution algorithms
+// otherwise write themselves.  Each Record is read once and fn is called after
+// every Record in the time span of the Window has been added.  The Window then
+// slides by slide, keeping the Records that are still in it.  When the Window
+// is empty and the next Record is further away than one slide the Window jumps
+// forward by a whole number of slides to the first position that holds the
+// Record, so gaps in the data do not produce empty windows.  After the last
+// Record the Window slides on until it is empty.
+//
+// A slide equal to the Width of the Window gives tumbling windows in which
+// every Record appears once.  A shorter slide gives hopping windows that
+// overlap, so a Record appears in Width/slide successive windows.  A slide
+// longer than the Width samples the data and Records that fall between two
+// positions of the Window are not passed to fn.
+//
+// Records whose BaseDateTime cannot be parsed or is earlier than the Record
+// before it are handled by the ErrorPolicy of rs.  A non-nil error from fn
+// stops the run and is returned by Run, except ErrStopRun which stops the run
+// and returns nil.
+//
+//	win, err := ais.NewWindow(rs, 10*time.Minute)
+//	err = win.Run(rs, 5*time.Minute, func(win *ais.Window) error {
+//		for _, cluster := range win.FindClusters(geohashIndex) {
+//			// analyze the cluster
+//		}
+//		return nil
+//	})
+func (win *Window) Run(rs *RecordSet, slide time.Duration, fn func(*Window) error) error {
+	if slide <= 0 || win.Width() <= 0 {
+		return fmt.Errorf("window run: width and slide must be positive")
+	}
+	advance := func(t time.Time) {
+		win.Slide(slide)
+		if win.Len() == 0 && !t.Before(win.Right()) {
+			k := (t.Sub(win.Left())-win.Width())/slide + 1
+			win.Slide(k * slide)
+		}
+	}
+	return win.run(rs, slide, fn, advance, nil)
 }
 
-// Validate checks the data held by the Window to ensure every Record passes
-// the InWindow test.
-func (win *Window) validate() error {
-	for hash, rec := range win.Data {
-		in, err := win.RecordInWindow(rec)
+// RunSessions passes session windows over the chronologically sorted rs and
+// calls fn with each one.  A session starts with the first Record that is not
+// in the previous session and grows as long as each new Record is less than
+// gap after the Record before it, so the Left and Right of the Window are the
+// time of the first Record in the session and gap after the last one.  The
+// Records of a session are cleared before the next one starts.  Errors are
+// handled as in Run.
+func (win *Window) RunSessions(rs *RecordSet, gap time.Duration, fn func(*Window) error) error {
+	if gap <= 0 {
+		return fmt.Errorf("window run sessions: gap must be positive")
+	}
+	start := func(t time.Time) {
+		win.SetLeft(t)
+		win.SetWidth(gap)
+		win.SetRight(t.Add(gap))
+	}
+	start(win.Left())
+	advance := func(t time.Time) {
+		win.clear()
+		start(t)
+	}
+	added := func(t time.Time) {
+		if right := t.Add(gap); right.After(win.Right()) {
+			win.SetRight(right)
+			win.SetWidth(right.Sub(win.Left()))
+		}
+	}
+	return win.run(rs, 0, fn, advance, added)
+}
+
+// run reads rs to the end, adding each Record to the Window when it is in the
+// Window.  When a Record is after the Window, fn is called with the Window if
+// it holds Records and advance moves the Window on, until the Record is no
+// longer after the Window.  added, which may be nil, is called with the time of
+// each Record added.  At the end of the data the Window slides by slide until
+// it is empty, or stops after one call to fn when slide is zero.
+func (win *Window) run(rs *RecordSet, slide time.Duration, fn func(*Window) error, advance, added func(time.Time)) error {
+	emit := func() error {
+		if win.Len() == 0 {
+			return nil
+		}
+		return fn(win)
+	}
+	var last time.Time
+	for {
+		rec, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("window run: %v", err)
+		}
+		val, _ := rec.Value(win.timeIndex)
+		t, err := parseTimeLayout(win.layout, val)
+		if err == nil && t.Before(last) {
+			err = fmt.Errorf("record is earlier than the record before it, sort the recordset by time")
+		}
+		if err != nil {
+			if err := rs.reject(*rec, win.timeIndex, err); err != nil {
+				return fmt.Errorf("window run: %v", err)
+			}
+			continue
+		}
+		last = t
+
+		for !t.Before(win.Right()) {
+			if err := emit(); err != nil {
+				return stopRun(err)
+			}
+			advance(t)
+		}
+		if win.InWindow(t) {
+			win.AddRecordAt(*rec, t)
+			if added != nil {
+				added(t)
+			}
+		}
+	}
+	// Slide on past the end of the data so that every position that holds
+	// one of the last Records is passed to fn.
+	for win.Len() > 0 {
+		if err := emit(); err != nil {
+			return stopRun(err)
 		}
-		if !in {
-			delete(win.Data, hash)
+		if slide <= 0 {
+			break
 		}
+		win.Slide(slide)
 	}
 	return nil
 }
 
+// stopRun returns nil for ErrStopRun and err otherwise.
+func stopRun(err error) error {
+	if err == ErrStopRun {
+		return nil
+	}
+	return err
+}
+
+// Len returns the lenght of the slice holding the Records in the Window
+func (win *Window) Len() int {
+	return len(win.entries)
+}
+
+// evict removes the Records that are no longer in the Window.  Because the
+// Records are in time order the Records before the left marker and after the
+// right marker are found by binary search.
+func (win *Window) evict() {
+	n := len(win.entries)
+	lo := sort.Search(n, func(i int) bool { return !win.entries[i].t.Before(win.leftMarker) })
+	hi := sort.Search(n, func(i int) bool { return !win.entries[i].t.Before(win.rightMarker) })
+	if hi < lo {
+		hi = lo
+	}
+	remove := func(expired []windowEntry) {
+		for i := range expired {
+			delete(win.hashes, expired[i].hash)
+			delete(win.Data, expired[i].hash)
+			if win.latest != nil {
+				mmsi, _ := expired[i].rec.Value(win.mmsiIndex)
+				delete(win.latest, mmsi)
+			}
+			// Clear the entry so that its Record can be collected.
+			expired[i] = windowEntry{}
+		}
+	}
+	remove(win.entries[:lo])
+	remove(win.entries[hi:])
+	win.entries = win.entries[lo:hi]
+}
+
+// clear removes every Record from the Window.
+func (win *Window) clear() {
+	win.entries = nil
+	win.hashes = nil
+	win.Data = nil
+	win.latest = nil
+}
+
 // String implements the Stringer interface for Window.
 func (win *Window) String() string {
 	var buf bytes.Buffer
-	for _, rec := range win.Data {
-		fmt.Fprintln(&buf, rec)
+	for _, e := range win.entries {
+		fmt.Fprintln(&buf, e.rec)
 	}
 	return buf.String()
 }
@@ -2,7 +2,13 @@ package ais
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -175,10 +181,252 @@ func TestWindow_AddRecord(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.win.AddRecord(tt.args.rec)
-			got := len(tt.win.Data)
+			got := tt.win.Len()
 			if got != tt.want {
 				t.Errorf("Window.AddRecord() error: got len=%v, want len=%v", got, tt.want)
 			}
 		})
 	}
 }
+
+// windowData has Records each minute from 00:00 to 00:04 and then from 00:30
+// to 00:31, so there is a gap of more than twenty minutes.
+const windowData = `MMSI,BaseDateTime
+1,2017-12-01T00:00:00
+2,2017-12-01T00:01:00
+3,2017-12-01T00:02:00
+4,2017-12-01T00:03:00
+5,2017-12-01T00:04:00
+6,2017-12-01T00:30:00
+7,2017-12-01T00:31:00
+`
+
+// windowStates returns the left marker and the MMSI in time order of each
+// Window passed to the callback of a run.
+func windowStates(states *[]string) func(win *Window) error {
+	return func(win *Window) error {
+		var mmsi []string
+		for _, rec := range win.Records() {
+			mmsi = append(mmsi, (*rec)[0])
+		}
+		*states = append(*states, win.Left().Format("04")+":"+strings.Join(mmsi, ""))
+		return nil
+	}
+}
+
+func TestWindow_Run(t *testing.T) {
+	tests := []struct {
+		name  string
+		width time.Duration
+		slide time.Duration
+		want  []string
+	}{
+		{"tumbling", 2 * time.Minute, 2 * time.Minute, []string{"00:12", "02:34", "04:5", "30:67"}},
+		{"hopping", 2 * time.Minute, time.Minute, []string{"00:12", "01:23", "02:34", "03:45", "04:5", "29:6", "30:67", "31:7"}},
+		{"sampling", time.Minute, 2 * time.Minute, []string{"00:1", "02:3", "04:5", "30:6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRecordSetFromReader(strings.NewReader(windowData))
+			if err != nil {
+				t.Fatalf("NewRecordSetFromReader() error = %v", err)
+			}
+			win, err := NewWindow(rs, tt.width)
+			if err != nil {
+				t.Fatalf("NewWindow() error = %v", err)
+			}
+			var got []string
+			if err := win.Run(rs, tt.slide, windowStates(&got)); err != nil {
+				t.Fatalf("Window.Run() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Window.Run() windows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow_RunSessions(t *testing.T) {
+	rs, err := NewRecordSetFromReader(strings.NewReader(windowData))
+	if err != nil {
+		t.Fatalf("NewRecordSetFromReader() error = %v", err)
+	}
+	win, err := NewWindow(rs, time.Minute)
+	if err != nil {
+		t.Fatalf("NewWindow() error = %v", err)
+	}
+	var got []string
+	var widths []time.Duration
+	err = win.RunSessions(rs, 5*time.Minute, func(win *Window) error {
+		widths = append(widths, win.Width())
+		return windowStates(&got)(win)
+	})
+	if err != nil {
+		t.Fatalf("Window.RunSessions() error = %v", err)
+	}
+	want := []string{"00:12345", "30:67"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Window.RunSessions() windows = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(widths, []time.Duration{9 * time.Minute, 6 * time.Minute}) {
+		t.Errorf("Window.RunSessions() widths = %v, want [9m 6m]", widths)
+	}
+}
+
+func TestWindow_RunErrors(t *testing.T) {
+	unsorted := windowData + "8,2017-12-01T00:02:30\n"
+	tests := []struct {
+		name    string
+		data    string
+		policy  ErrorPolicy
+		fn      func(n *int) func(*Window) error
+		want    int // windows passed to fn
+		wantErr bool
+	}{
+		{"stop", windowData, FailFast, func(n *int) func(*Window) error {
+			return func(*Window) error {
+				if *n++; *n == 2 {
+					return ErrStopRun
+				}
+				return nil
+			}
+		}, 2, false},
+		{"callback error", windowData, FailFast, func(n *int) func(*Window) error {
+			return func(*Window) error { *n++; return errors.New("failed") }
+		}, 1, true},
+		{"unsorted", unsorted, FailFast, countWindows, 3, true},
+		{"unsorted skipped", unsorted, SkipInvalid, countWindows, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewRecordSetFromReader(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("NewRecordSetFromReader() error = %v", err)
+			}
+			rs.SetErrorPolicy(tt.policy, nil)
+			win, err := NewWindow(rs, 2*time.Minute)
+			if err != nil {
+				t.Fatalf("NewWindow() error = %v", err)
+			}
+			n := 0
+			err = win.Run(rs, 2*time.Minute, tt.fn(&n))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Window.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n != tt.want {
+				t.Errorf("Window.Run() called fn %d times, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func countWindows(n *int) func(*Window) error {
+	return func(*Window) error { *n++; return nil }
+}
+
+func TestWindow_Slide(t *testing.T) {
+	start := time.Date(2017, time.December, 1, 0, 0, 0, 0, time.UTC)
+	win := &Window{timeIndex: 1, width: 2 * time.Minute}
+	win.SetLeft(start)
+	win.SetRight(start.Add(win.Width()))
+	// Added out of time order, with a duplicate and a time that cannot be parsed.
+	for _, s := range []string{
+		"3,2017-12-01T00:01:30",
+		"1,2017-12-01T00:00:00",
+		"2,2017-12-01T00:01:00",
+		"1,2017-12-01T00:00:00",
+		"4,2017-12-01T00:01:30",
+		"5,notatime",
+	} {
+		win.AddRecord(Record(strings.Split(s, ",")))
+	}
+	mmsi := func() string {
+		var s []string
+		for _, rec := range win.Records() {
+			s = append(s, (*rec)[0])
+		}
+		return strings.Join(s, "")
+	}
+
+	tests := []struct {
+		slide time.Duration
+		want  string
+	}{
+		{0, "51234"},
+		{time.Minute, "234"},
+		{time.Minute, ""},
+	}
+	// The deprecated Data map holds the same Records as the Window.
+	checkData := func(slide time.Duration) {
+		if len(win.Data) != win.Len() {
+			t.Errorf("after Slide(%v) len(Window.Data) = %d, want %d", slide, len(win.Data), win.Len())
+		}
+		for _, rec := range win.Records() {
+			if win.Data[rec.Hash()] != rec {
+				t.Errorf("after Slide(%v) Window.Data does not hold Record %v", slide, *rec)
+			}
+		}
+	}
+	if got := mmsi(); got != tests[0].want {
+		t.Errorf("Window.Records() = %s, want %s", got, tests[0].want)
+	}
+	checkData(0)
+	for _, tt := range tests[1:] {
+		win.Slide(tt.slide)
+		if got := mmsi(); got != tt.want || win.Len() != len(tt.want) {
+			t.Errorf("after Slide(%v) Window.Records() = %s with Len %d, want %s", tt.slide, got, win.Len(), tt.want)
+		}
+		checkData(tt.slide)
+	}
+
+	// A Record removed by a Slide can be added again.
+	win.AddRecord(Record{"2", "2017-12-01T00:02:30"})
+	if win.Len() != 1 {
+		t.Errorf("Window.Len() = %d, want 1", win.Len())
+	}
+}
+
+func TestInteractions_SaveOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := Headers{Fields: []string{"MMSI", "BaseDateTime", "LAT", "LON"}}
+	recs := []*Record{
+		{"1", "2017-12-01T00:00:02", "36.5", "-76.5"},
+		{"2", "2017-12-01T00:00:01", "36.5", "-76.5"},
+		{"3", "2017-12-01T00:00:03", "36.5", "-76.5"},
+		{"4", "2017-12-01T00:00:00", "36.5", "-76.5"},
+	}
+	var files []string
+	for i := 0; i < 2; i++ {
+		inter, err := NewInteractions(h)
+		if err != nil {
+			t.Fatalf("NewInteractions() error = %v", err)
+		}
+		// Add the clusters in a different order each time.
+		c1, c2 := &Cluster{data: recs[:3]}, &Cluster{data: recs[1:]}
+		if i == 1 {
+			c1, c2 = c2, c1
+		}
+		for _, c := range []*Cluster{c1, c2} {
+			if err := inter.AddCluster(c); err != nil {
+				t.Fatalf("Interactions.AddCluster() error = %v", err)
+			}
+		}
+		name := filepath.Join(dir, fmt.Sprintf("inter%d.csv", i))
+		if err := inter.Save(name); err != nil {
+			t.Fatalf("Interactions.Save() error = %v", err)
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, string(b))
+	}
+	if files[0] != files[1] {
+		t.Errorf("Interactions.Save() wrote\n%s\nand\n%s", files[0], files[1])
+	}
+}
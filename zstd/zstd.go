@@ -0,0 +1,42 @@
+// Package zstd registers Zstandard as an ais.Compression, so that
+// ais.OpenRecordSet and ais.NewRecordSetFromReader decompress zstd data and
+// RecordSet.Save compresses files whose name ends in Ext.  It is kept apart
+// from package ais so that programs that do not need zstd do not depend on
+// klauspost/compress.  Import it for its side effect.
+//
+//	import _ "github.com/FATHOM5/ais/zstd"
+package zstd
+
+import (
+	"io"
+
+	"github.com/FATHOM5/ais"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Ext is the filename extension that RecordSet.Save compresses with zstd.
+const Ext = ".zst"
+
+const magic = "\x28\xb5\x2f\xfd"
+
+func init() {
+	ais.RegisterCompression(ais.Compression{
+		Name:      "zstd",
+		Ext:       Ext,
+		Magic:     magic,
+		NewReader: newReader,
+		NewWriter: newWriter,
+	})
+}
+
+func newReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+func newWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
@@ -0,0 +1,79 @@
+package zstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/FATHOM5/ais"
+)
+
+// readAll returns the Records remaining in rs.
+func readAll(t *testing.T, rs *ais.RecordSet) []ais.Record {
+	t.Helper()
+	var recs []ais.Record
+	for {
+		rec, err := rs.Read()
+		if err != nil {
+			break
+		}
+		recs = append(recs, *rec)
+	}
+	return recs
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ais")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want, err := ais.OpenRecordSet("../testdata/ten.csv")
+	if err != nil {
+		t.Fatalf("OpenRecordSet() error = %v", err)
+	}
+	defer want.Close()
+	wantRecs := readAll(t, want)
+
+	for _, file := range []string{"ten.csv" + Ext, "ten" + ais.ColumnarExt + Ext} {
+		t.Run(file, func(t *testing.T) {
+			rs, err := ais.OpenRecordSet("../testdata/ten.csv")
+			if err != nil {
+				t.Fatalf("OpenRecordSet() error = %v", err)
+			}
+			defer rs.Close()
+			name := filepath.Join(dir, file)
+			if err := rs.Save(name); err != nil {
+				t.Fatalf("RecordSet.Save() error = %v", err)
+			}
+			b, _ := ioutil.ReadFile(name)
+			if !bytes.HasPrefix(b, []byte(magic)) {
+				t.Fatalf("RecordSet.Save() did not compress %s", file)
+			}
+
+			got, err := ais.OpenRecordSet(name)
+			if err != nil {
+				t.Fatalf("OpenRecordSet(%s) error = %v", file, err)
+			}
+			if gotRecs := readAll(t, got); !reflect.DeepEqual(gotRecs, wantRecs) {
+				t.Errorf("Records = %v, want %v", gotRecs, wantRecs)
+			}
+			if err := got.Close(); err != nil {
+				t.Errorf("RecordSet.Close() error = %v", err)
+			}
+
+			got, err = ais.NewRecordSetFromReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("NewRecordSetFromReader() error = %v", err)
+			}
+			defer got.Close()
+			if gotRecs := readAll(t, got); !reflect.DeepEqual(gotRecs, wantRecs) {
+				t.Errorf("Records from reader = %v, want %v", gotRecs, wantRecs)
+			}
+		})
+	}
+}